@@ -0,0 +1,164 @@
+package affectedtiles
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/whosonfirst/go-whosonfirst-iterwriter/v4/app/iterwriter"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+)
+
+// Tile is a struct describing a map tile in JSON output.
+type Tile struct {
+	Z uint32 `json:"z"`
+	X uint32 `json:"x"`
+	Y uint32 `json:"y"`
+}
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	err := flagset.SetFlagsFromEnvVars(fs, "WOF")
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign flags from environment variables, %w", err)
+	}
+
+	switch format {
+	case "text", "json":
+		// pass
+	default:
+		return fmt.Errorf("Invalid -format flag, %s", format)
+	}
+
+	iterator_paths := fs.Args()
+
+	tiles, err := tippecanoe.NewAffectedTiles(min_zoom, max_zoom)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create affected tiles, %w", err)
+	}
+
+	cb_opts, err := callbackOptions()
+
+	if err != nil {
+		return err
+	}
+
+	if since != "" {
+
+		s, err := tippecanoe.ParseSince(ctx, since, iterator_paths...)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse -since flag, %w", err)
+		}
+
+		defer s.Close()
+
+		// Previous versions of records are processed with the same options, other than -since (since their
+		// relative paths may no longer exist), so that the tiles they have vacated are also invalidated.
+
+		prev_p, err := tippecanoe.DefaultPipelineWithTransformers(ctx, cb_opts, transform_uris...)
+
+		if err != nil {
+			return err
+		}
+
+		err = tiles.AddPrevious(ctx, prev_p, s.Changes, forgiving)
+
+		if err != nil {
+			return err
+		}
+
+		cb_opts.Since = s
+	}
+
+	p, err := tippecanoe.DefaultPipelineWithTransformers(ctx, cb_opts, transform_uris...)
+
+	if err != nil {
+		return err
+	}
+
+	opts := &iterwriter.RunOptions{
+		CallbackFunc:  tippecanoe.IterwriterCallbackFuncBuilderWithPipeline(p, cb_opts),
+		Writer:        tiles,
+		IteratorURI:   iterator_uri,
+		IteratorPaths: iterator_paths,
+		MonitorWriter: os.Stderr,
+		Verbose:       verbose,
+	}
+
+	err = iterwriter.RunWithOptions(ctx, opts)
+
+	if err != nil {
+		return fmt.Errorf("Failed to run iterwriter, %v", err)
+	}
+
+	return writeTiles(os.Stdout, tiles)
+}
+
+// callbackOptions returns the `tippecanoe.IterwriterCallbackFuncBuilderOptions` derived from the flags, which match
+// the flags for the features tool, so that tiles are derived from the same features that tool would emit.
+func callbackOptions() (*tippecanoe.IterwriterCallbackFuncBuilderOptions, error) {
+
+	cb_opts := &tippecanoe.IterwriterCallbackFuncBuilderOptions{
+		RequirePolygon:             require_polygons,
+		IncludeAltFiles:            include_alt_files,
+		Forgiving:                  forgiving,
+		CoerceGeometryCollections:  coerce_geometry_collections,
+		ExplodeGeometryCollections: explode_geometry_collections,
+		ExplodeMultiPolygons:       explode_multipolygons,
+	}
+
+	err := tippecanoe.ApplySelectionOptions(cb_opts, selection_flags.SelectionOptions())
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid flags, %w", err)
+	}
+
+	return cb_opts, nil
+}
+
+func writeTiles(wr io.Writer, tiles *tippecanoe.AffectedTiles) error {
+
+	switch format {
+	case "json":
+
+		out := make([]*Tile, 0)
+
+		for _, t := range tiles.Tiles() {
+			out = append(out, &Tile{Z: uint32(t.Z), X: t.X, Y: t.Y})
+		}
+
+		enc := json.NewEncoder(wr)
+		err := enc.Encode(out)
+
+		if err != nil {
+			return fmt.Errorf("Failed to encode tiles, %w", err)
+		}
+
+	default:
+
+		for _, t := range tiles.Tiles() {
+
+			_, err := fmt.Fprintf(wr, "%d/%d/%d\n", t.Z, t.X, t.Y)
+
+			if err != nil {
+				return fmt.Errorf("Failed to write tile, %w", err)
+			}
+		}
+	}
+
+	return nil
+}
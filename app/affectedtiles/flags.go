@@ -0,0 +1,54 @@
+package affectedtiles
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+)
+
+var iterator_uri string
+var require_polygons bool
+var include_alt_files bool
+var since string
+
+var selection_flags tippecanoe.SelectionFlags
+
+var transform_uris multi.MultiString
+
+var coerce_geometry_collections bool
+var explode_geometry_collections bool
+var explode_multipolygons bool
+
+var min_zoom int
+var max_zoom int
+var format string
+
+var forgiving bool
+var verbose bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("affected-tiles")
+
+	fs.StringVar(&iterator_uri, "iterator-uri", "repo://", "A valid whosonfirst/go-whosonfirst-iterate/v3 URI.")
+	fs.BoolVar(&require_polygons, "require-polygons", false, "Require that geometry type be 'Polygon' or 'MultiPolygon' to be included in output.")
+	fs.BoolVar(&coerce_geometry_collections, "coerce-geometry-collections", false, "Replace GeometryCollections with their polygonal parts (as a Polygon or MultiPolygon) before geometry types are tested.")
+	fs.BoolVar(&explode_geometry_collections, "explode-geometry-collections", false, "Replace GeometryCollections with a feature for each member geometry before geometry types are tested.")
+	fs.BoolVar(&explode_multipolygons, "explode-multipolygons", false, "Replace MultiPolygons with a feature for each polygon before geometry types are tested.")
+	fs.BoolVar(&include_alt_files, "include-alt-files", false, "Include alternate geometry files in output.")
+	fs.StringVar(&since, "since", "", "If not empty, only consider records modified since this value. Valid options are a Unix timestamp or an ISO-8601 date (compared against wof:lastmodified) or a Git commit hash (compared against the files changed between that commit and HEAD in each of the repositories being iterated, skipping repositories where the commit does not exist). All-digit values with fewer than nine digits are treated as YYYYMMDD dates or commits; use a unix: or commit: prefix to be explicit. If a Git commit then the previous geometries of modified and deleted records are also considered.")
+
+	tippecanoe.AppendSelectionFlags(fs, &selection_flags)
+
+	fs.Var(&transform_uris, "transform-uri", "Zero or more registered transformer URIs (for example simplify://?tolerance=0.001 or rename://?from=a&to=b) to apply to each feature, in order, after all other processing. These should match the -transform-uri flags passed to the features tool since they may change the tiles a feature covers.")
+
+	fs.IntVar(&min_zoom, "min-zoom", 0, "The minimum zoom level to derive tiles for.")
+	fs.IntVar(&max_zoom, "max-zoom", 12, "The maximum zoom level to derive tiles for. The maximum value is 24.")
+	fs.StringVar(&format, "format", "text", "The format for output. Valid options are: text (one z/x/y tile per line), json.")
+
+	fs.BoolVar(&forgiving, "forgiving", false, "Be \"forgiving\" of failed records, logging the issue(s) but not triggering errors")
+	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging")
+	return fs
+}
@@ -0,0 +1,184 @@
+package build
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/lookup"
+	"github.com/sfomuseum/go-flags/multi"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe/app/features"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+// RunWithFlagSet starts tippecanoe as a subprocess and then runs the features application writing its output
+// to that process. If tippecanoe exits with a non-zero status a `tippecanoe.ProcessError` is returned.
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	err := flagset.SetFlagsFromEnvVars(fs, "WOF")
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign flags from environment variables, %w", err)
+	}
+
+	err = checkWriterFlags(fs)
+
+	if err != nil {
+		return err
+	}
+
+	if len(layers) > 0 && layer_property == "" {
+		return fmt.Errorf("-layer-property flag is required when -layer flags are present")
+	}
+
+	v, err := lookup.Lookup(fs, "profile")
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive profiles, %w", err)
+	}
+
+	// Profiles define their own writers (for example ?writer-uri=constant://?val=tippecanoe://)
+
+	if len(v.(multi.MultiString)) == 0 {
+
+		wr_uri := "tippecanoe://"
+
+		if layer_property != "" {
+			wr_uri = fmt.Sprintf("%s?layer-property=%s", wr_uri, url.QueryEscape(layer_property))
+		}
+
+		err = fs.Set("writer-uri", fmt.Sprintf("constant://?val=%s", url.QueryEscape(wr_uri)))
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign writer URI, %w", err)
+		}
+	}
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path:   tippecanoe_path,
+		Args:   tippecanoe_args,
+		Layers: layers,
+		Stdout: os.Stdout,
+		Logger: slog.Default(),
+	}
+
+	run_features := func(ctx context.Context) error {
+		return features.RunWithFlagSet(ctx, fs)
+	}
+
+	return runWithProcess(ctx, process_opts, run_features)
+}
+
+// checkWriterFlags returns an error if writers are defined by the -writer-uri flag or the "writers" section (or
+// "writer-uri" key) of the -config file. Writers defined by a config file would otherwise be silently replaced
+// since the writer URI is assigned, as a flag, before the config file is applied.
+func checkWriterFlags(fs *flag.FlagSet) error {
+
+	v, err := lookup.Lookup(fs, "writer-uri")
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive writer URIs, %w", err)
+	}
+
+	if len(v.(multi.MultiCSVString)) > 0 {
+		return fmt.Errorf("-writer-uri flag is not supported, features are written to tippecanoe")
+	}
+
+	config_path, err := lookup.StringVar(fs, "config")
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive config path, %w", err)
+	}
+
+	if config_path == "" {
+		return nil
+	}
+
+	has_writers, err := features.ConfigDefinesFlag(config_path, "writer-uri")
+
+	if err != nil {
+		return fmt.Errorf("Failed to read config, %w", err)
+	}
+
+	if has_writers {
+		return fmt.Errorf("Writers defined by the -config file are not supported, features are written to tippecanoe")
+	}
+
+	return nil
+}
+
+// runWithProcess starts tippecanoe, configured by 'process_opts', and then invokes 'run_features' with a context
+// containing that process (for use by the "tippecanoe://" writer). If tippecanoe exits first its error is returned
+// since it is the cause of any subsequent failure to write features. Otherwise, if 'run_features' fails, its error
+// is returned and the resulting cancellation of tippecanoe is only logged.
+func runWithProcess(ctx context.Context, process_opts *tippecanoe.ProcessOptions, run_features func(context.Context) error) error {
+
+	// The tippecanoe process is killed if the features application fails so that it doesn't produce a
+	// tileset from partial input. Conversely the features application is cancelled if tippecanoe exits.
+
+	process_ctx, process_cancel := context.WithCancel(ctx)
+	defer process_cancel()
+
+	p, err := tippecanoe.StartProcess(process_ctx, process_opts)
+
+	if err != nil {
+		return fmt.Errorf("Failed to start tippecanoe, %w", err)
+	}
+
+	features_ctx, features_cancel := context.WithCancel(ctx)
+	defer features_cancel()
+
+	// Wrap the context before starting the watcher below so that it never reads a variable being reassigned
+
+	features_ctx = tippecanoe.ContextWithProcess(features_ctx, p)
+
+	go func() {
+		select {
+		case <-p.Done():
+			features_cancel()
+		case <-features_ctx.Done():
+		}
+	}()
+
+	features_err := run_features(features_ctx)
+
+	if features_err != nil {
+
+		select {
+		case <-p.Done():
+
+			// tippecanoe exited first so its error is the more useful one
+
+			process_err := p.Wait()
+
+			if process_err != nil {
+				return process_err
+			}
+
+		default:
+
+			process_cancel()
+
+			process_err := p.Wait()
+
+			if process_err != nil {
+				slog.Debug("Cancelled tippecanoe after failing to derive features", "error", process_err)
+			}
+		}
+
+		return fmt.Errorf("Failed to derive features, %w", features_err)
+	}
+
+	return p.Wait()
+}
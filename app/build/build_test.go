@@ -0,0 +1,308 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+)
+
+// stubTippecanoe writes a shell script, whose body is 'script', to a temporary directory returning its path.
+func stubTippecanoe(t *testing.T, script string) string {
+
+	path := filepath.Join(t.TempDir(), "tippecanoe")
+
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755)
+
+	if err != nil {
+		t.Fatalf("Failed to write stub tippecanoe, %v", err)
+	}
+
+	return path
+}
+
+func writeFeature(ctx context.Context) error {
+
+	p, err := tippecanoe.ProcessFromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return p.Write("", []byte(`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[0,0]}}`))
+}
+
+func TestRunWithProcessSuccess(t *testing.T) {
+
+	ctx := context.Background()
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path: stubTippecanoe(t, "cat > /dev/null"),
+	}
+
+	err := runWithProcess(ctx, process_opts, writeFeature)
+
+	if err != nil {
+		t.Fatalf("Expected success, %v", err)
+	}
+}
+
+func TestRunWithProcessTippecanoeFailsFirst(t *testing.T) {
+
+	ctx := context.Background()
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path: stubTippecanoe(t, "echo 'Invalid argument' >&2; exit 3"),
+	}
+
+	run_features := func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("Failed to write features, %w", ctx.Err())
+	}
+
+	err := runWithProcess(ctx, process_opts, run_features)
+
+	var process_err *tippecanoe.ProcessError
+
+	if !errors.As(err, &process_err) {
+		t.Fatalf("Expected tippecanoe.ProcessError, got %v", err)
+	}
+
+	if process_err.ExitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %d", process_err.ExitCode)
+	}
+}
+
+func TestRunWithProcessFeaturesFailsFirst(t *testing.T) {
+
+	ctx := context.Background()
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path: stubTippecanoe(t, "exec sleep 30"),
+	}
+
+	features_err := errors.New("Invalid iterator URI")
+
+	run_features := func(ctx context.Context) error {
+		return features_err
+	}
+
+	err := runWithProcess(ctx, process_opts, run_features)
+
+	if !errors.Is(err, features_err) {
+		t.Fatalf("Expected features error, got %v", err)
+	}
+
+	if strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected features error not cancellation error, got %v", err)
+	}
+}
+
+func TestRunWithProcessLongStderrLine(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// A line longer than PROCESS_MAX_STDERR_LINE, and more than a pipe buffer, must not block tippecanoe
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path: stubTippecanoe(t, "cat > /dev/null; head -c 3000000 /dev/zero | tr '\\0' x >&2; echo >&2; echo 'Invalid argument' >&2; exit 3"),
+	}
+
+	err := runWithProcess(ctx, process_opts, writeFeature)
+
+	var process_err *tippecanoe.ProcessError
+
+	if !errors.As(err, &process_err) {
+		t.Fatalf("Expected tippecanoe.ProcessError, got %v", err)
+	}
+
+	if process_err.ExitCode != 3 || process_err.Message != "Invalid argument" {
+		t.Fatalf("Expected exit code 3 and last message, got %d '%s'", process_err.ExitCode, process_err.Message)
+	}
+}
+
+// stubLayersScript is a stub tippecanoe which reads each "-L {LAYER}:{PATH}" named pipe, in order, and copies its
+// contents to {LAYER}.geojsonl in the directory passed using "-o".
+const stubLayersScript string = `out=""
+layers=""
+while [ $# -gt 0 ]; do
+	case "$1" in
+		-L) layers="$layers $2"; shift 2;;
+		-o) out="$2"; shift 2;;
+		*) shift;;
+	esac
+done
+for l in $layers; do
+	cat "${l#*:}" > "$out/${l%%:*}.geojsonl" || exit 1
+done`
+
+func TestRunWithProcessLayers(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	out := t.TempDir()
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path:   stubTippecanoe(t, stubLayersScript),
+		Args:   []string{"-o", out},
+		Layers: []string{"polygons", "points"},
+	}
+
+	features := []struct {
+		layer string
+		body  string
+	}{
+		// Features for layers other than the first are spooled until the process is closed
+		{"points", `{"type":"Feature","properties":{"wof:id":1},"geometry":{"type":"Point","coordinates":[0,0]}}`},
+		{"polygons", `{"type":"Feature","properties":{"wof:id":2},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}}`},
+		{"points", `{"type":"Feature","properties":{"wof:id":3},"geometry":{"type":"Point","coordinates":[1,1]}}`},
+	}
+
+	run_features := func(ctx context.Context) error {
+
+		p, err := tippecanoe.ProcessFromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, f := range features {
+
+			err := p.Write(f.layer, []byte(f.body+"\n"))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	err := runWithProcess(ctx, process_opts, run_features)
+
+	if err != nil {
+		t.Fatalf("Expected success, %v", err)
+	}
+
+	for _, layer := range process_opts.Layers {
+
+		expected := ""
+
+		for _, f := range features {
+
+			if f.layer == layer {
+				expected += f.body + "\n"
+			}
+		}
+
+		path := filepath.Join(out, layer+".geojsonl")
+
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			t.Fatalf("Failed to read %s, %v", path, err)
+		}
+
+		if string(body) != expected {
+			t.Fatalf("Unexpected features for layer '%s', got %s", layer, body)
+		}
+	}
+}
+
+func TestRunWithProcessLayersExitsEarly(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// tippecanoe exits without opening its named pipes so pending opens must be unblocked rather than hang
+
+	process_opts := &tippecanoe.ProcessOptions{
+		Path:   stubTippecanoe(t, "echo 'Invalid argument' >&2; exit 3"),
+		Layers: []string{"polygons", "points"},
+	}
+
+	run_features := func(ctx context.Context) error {
+
+		p, err := tippecanoe.ProcessFromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		return p.Write("polygons", []byte(`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[0,0]}}`+"\n"))
+	}
+
+	err := runWithProcess(ctx, process_opts, run_features)
+
+	var process_err *tippecanoe.ProcessError
+
+	if !errors.As(err, &process_err) {
+		t.Fatalf("Expected tippecanoe.ProcessError, got %v", err)
+	}
+
+	if process_err.ExitCode != 3 {
+		t.Fatalf("Expected exit code 3, got %d", process_err.ExitCode)
+	}
+}
+
+func TestCheckWriterFlags(t *testing.T) {
+
+	root := t.TempDir()
+
+	configs := map[string]string{
+		"writers.yaml":    "writers:\n  - stdout://\n",
+		"writer-uri.json": `{"writer_uri": "stdout://"}`,
+		"filters.yaml":    "filters:\n  placetype: locality\n",
+	}
+
+	tests := []struct {
+		config  string
+		allowed bool
+	}{
+		{"", true},
+		{"filters.yaml", true},
+		{"writers.yaml", false},
+		{"writer-uri.json", false},
+	}
+
+	for fname, body := range configs {
+
+		err := os.WriteFile(filepath.Join(root, fname), []byte(body), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", fname, err)
+		}
+	}
+
+	for _, test := range tests {
+
+		fs := DefaultFlagSet()
+
+		if test.config != "" {
+
+			err := fs.Set("config", filepath.Join(root, test.config))
+
+			if err != nil {
+				t.Fatalf("Failed to assign config flag, %v", err)
+			}
+		}
+
+		err := checkWriterFlags(fs)
+
+		if test.allowed && err != nil {
+			t.Fatalf("Expected config '%s' to be allowed, %v", test.config, err)
+		}
+
+		if !test.allowed && err == nil {
+			t.Fatalf("Expected config '%s' to be rejected", test.config)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package build
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/multi"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe/app/features"
+)
+
+var tippecanoe_path string
+var tippecanoe_args multi.MultiString
+
+var layers multi.MultiString
+var layer_property string
+
+// DefaultFlagSet returns the flags for the features application plus the flags for running tippecanoe.
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := features.DefaultFlagSet()
+
+	fs.StringVar(&tippecanoe_path, "tippecanoe-path", tippecanoe.TIPPECANOE_BINARY, "The path to the tippecanoe binary.")
+	fs.Var(&tippecanoe_args, "tippecanoe-arg", "Zero or more arguments to pass to tippecanoe, one per flag. For example: -tippecanoe-arg=-zg -tippecanoe-arg=--output=wof.pmtiles")
+
+	fs.Var(&layers, "layer", "Zero or more layer names. If present features are written to a named pipe for each layer (passed to tippecanoe as -L {LAYER}:{PIPE}) rather than to tippecanoe's STDIN.")
+	fs.StringVar(&layer_property, "layer-property", "", "The property whose value is the name of the layer a feature is written to. Required if -layer is present.")
+	return fs
+}
@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+)
+
+func Run(ctx context.Context) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
+
+	flagset.Parse(fs)
+
+	err := flagset.SetFlagsFromEnvVars(fs, "WOF")
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign flags from environment variables, %w", err)
+	}
+
+	switch format {
+	case "text", "json":
+		// pass
+	default:
+		return fmt.Errorf("Invalid -format flag, %s", format)
+	}
+
+	args := fs.Args()
+
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: diff [options] previous current")
+	}
+
+	var diffs iter.Seq2[*tippecanoe.FeatureDiff, error]
+
+	if manifests {
+
+		prev_m, err := readManifest(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		curr_m, err := readManifest(args[1])
+
+		if err != nil {
+			return err
+		}
+
+		diffs = func(yield func(*tippecanoe.FeatureDiff, error) bool) {
+
+			for _, d := range tippecanoe.DiffManifests(prev_m, curr_m) {
+
+				if !yield(d, nil) {
+					return
+				}
+			}
+		}
+
+	} else {
+
+		prev_fh, err := os.Open(args[0])
+
+		if err != nil {
+			return fmt.Errorf("Failed to open %s, %w", args[0], err)
+		}
+
+		defer prev_fh.Close()
+
+		curr_fh, err := os.Open(args[1])
+
+		if err != nil {
+			return fmt.Errorf("Failed to open %s, %w", args[1], err)
+		}
+
+		defer curr_fh.Close()
+
+		diffs = tippecanoe.DiffFeatures(ctx, prev_fh, bufio.NewReader(curr_fh))
+	}
+
+	var changes_wr *bufio.Writer
+
+	if changes != "" {
+
+		changes_fh, err := os.Create(changes)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create %s, %w", changes, err)
+		}
+
+		defer changes_fh.Close()
+
+		changes_wr = bufio.NewWriter(changes_fh)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	counts := map[string]int{
+		tippecanoe.DIFF_ADDED:    0,
+		tippecanoe.DIFF_REMOVED:  0,
+		tippecanoe.DIFF_MODIFIED: 0,
+	}
+
+	for d, err := range diffs {
+
+		if err != nil {
+			return fmt.Errorf("Failed to diff features, %w", err)
+		}
+
+		counts[d.Change] += 1
+
+		err = writeDiff(out, d)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write change for %s, %w", d.Id, err)
+		}
+
+		if changes_wr != nil && len(d.Body) > 0 {
+
+			err := writeChange(changes_wr, d)
+
+			if err != nil {
+				return fmt.Errorf("Failed to write %s to %s, %w", d.Id, changes, err)
+			}
+		}
+	}
+
+	if changes_wr != nil {
+
+		err := changes_wr.Flush()
+
+		if err != nil {
+			return fmt.Errorf("Failed to flush %s, %w", changes, err)
+		}
+	}
+
+	slog.Info("Changes", "added", counts[tippecanoe.DIFF_ADDED], "removed", counts[tippecanoe.DIFF_REMOVED], "modified", counts[tippecanoe.DIFF_MODIFIED])
+	return nil
+}
+
+func writeDiff(wr io.Writer, d *tippecanoe.FeatureDiff) error {
+
+	if format == "json" {
+
+		enc := json.NewEncoder(wr)
+		return enc.Encode(d)
+	}
+
+	details := make([]string, 0)
+
+	if d.Geometry {
+		details = append(details, "geometry")
+	}
+
+	if len(d.Properties) > 0 {
+		details = append(details, fmt.Sprintf("properties=%s", strings.Join(d.Properties, ",")))
+	}
+
+	_, err := fmt.Fprintf(wr, "%s\t%s\t%s\n", d.Change, d.Id, strings.Join(details, " "))
+	return err
+}
+
+func writeChange(wr io.Writer, d *tippecanoe.FeatureDiff) error {
+
+	var buf bytes.Buffer
+
+	err := json.Compact(&buf, d.Body)
+
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString("\n")
+
+	_, err = wr.Write(buf.Bytes())
+	return err
+}
+
+func readManifest(path string) (*tippecanoe.Manifest, error) {
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	var m *tippecanoe.Manifest
+
+	err = json.Unmarshal(body, &m)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal %s, %w", path, err)
+	}
+
+	if m.Features == nil {
+		return nil, fmt.Errorf("%s is not a valid manifest", path)
+	}
+
+	return m, nil
+}
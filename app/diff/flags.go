@@ -0,0 +1,21 @@
+package diff
+
+import (
+	"flag"
+
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+var format string
+var changes string
+var manifests bool
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("diff")
+
+	fs.StringVar(&format, "format", "text", "The format for reporting changes. Valid options are: text (tab-separated), json (one JSON object per line).")
+	fs.StringVar(&changes, "changes", "", "If not empty, the path where the current versions of added and modified features will be written as JSON-L.")
+	fs.BoolVar(&manifests, "manifests", false, "Treat inputs as manifests produced by the features -manifest flag rather than JSON-L or FeatureCollection outputs.")
+	return fs
+}
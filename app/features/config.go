@@ -0,0 +1,352 @@
+package features
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The key in a config file containing the list of paths (or URIs) to iterate over. All other keys are flag names.
+const CONFIG_SOURCES string = "sources"
+
+// The section of a config file describing the iterator URI ("uri") and the paths to iterate over ("sources").
+const CONFIG_SECTION_ITERATOR string = "iterator"
+
+// The section of a config file whose keys are the names of the flags used to select which records are emitted.
+const CONFIG_SECTION_FILTERS string = "filters"
+
+// The section of a config file describing SPR output: whether it is enabled ("enabled") and the properties to append to it ("append_properties").
+const CONFIG_SECTION_SPR string = "spr"
+
+// The section of a config file containing the list of transformer URIs to apply.
+const CONFIG_SECTION_TRANSFORMS string = "transforms"
+
+// The section of a config file containing the list of writer URIs to write to.
+const CONFIG_SECTION_WRITERS string = "writers"
+
+// config_filter_flags are the names of the flags which may be defined in the "filters" section of a config file.
+var config_filter_flags = []string{
+	"require-polygons",
+	"include-alt-files",
+	"geometry-type",
+	"exclude-geometry-type",
+	"since",
+	"valid-at",
+	"valid-between",
+	"include-id",
+	"exclude-id",
+	"placetype",
+	"exclude-placetype",
+	"placetype-descendants-of",
+	"placetype-ancestors-of",
+}
+
+// configEntry is a struct mapping a key in a config file to the flag it defines.
+type configEntry struct {
+	// key is the (dotted) path of the key in the config file.
+	key string
+	// name is the name of the flag, or `CONFIG_SOURCES`, defined by the key.
+	name  string
+	value interface{}
+}
+
+// applyConfig reads the JSON or YAML file at 'path' and assigns its values to any flags in 'fs' which have not
+// already been set (either on the command line or from environment variables) so that flags always override config
+// values. The file may contain "iterator", "filters", "spr", "transforms" and "writers" sections and any other flag
+// as a top-level key. Keys are flag names (hyphens and underscores are interchangeable). The list of paths to iterate
+// over, defined by "iterator.sources" (or a top-level "sources" key), is returned.
+func applyConfig(fs *flag.FlagSet, path string) ([]string, error) {
+
+	cfg, err := readConfig(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := configEntries(cfg)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid config file %s, %w", path, err)
+	}
+
+	is_set := make(map[string]bool)
+
+	fs.Visit(func(fl *flag.Flag) {
+		is_set[fl.Name] = true
+	})
+
+	sources := make([]string, 0)
+
+	for _, e := range entries {
+
+		if e.name == CONFIG_SOURCES {
+
+			values, err := configValues(e.value)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value for '%s' key in %s, %w", e.key, path, err)
+			}
+
+			sources = append(sources, values...)
+			continue
+		}
+
+		fl := fs.Lookup(e.name)
+
+		if fl == nil || e.name == "config" {
+			return nil, fmt.Errorf("Unknown key '%s' in %s", e.key, path)
+		}
+
+		if is_set[e.name] {
+			continue
+		}
+
+		values, err := configValues(e.value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value for '%s' key in %s, %w", e.key, path, err)
+		}
+
+		if len(values) > 1 && !isMultiFlag(fl) {
+			return nil, fmt.Errorf("Invalid value for '%s' key in %s, expected a single value but found a list", e.key, path)
+		}
+
+		for _, str_v := range values {
+
+			err := fs.Set(e.name, str_v)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value for '%s' key in %s, %w", e.key, path, err)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// ConfigDefinesFlag returns true if the JSON or YAML config file at 'path' defines one or more values for the flag
+// 'name', either as a top-level key or in one of its sections (for example "writers" defines "writer-uri").
+func ConfigDefinesFlag(path string, name string) (bool, error) {
+
+	cfg, err := readConfig(path)
+
+	if err != nil {
+		return false, err
+	}
+
+	entries, err := configEntries(cfg)
+
+	if err != nil {
+		return false, fmt.Errorf("Invalid config file %s, %w", path, err)
+	}
+
+	for _, e := range entries {
+
+		if e.name != name {
+			continue
+		}
+
+		values, err := configValues(e.value)
+
+		if err != nil {
+			return false, fmt.Errorf("Invalid value for '%s' key in %s, %w", e.key, path, err)
+		}
+
+		return len(values) > 0, nil
+	}
+
+	return false, nil
+}
+
+// configEntries returns the list of (flag) entries defined by the sections and top-level keys in 'cfg', sorted by key.
+// It is an error for the same flag to be defined more than once.
+func configEntries(cfg map[string]interface{}) ([]*configEntry, error) {
+
+	entries := make([]*configEntry, 0)
+
+	for k, v := range cfg {
+
+		section := configKey(k)
+
+		switch section {
+		case CONFIG_SECTION_TRANSFORMS, CONFIG_SECTION_WRITERS:
+
+			name := "transform-uri"
+
+			if section == CONFIG_SECTION_WRITERS {
+				name = "writer-uri"
+			}
+
+			entries = append(entries, &configEntry{key: k, name: name, value: v})
+
+		case CONFIG_SECTION_ITERATOR, CONFIG_SECTION_FILTERS, CONFIG_SECTION_SPR:
+
+			m, ok := v.(map[string]interface{})
+
+			if !ok {
+				return nil, fmt.Errorf("'%s' key must be a dictionary", k)
+			}
+
+			for sub_k, sub_v := range m {
+
+				name, ok := configSectionFlag(section, configKey(sub_k))
+
+				if !ok {
+					return nil, fmt.Errorf("Unknown key '%s.%s'", k, sub_k)
+				}
+
+				entries = append(entries, &configEntry{key: fmt.Sprintf("%s.%s", k, sub_k), name: name, value: sub_v})
+			}
+
+		default:
+			entries = append(entries, &configEntry{key: k, name: section, value: v})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	seen := make(map[string]string)
+
+	for _, e := range entries {
+
+		other, exists := seen[e.name]
+
+		if exists {
+			return nil, fmt.Errorf("'%s' and '%s' keys both define '%s'", other, e.key, e.name)
+		}
+
+		seen[e.name] = e.key
+	}
+
+	return entries, nil
+}
+
+// configSectionFlag returns the name of the flag defined by 'key' in the config file section 'section'.
+func configSectionFlag(section string, key string) (string, bool) {
+
+	switch section {
+	case CONFIG_SECTION_ITERATOR:
+
+		switch key {
+		case "uri":
+			return "iterator-uri", true
+		case CONFIG_SOURCES:
+			return CONFIG_SOURCES, true
+		}
+
+	case CONFIG_SECTION_SPR:
+
+		switch key {
+		case "enabled":
+			return "as-spr", true
+		case "append-properties":
+			return "spr-append-property", true
+		}
+
+	case CONFIG_SECTION_FILTERS:
+
+		if slices.Contains(config_filter_flags, key) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// configKey returns 'k' with underscores replaced by hyphens.
+func configKey(k string) string {
+	return strings.ReplaceAll(k, "_", "-")
+}
+
+// readConfig decodes the file at 'path' as YAML if its extension is ".yaml" or ".yml" and as JSON otherwise.
+func readConfig(path string) (map[string]interface{}, error) {
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file, %w", err)
+	}
+
+	cfg := make(map[string]interface{})
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &cfg)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		err = dec.Decode(&cfg)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode config file %s, %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// configValues returns the string representation(s) of 'v' suitable for passing to `flag.FlagSet.Set`.
+func configValues(v interface{}) ([]string, error) {
+
+	switch v := v.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case int:
+		return []string{strconv.Itoa(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case []interface{}:
+
+		values := make([]string, 0, len(v))
+
+		for _, i := range v {
+
+			switch i.(type) {
+			case []interface{}, map[string]interface{}:
+				return nil, fmt.Errorf("Nested lists and dictionaries are not supported")
+			}
+
+			i_values, err := configValues(i)
+
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, i_values...)
+		}
+
+		return values, nil
+	default:
+		return nil, fmt.Errorf("Unsupported type %T", v)
+	}
+}
+
+// isMultiFlag returns true if 'fl' accepts multiple values.
+func isMultiFlag(fl *flag.Flag) bool {
+
+	g, ok := fl.Value.(flag.Getter)
+
+	if !ok {
+		return false
+	}
+
+	return reflect.ValueOf(g.Get()).Kind() == reflect.Slice
+}
@@ -0,0 +1,247 @@
+package features
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// testConfigFlags is a struct containing the values of the flags defined by `testConfigFlagSet`.
+type testConfigFlags struct {
+	config_path      string
+	iterator_uri     string
+	as_spr           bool
+	require_polygons bool
+	tippecanoe_layer string
+	placetypes       multi.MultiCSVString
+	append_props     multi.MultiString
+	transform_uris   multi.MultiString
+	writer_uris      multi.MultiString
+}
+
+// testConfigFlagSet returns a new `flag.FlagSet` defining a subset of the features tool flags, assigned to local
+// variables rather than the package globals, so that each test starts from the default values.
+func testConfigFlagSet() (*flag.FlagSet, *testConfigFlags) {
+
+	v := new(testConfigFlags)
+
+	fs := flagset.NewFlagSet("features")
+	fs.StringVar(&v.config_path, "config", "", "")
+	fs.StringVar(&v.iterator_uri, "iterator-uri", "repo://", "")
+	fs.BoolVar(&v.as_spr, "as-spr", false, "")
+	fs.BoolVar(&v.require_polygons, "require-polygons", false, "")
+	fs.StringVar(&v.tippecanoe_layer, "tippecanoe-layer", "", "")
+	fs.Var(&v.placetypes, "placetype", "")
+	fs.Var(&v.append_props, "spr-append-property", "")
+	fs.Var(&v.transform_uris, "transform-uri", "")
+	fs.Var(&v.writer_uris, "writer-uri", "")
+
+	return fs, v
+}
+
+// writeTestConfig writes 'body' to a file named 'fname' in a temporary directory returning its path.
+func writeTestConfig(t *testing.T, fname string, body string) string {
+
+	path := filepath.Join(t.TempDir(), fname)
+
+	err := os.WriteFile(path, []byte(body), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write config, %v", err)
+	}
+
+	return path
+}
+
+func TestApplyConfigSections(t *testing.T) {
+
+	tests := map[string]string{
+		"config.yaml": `
+iterator:
+  uri: git://
+  sources:
+    - /usr/local/data/a
+    - /usr/local/data/b
+filters:
+  require_polygons: true
+  placetype:
+    - locality
+    - region
+spr:
+  enabled: true
+  append_properties:
+    - wof:hierarchy
+    - wof:concordances
+transforms:
+  - simplify://?tolerance=0.001
+writers:
+  - null://
+tippecanoe_layer: places
+`,
+		"config.json": `{
+  "iterator": {"uri": "git://", "sources": ["/usr/local/data/a", "/usr/local/data/b"]},
+  "filters": {"require-polygons": true, "placetype": ["locality", "region"]},
+  "spr": {"enabled": true, "append-properties": ["wof:hierarchy", "wof:concordances"]},
+  "transforms": ["simplify://?tolerance=0.001"],
+  "writers": ["null://"],
+  "tippecanoe-layer": "places"
+}`,
+		// Flat flag names, as top-level keys, are still supported
+		"flat.yml": `
+iterator_uri: git://
+sources:
+  - /usr/local/data/a
+  - /usr/local/data/b
+require-polygons: true
+placetype: locality,region
+as_spr: true
+spr_append_property:
+  - wof:hierarchy
+  - wof:concordances
+transform_uri: simplify://?tolerance=0.001
+writer-uri:
+  - null://
+tippecanoe_layer: places
+`,
+	}
+
+	for fname, body := range tests {
+
+		fs, v := testConfigFlagSet()
+
+		sources, err := applyConfig(fs, writeTestConfig(t, fname, body))
+
+		if err != nil {
+			t.Fatalf("Failed to apply %s, %v", fname, err)
+		}
+
+		if strings.Join(sources, ",") != "/usr/local/data/a,/usr/local/data/b" {
+			t.Fatalf("Unexpected sources for %s, %v", fname, sources)
+		}
+
+		if v.iterator_uri != "git://" || !v.require_polygons || !v.as_spr || v.tippecanoe_layer != "places" {
+			t.Fatalf("Unexpected flag values for %s, %+v", fname, v)
+		}
+
+		if strings.Join(v.placetypes, ",") != "locality,region" {
+			t.Fatalf("Unexpected placetypes for %s, %v", fname, v.placetypes)
+		}
+
+		if strings.Join(v.append_props, ",") != "wof:hierarchy,wof:concordances" {
+			t.Fatalf("Unexpected SPR properties for %s, %v", fname, v.append_props)
+		}
+
+		if strings.Join(v.transform_uris, ",") != "simplify://?tolerance=0.001" || strings.Join(v.writer_uris, ",") != "null://" {
+			t.Fatalf("Unexpected transform or writer URIs for %s, %v %v", fname, v.transform_uris, v.writer_uris)
+		}
+	}
+}
+
+func TestApplyConfigInvalid(t *testing.T) {
+
+	tests := map[string]string{
+		"unknown key":                 "verbosity: true",
+		"unknown section key":         "filters:\n  foo: bar",
+		"non-filter flag in filters":  "filters:\n  tippecanoe_layer: places",
+		"unknown iterator key":        "iterator:\n  paths:\n    - /usr/local/data",
+		"section is not a dictionary": "spr: true",
+		"duplicate flag":              "writer_uri: null://\nwriters:\n  - stdout://",
+		"duplicate aliased flag":      "iterator_uri: git://\niterator-uri: repo://",
+		"duplicate sources":           "sources:\n  - a\niterator:\n  sources:\n    - b",
+		"list for single value flag":  "tippecanoe_layer:\n  - a\n  - b",
+		"invalid value":               "filters:\n  require_polygons: maybe",
+		"dictionary value":            "tippecanoe_layer:\n  name: places",
+		"config key":                  "config: other.yaml",
+	}
+
+	for label, body := range tests {
+
+		fs, _ := testConfigFlagSet()
+
+		_, err := applyConfig(fs, writeTestConfig(t, "config.yaml", body))
+
+		if err == nil {
+			t.Fatalf("Expected config with %s to fail", label)
+		}
+	}
+
+	fs, _ := testConfigFlagSet()
+
+	_, err := applyConfig(fs, writeTestConfig(t, "config.yaml", "filters:\n  foo: bar"))
+
+	if err == nil || !strings.Contains(err.Error(), "'filters.foo'") {
+		t.Fatalf("Expected error to name the unknown section key, %v", err)
+	}
+
+	_, err = applyConfig(fs, writeTestConfig(t, "config.txt", "tippecanoe_layer: places"))
+
+	if err == nil {
+		t.Fatalf("Expected config with unsupported extension to fail")
+	}
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+
+	body := `
+iterator:
+  uri: git://
+filters:
+  require_polygons: true
+spr:
+  append_properties:
+    - wof:hierarchy
+writers:
+  - null://
+tippecanoe_layer: places
+`
+
+	path := writeTestConfig(t, "config.yaml", body)
+
+	fs, v := testConfigFlagSet()
+
+	// Values assigned on the command line...
+
+	err := fs.Parse([]string{"-require-polygons=false", "-writer-uri", "stdout://"})
+
+	if err != nil {
+		t.Fatalf("Failed to parse flags, %v", err)
+	}
+
+	// ... and from environment variables override config values
+
+	t.Setenv("WOF_TIPPECANOE_LAYER", "env")
+	t.Setenv("WOF_SPR_APPEND_PROPERTY", "wof:concordances")
+
+	err = flagset.SetFlagsFromEnvVars(fs, "WOF")
+
+	if err != nil {
+		t.Fatalf("Failed to assign flags from environment variables, %v", err)
+	}
+
+	_, err = applyConfig(fs, path)
+
+	if err != nil {
+		t.Fatalf("Failed to apply config, %v", err)
+	}
+
+	if v.require_polygons {
+		t.Fatalf("Expected -require-polygons flag to override config")
+	}
+
+	if strings.Join(v.writer_uris, ",") != "stdout://" {
+		t.Fatalf("Expected -writer-uri flag to replace config writers, %v", v.writer_uris)
+	}
+
+	if v.tippecanoe_layer != "env" || strings.Join(v.append_props, ",") != "wof:concordances" {
+		t.Fatalf("Expected environment variables to override config, %s %v", v.tippecanoe_layer, v.append_props)
+	}
+
+	if v.iterator_uri != "git://" {
+		t.Fatalf("Expected config to assign unset flags, %s", v.iterator_uri)
+	}
+}
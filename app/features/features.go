@@ -2,13 +2,19 @@ package features
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/sfomuseum/go-flags/flagset"
 	"github.com/sfomuseum/go-flags/lookup"
+	"github.com/sfomuseum/go-flags/multi"
 	"github.com/whosonfirst/go-whosonfirst-iterwriter/v4/app/iterwriter"
 	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+	"github.com/whosonfirst/go-writer/v3"
 )
 
 func Run(ctx context.Context) error {
@@ -18,12 +24,30 @@ func Run(ctx context.Context) error {
 
 func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 
-	flagset.Parse(fs)
+	// The flagset may have already been parsed by a wrapping application (for example app/build)
 
-	err := flagset.SetFlagsFromEnvVars(fs, "WOF")
+	if !fs.Parsed() {
 
-	if err != nil {
-		return fmt.Errorf("Failed to assign flags from environment variables, %w", err)
+		flagset.Parse(fs)
+
+		err := flagset.SetFlagsFromEnvVars(fs, "WOF")
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign flags from environment variables, %w", err)
+		}
+	}
+
+	var config_sources []string
+
+	if config_path != "" {
+
+		sources, err := applyConfig(fs, config_path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to apply config, %w", err)
+		}
+
+		config_sources = sources
 	}
 
 	opts, err := iterwriter.DefaultOptionsFromFlagSet(fs, true)
@@ -32,6 +56,10 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 		return fmt.Errorf("Failed to derive default options from flags, %w", err)
 	}
 
+	if len(opts.IteratorPaths) == 0 {
+		opts.IteratorPaths = config_sources
+	}
+
 	forgiving, err := lookup.BoolVar(fs, "forgiving")
 
 	if err != nil {
@@ -39,14 +67,117 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 	}
 
 	cb_opts := &tippecanoe.IterwriterCallbackFuncBuilderOptions{
-		AsSPR:               as_spr,
-		RequirePolygon:      require_polygons,
-		IncludeAltFiles:     include_alt_files,
-		AppendSPRProperties: spr_properties,
-		Forgiving:           forgiving,
+		AsSPR:                      as_spr,
+		RequirePolygon:             require_polygons,
+		IncludeAltFiles:            include_alt_files,
+		AppendSPRProperties:        spr_properties,
+		Forgiving:                  forgiving,
+		EDTFAttributes:             edtf_attributes,
+		Concordances:               concordances,
+		GeometryMetrics:            geometry_metrics,
+		CoerceGeometryCollections:  coerce_geometry_collections,
+		ExplodeGeometryCollections: explode_geometry_collections,
+		ExplodeMultiPolygons:       explode_multipolygons,
+	}
+
+	if !isValidEDTFAttributes(edtf_attributes) {
+		return fmt.Errorf("Invalid -edtf-attributes flag, %s", edtf_attributes)
+	}
+
+	switch geometry_metrics {
+	case "", tippecanoe.METRICS_UNITS_METRES, tippecanoe.METRICS_UNITS_KILOMETRES:
+		// pass
+	default:
+		return fmt.Errorf("Invalid -geometry-metrics flag, %s", geometry_metrics)
+	}
+
+	if since != "" {
+
+		s, err := tippecanoe.ParseSince(ctx, since, opts.IteratorPaths...)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse -since flag, %w", err)
+		}
+
+		defer s.Close()
+
+		cb_opts.Since = s
+	}
+
+	err = tippecanoe.ApplySelectionOptions(cb_opts, selection_flags.SelectionOptions())
+
+	if err != nil {
+		return fmt.Errorf("Invalid flags, %w", err)
+	}
+
+	if len(profile_uris) > 0 {
+		return runProfiles(ctx, fs, opts, cb_opts)
+	}
+
+	budget, err := newPropertyBudget(ctx, max_property_bytes, max_value_bytes, truncate_properties...)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create property budget, %w", err)
+	}
+
+	if truncation_report != "" && budget == nil {
+		return fmt.Errorf("-truncation-report flag requires one or more of the -max-property-bytes, -max-value-bytes or -truncate-property flags")
+	}
+
+	cb_opts.PropertyBudget = budget
+
+	var close_hooks []func(context.Context) error
+
+	if dedupe != "" {
+
+		dd, err := newDeduplicator(ctx, dedupe, dedupe_path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create deduplicator, %w", err)
+		}
+
+		defer dd.Close()
+
+		cb_opts.Deduplicator = dd
+		close_hooks = append(close_hooks, dd.Flush)
+	}
+
+	var manifest_wr *tippecanoe.ManifestWriter
+	var command_wr *tippecanoe.CommandWriter
+	var commits map[string]string
+
+	if len(close_hooks) > 0 || manifest != "" || tippecanoe_command != "" {
+
+		wr, err := writerFromFlagSet(ctx, fs)
+
+		if err != nil {
+			return err
+		}
+
+		if manifest != "" {
+			manifest_wr = tippecanoe.NewManifestWriter(wr)
+			commits = tippecanoe.GitCommits(ctx, opts.IteratorPaths...)
+			wr = manifest_wr
+		}
+
+		if tippecanoe_command != "" {
+			command_wr = tippecanoe.NewCommandWriter(wr)
+			wr = command_wr
+		}
+
+		opts.Writer = &closeHookWriter{
+			Writer: wr,
+			hooks:  close_hooks,
+		}
+	}
+
+	p, err := tippecanoe.DefaultPipelineWithTransformers(ctx, cb_opts, transform_uris...)
+
+	if err != nil {
+		return err
 	}
 
-	cb_func := tippecanoe.IterwriterCallbackFuncBuilder(cb_opts)
+	cb_func := tippecanoe.IterwriterCallbackFuncBuilderWithPipeline(p, cb_opts)
 
 	opts.CallbackFunc = cb_func
 
@@ -56,5 +187,350 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 		return fmt.Errorf("Failed to run iterwriter, %v", err)
 	}
 
+	if manifest_wr != nil {
+
+		m := manifest_wr.Manifest(ctx, opts.IteratorURI, opts.IteratorPaths...)
+		m.Commits = commits
+		m.Options = flagsToOptions(fs)
+
+		err := writeManifest(manifest, m)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write manifest, %w", err)
+		}
+	}
+
+	if truncation_report != "" {
+
+		err := writeTruncationReport(truncation_report, budget.Report())
+
+		if err != nil {
+			return fmt.Errorf("Failed to write truncation report, %w", err)
+		}
+	}
+
+	if command_wr != nil {
+
+		err := writeCommand(tippecanoe_command, command_wr)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write tippecanoe command, %w", err)
+		}
+	}
+
+	return nil
+}
+
+// runProfiles processes each record emitted by the iterator defined in 'opts' once for each of the -profile flags,
+// using 'cb_opts' as the default options for each profile.
+func runProfiles(ctx context.Context, fs *flag.FlagSet, opts *iterwriter.RunOptions, cb_opts *tippecanoe.IterwriterCallbackFuncBuilderOptions) error {
+
+	if manifest != "" {
+		return fmt.Errorf("-manifest flag is not supported with -profile flags, use the ?manifest= parameter instead")
+	}
+
+	if truncation_report != "" {
+		return fmt.Errorf("-truncation-report flag is not supported with -profile flags, use the ?truncation-report= parameter instead")
+	}
+
+	if tippecanoe_command != "" {
+		return fmt.Errorf("-tippecanoe-command flag is not supported with -profile flags, use the ?tippecanoe-command= parameter instead")
+	}
+
+	v, err := lookup.Lookup(fs, "writer-uri")
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive writer URIs, %w", err)
+	}
+
+	if len(v.(multi.MultiCSVString)) > 0 {
+		return fmt.Errorf("-writer-uri flag is not supported with -profile flags, use the ?writer-uri= parameter instead")
+	}
+
+	app_profiles := make([]*appProfile, 0, len(profile_uris))
+	profiles := make([]*tippecanoe.Profile, 0, len(profile_uris))
+	close_hooks := make([]func(context.Context) error, 0, len(profile_uris))
+
+	seen := make(map[string]bool)
+
+	for _, uri := range profile_uris {
+
+		ap, err := newAppProfile(ctx, uri, cb_opts)
+
+		if err != nil {
+			closeAppProfiles(ctx, app_profiles)
+			return err
+		}
+
+		if ap.deduplicator != nil {
+			defer ap.deduplicator.Close()
+		}
+
+		if seen[ap.profile.Name] {
+			closeAppProfiles(ctx, append(app_profiles, ap))
+			return fmt.Errorf("Duplicate profile name '%s'", ap.profile.Name)
+		}
+
+		seen[ap.profile.Name] = true
+
+		app_profiles = append(app_profiles, ap)
+		profiles = append(profiles, ap.profile)
+		close_hooks = append(close_hooks, ap.Close)
+	}
+
+	var commits map[string]string
+
+	for _, ap := range app_profiles {
+
+		if ap.manifest_wr != nil {
+			commits = tippecanoe.GitCommits(ctx, opts.IteratorPaths...)
+			break
+		}
+	}
+
+	null_wr, err := writer.NewNullWriter(ctx, "null://")
+
+	if err != nil {
+		closeAppProfiles(ctx, app_profiles)
+		return fmt.Errorf("Failed to create null writer, %w", err)
+	}
+
+	opts.Writer = &closeHookWriter{
+		Writer: null_wr,
+		hooks:  close_hooks,
+	}
+
+	opts.CallbackFunc = tippecanoe.ProfilesCallbackFuncBuilder(profiles...)
+
+	err = iterwriter.RunWithOptions(ctx, opts)
+
+	if err != nil {
+		return fmt.Errorf("Failed to run iterwriter, %v", err)
+	}
+
+	for _, ap := range app_profiles {
+
+		if ap.manifest_wr == nil {
+			continue
+		}
+
+		m := ap.manifest_wr.Manifest(ctx, opts.IteratorURI, opts.IteratorPaths...)
+		m.Commits = commits
+		m.Options = flagsToOptions(fs)
+		// Profile parameters override flags so record the profile URI as well
+		m.Options["profile"] = ap.uri
+
+		err := writeManifest(ap.manifest_path, m)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write manifest for profile '%s', %w", ap.profile.Name, err)
+		}
+	}
+
+	for _, ap := range app_profiles {
+
+		if ap.report_path == "" {
+			continue
+		}
+
+		err := writeTruncationReport(ap.report_path, ap.budget.Report())
+
+		if err != nil {
+			return fmt.Errorf("Failed to write truncation report for profile '%s', %w", ap.profile.Name, err)
+		}
+	}
+
+	for _, ap := range app_profiles {
+
+		if ap.command_wr == nil {
+			continue
+		}
+
+		err := writeCommand(ap.command_path, ap.command_wr)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write tippecanoe command for profile '%s', %w", ap.profile.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// isValidEDTFAttributes returns true if 'format' is a valid value for the -edtf-attributes flag (or an empty string).
+func isValidEDTFAttributes(format string) bool {
+
+	switch format {
+	case "", tippecanoe.EDTF_FORMAT_UNIX, tippecanoe.EDTF_FORMAT_YEAR:
+		return true
+	default:
+		return false
+	}
+}
+
+// newDeduplicator returns a new `tippecanoe.Deduplicator` instance for 'policy', storing state in 'path' if not empty,
+// configured by the other -dedupe flags.
+func newDeduplicator(ctx context.Context, policy string, path string) (*tippecanoe.Deduplicator, error) {
+
+	dd_opts := &tippecanoe.DeduplicatorOptions{
+		Policy:     policy,
+		PreferRepo: dedupe_prefer_repo,
+		Path:       path,
+	}
+
+	return tippecanoe.NewDeduplicator(ctx, dd_opts)
+}
+
+// newPropertyBudget returns a new `tippecanoe.PropertyBudget` instance for 'max_bytes', 'max_value_bytes' and zero or more
+// {PROPERTY}={BYTES} 'rules' or nil if none of them are defined.
+func newPropertyBudget(ctx context.Context, max_bytes int, max_value_bytes int, rules ...string) (*tippecanoe.PropertyBudget, error) {
+
+	if max_bytes == 0 && max_value_bytes == 0 && len(rules) == 0 {
+		return nil, nil
+	}
+
+	budget_opts := &tippecanoe.PropertyBudgetOptions{
+		MaxBytes:      max_bytes,
+		MaxValueBytes: max_value_bytes,
+		Rules:         make(map[string]int),
+	}
+
+	for _, str_rule := range rules {
+
+		k, v, err := tippecanoe.ParsePropertyBudgetRule(str_rule)
+
+		if err != nil {
+			return nil, err
+		}
+
+		budget_opts.Rules[k] = v
+	}
+
+	return tippecanoe.NewPropertyBudget(ctx, budget_opts)
+}
+
+// flagsToOptions returns a dictionary of the (effective) value of every flag in 'fs'.
+func flagsToOptions(fs *flag.FlagSet) map[string]string {
+
+	options := make(map[string]string)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		options[f.Name] = f.Value.String()
+	})
+
+	return options
+}
+
+func writeCommand(path string, command_wr *tippecanoe.CommandWriter) error {
+
+	cmd_opts := &tippecanoe.CommandOptions{
+		Inputs: make(map[string]string),
+	}
+
+	for _, l := range tippecanoe_layers {
+
+		name, path, ok := strings.Cut(l, ":")
+
+		if ok {
+			cmd_opts.Inputs[name] = path
+		} else {
+			cmd_opts.Layer = name
+		}
+	}
+
+	args := command_wr.Command(cmd_opts)
+
+	var buf strings.Builder
+
+	untyped := command_wr.UntypedAttributes()
+
+	if len(untyped) > 0 {
+
+		names := make([]string, 0, len(untyped))
+
+		for name := range untyped {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		buf.WriteString("# The types of the following attributes can not be passed to tippecanoe using the -T flag because their names contain a ':'\n")
+
+		for _, name := range names {
+			fmt.Fprintf(&buf, "# %s:%s\n", name, untyped[name])
+		}
+
+		rename_uri := command_wr.RenameTransformerURI()
+
+		if rename_uri != "" {
+
+			renamed := command_wr.RenamedAttributes()
+
+			buf.WriteString("#\n")
+			buf.WriteString("# To type them, rename them by running the features tool again with the following flag. The command it writes will then include these -T arguments:\n")
+			fmt.Fprintf(&buf, "# -transform-uri %s", tippecanoe.CommandString([]string{rename_uri}))
+
+			for _, name := range names {
+
+				new_name, ok := renamed[name]
+
+				if ok {
+					fmt.Fprintf(&buf, "# -T %s:%s\n", new_name, untyped[name])
+				}
+			}
+		}
+
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString(tippecanoe.CommandString(args))
+
+	err := os.WriteFile(path, []byte(buf.String()), 0644)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write %s, %w", path, err)
+	}
+
 	return nil
 }
+
+func writeTruncationReport(path string, r *tippecanoe.PropertyBudgetReport) error {
+
+	fh, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create %s, %w", path, err)
+	}
+
+	enc := json.NewEncoder(fh)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(r)
+
+	if err != nil {
+		fh.Close()
+		return fmt.Errorf("Failed to encode truncation report, %w", err)
+	}
+
+	return fh.Close()
+}
+
+func writeManifest(path string, m *tippecanoe.Manifest) error {
+
+	fh, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create %s, %w", path, err)
+	}
+
+	enc := json.NewEncoder(fh)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(m)
+
+	if err != nil {
+		fh.Close()
+		return fmt.Errorf("Failed to encode manifest, %w", err)
+	}
+
+	return fh.Close()
+}
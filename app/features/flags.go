@@ -5,22 +5,90 @@ import (
 	"github.com/sfomuseum/go-flags/multi"
 
 	"github.com/whosonfirst/go-whosonfirst-iterwriter/v4/app/iterwriter"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
 )
 
+var config_path string
+
 var as_spr bool
 var require_polygons bool
 var include_alt_files bool
 
+var coerce_geometry_collections bool
+var explode_geometry_collections bool
+var explode_multipolygons bool
+
 var spr_properties multi.MultiCSVString
 
+var edtf_attributes string
+
+var concordances multi.MultiCSVString
+
+var geometry_metrics string
+
+var transform_uris multi.MultiString
+
+var profile_uris multi.MultiString
+
+var since string
+
+var selection_flags tippecanoe.SelectionFlags
+
+var max_property_bytes int
+var max_value_bytes int
+var truncate_properties multi.MultiString
+var truncation_report string
+
+var manifest string
+
+var tippecanoe_command string
+var tippecanoe_layers multi.MultiString
+
+var dedupe string
+var dedupe_prefer_repo string
+var dedupe_path string
+
 func DefaultFlagSet() *flag.FlagSet {
 
 	fs := iterwriter.DefaultFlagSet()
 
+	fs.StringVar(&config_path, "config", "", "An optional path to a JSON or YAML (.yaml or .yml) file with optional \"iterator\", \"filters\", \"spr\", \"transforms\" and \"writers\" sections whose other keys are the names of flags. See the README for details. Flags, and environment variables, override config values.")
+
 	fs.BoolVar(&as_spr, "as-spr", true, "Replace Feature properties with Who's On First Standard Places Result (SPR) derived from that feature.")
 	fs.BoolVar(&require_polygons, "require-polygons", false, "Require that geometry type be 'Polygon' or 'MultiPolygon' to be included in output.")
+	fs.BoolVar(&coerce_geometry_collections, "coerce-geometry-collections", false, "Replace GeometryCollections with their polygonal parts (as a Polygon or MultiPolygon) before geometry types are tested. Collections without polygonal parts are left unchanged.")
+	fs.BoolVar(&explode_geometry_collections, "explode-geometry-collections", false, "Replace GeometryCollections with a feature for each member geometry, with part_index and part_key properties and a tippecanoe.layer value of points, lines or polygons, before geometry types are tested. Collections are exploded before -coerce-geometry-collections is applied.")
+	fs.BoolVar(&explode_multipolygons, "explode-multipolygons", false, "Replace MultiPolygons (including the members of exploded GeometryCollections) with a feature for each polygon, with part_index and part_key properties, before geometry types are tested. Polygons stay in the default layer.")
 	fs.BoolVar(&include_alt_files, "include-alt-files", false, "Include alternate geometry files in output.")
 
 	fs.Var(&spr_properties, "spr-append-property", "Zero or more properties in a given feature to append to SPR output")
+
+	fs.StringVar(&edtf_attributes, "edtf-attributes", "", "If not empty, add numeric inception_lower, inception_upper, cessation_lower and cessation_upper properties derived from the edtf:inception and edtf:cessation properties encoded using this format. Valid options are: unix, year.")
+
+	fs.Var(&concordances, "concordance", "Zero or more concordance prefixes (for example wd or gn) or keys (for example wd:id) whose values in the wof:concordances property should be copied in to flat properties (for example wd_id or gn_id).")
+
+	fs.StringVar(&geometry_metrics, "geometry-metrics", "", "If not empty, add geodesic area (area_{UNITS}2), perimeter (perimeter_{UNITS}) and vertex count (vertices) properties derived from each feature's geometry using these units. Valid options are: m, km.")
+
+	fs.Var(&transform_uris, "transform-uri", "Zero or more registered transformer URIs (for example simplify://?tolerance=0.001 or rename://?from=a&to=b) to apply to each feature, in order, after all other processing.")
+
+	fs.Var(&profile_uris, "profile", "Zero or more named output profiles, in the form of profile://{NAME}?writer-uri={URI}&{PARAMETERS}, to produce in a single pass. Valid parameters are: as-spr, require-polygons, geometry-type, exclude-geometry-type, coerce-geometry-collections, explode-geometry-collections, explode-multipolygons, include-alt-files, spr-append-property, edtf-attributes, concordance, geometry-metrics, points-placetype, valid-at, valid-between, include-id, exclude-id, placetype, exclude-placetype, placetype-descendants-of, placetype-ancestors-of, max-property-bytes, max-value-bytes, truncate-property, truncation-report, transform-uri, dedupe, manifest, tippecanoe-command; unspecified parameters are inherited from their corresponding flags. The -since flag applies to every profile and can not be overridden. Writer URIs containing their own query parameters must be URL-encoded.")
+
+	fs.StringVar(&since, "since", "", "If not empty, only emit records modified since this value. Valid options are a Unix timestamp or an ISO-8601 date (compared against wof:lastmodified) or a Git commit hash (compared against the files changed between that commit and HEAD in each of the repositories being iterated, skipping repositories where the commit does not exist). All-digit values with fewer than nine digits are treated as YYYYMMDD dates or commits; use a unix: or commit: prefix to be explicit.")
+
+	tippecanoe.AppendSelectionFlags(fs, &selection_flags)
+
+	fs.IntVar(&max_property_bytes, "max-property-bytes", 0, "If greater than zero, the maximum size in bytes of the (JSON-encoded) properties of each feature. The largest properties (other than wof:id, wof:name, wof:placetype and wof:parent_id) are truncated, or removed, until the properties fit.")
+	fs.IntVar(&max_value_bytes, "max-value-bytes", 0, "If greater than zero, the maximum size in bytes of any one (JSON-encoded) property value. Strings are shortened, arrays have trailing elements removed and all other values are removed.")
+	fs.Var(&truncate_properties, "truncate-property", "Zero or more {PROPERTY}={BYTES} rules defining the maximum size in bytes of the (JSON-encoded) value of a specific property, overriding -max-value-bytes. For example: wof:belongsto=256.")
+	fs.StringVar(&truncation_report, "truncation-report", "", "If not empty, the path where a JSON report listing the properties truncated or removed from each feature by the -max-property-bytes, -max-value-bytes and -truncate-property flags will be written.")
+
+	fs.StringVar(&manifest, "manifest", "", "If not empty, the path where a JSON manifest describing the options, inputs (including Git commits) and SHA-256 hashes of each feature emitted will be written.")
+
+	fs.StringVar(&tippecanoe_command, "tippecanoe-command", "", "If not empty, the path where a recommended tippecanoe command (derived from the features emitted) will be written. The command includes attribute type hints, layer arguments, --use-attribute-for-id and zoom levels suggested from the extent, sizes and density of the features.")
+	fs.Var(&tippecanoe_layers, "tippecanoe-layer", "Zero or more layers to include in the recommended tippecanoe command. Values may be a layer name (-l) or {LAYER}:{PATH} for a named layer read from a file (-L).")
+
+	fs.StringVar(&dedupe, "dedupe", "", "If not empty, ensure that only one record for a given ID is emitted using this policy. Valid options are: first, lastmodified, repo. The lastmodified and repo policies retain every record (in memory, or on disk if -dedupe-path is set) until iteration is complete.")
+	fs.StringVar(&dedupe_prefer_repo, "dedupe-prefer-repo", "", "The name of the repository whose records should be preferred when -dedupe=repo.")
+	fs.StringVar(&dedupe_path, "dedupe-path", "", "An optional directory used to store deduplication state on disk rather than in memory. State includes a sparse ID bitmap file which may grow to an apparent size of 256MB, and any records retained by the lastmodified and repo policies along with the details used to compare them. Profiles store their state in a subdirectory named after the profile.")
 	return fs
 }
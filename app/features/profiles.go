@@ -0,0 +1,342 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// appProfile is a struct wrapping a `tippecanoe.Profile` instance and the application-specific details
+// (deduplication, manifests, truncation reports) necessary to produce its output.
+type appProfile struct {
+	uri           string
+	profile       *tippecanoe.Profile
+	manifest_path string
+	manifest_wr   *tippecanoe.ManifestWriter
+	command_path  string
+	command_wr    *tippecanoe.CommandWriter
+	deduplicator  *tippecanoe.Deduplicator
+	budget        *tippecanoe.PropertyBudget
+	report_path   string
+}
+
+// newAppProfile returns a new `appProfile` instance derived from 'uri' which takes the form of:
+//
+//	profile://{NAME}?writer-uri={URI}&{PARAMETERS}
+//
+// Where {URI} is one or more (gocloud.dev/runtimevar encoded) whosonfirst/go-writer/v3 URIs and {PARAMETERS} are zero or
+// more of the following, each of which override the values derived from 'defaults' and the dedupe flags: as-spr,
+// require-polygons, geometry-type, exclude-geometry-type, coerce-geometry-collections, explode-geometry-collections,
+// explode-multipolygons, include-alt-files, spr-append-property, edtf-attributes, concordance, geometry-metrics,
+// points-placetype, valid-at, valid-between, include-id, exclude-id, placetype, exclude-placetype, placetype-descendants-of, placetype-ancestors-of,
+// max-property-bytes, max-value-bytes, truncate-property, truncation-report, transform-uri, dedupe, manifest and tippecanoe-command. The -tippecanoe-command flag is not inherited
+// since each profile produces its own output; use the tippecanoe-command parameter instead. The -since flag can not be overridden and applies to
+// every profile since it is resolved once, against the iterator sources, before iteration begins. Writer URIs containing their own query parameters must be URL-encoded.
+func newAppProfile(ctx context.Context, uri string, defaults *tippecanoe.IterwriterCallbackFuncBuilderOptions) (*appProfile, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse profile URI, %w", err)
+	}
+
+	if u.Scheme != "profile" {
+		return nil, fmt.Errorf("Invalid scheme for profile URI '%s'", uri)
+	}
+
+	name := u.Host
+
+	if name == "" {
+		return nil, fmt.Errorf("Profile URI '%s' is missing a name", uri)
+	}
+
+	cb_opts := *defaults
+
+	profile_transform_uris := []string(transform_uris)
+	profile_dedupe := dedupe
+
+	sel_opts := selection_flags.SelectionOptions()
+
+	profile_max_bytes := max_property_bytes
+	profile_max_value_bytes := max_value_bytes
+	profile_truncate := []string(truncate_properties)
+
+	var profile_valid_at bool
+	var profile_valid_between bool
+
+	var profile_writer_uris []string
+	var profile_report string
+	var profile_manifest string
+	var profile_command string
+
+	for k, values := range u.Query() {
+
+		switch k {
+		case "writer-uri":
+			profile_writer_uris = values
+		case "transform-uri":
+			profile_transform_uris = values
+		case "spr-append-property":
+			cb_opts.AppendSPRProperties = splitValues(values)
+		case "edtf-attributes":
+
+			if !isValidEDTFAttributes(values[0]) {
+				return nil, fmt.Errorf("Invalid ?%s= parameter for profile '%s', %s", k, name, values[0])
+			}
+
+			cb_opts.EDTFAttributes = values[0]
+
+		case "concordance":
+			cb_opts.Concordances = splitValues(values)
+		case "geometry-metrics":
+
+			switch values[0] {
+			case "", tippecanoe.METRICS_UNITS_METRES, tippecanoe.METRICS_UNITS_KILOMETRES:
+				cb_opts.GeometryMetrics = values[0]
+			default:
+				return nil, fmt.Errorf("Invalid ?%s= parameter for profile '%s', %s", k, name, values[0])
+			}
+
+		case "geometry-type":
+			sel_opts.GeometryTypes = splitValues(values)
+		case "exclude-geometry-type":
+			sel_opts.ExcludeGeometryTypes = splitValues(values)
+		case "points-placetype":
+			sel_opts.PointPlacetypes = splitValues(values)
+		case "valid-at":
+			sel_opts.ValidAt = values[0]
+			profile_valid_at = true
+		case "valid-between":
+			sel_opts.ValidBetween = values[0]
+			profile_valid_between = true
+		case "include-id":
+			sel_opts.IncludeIds = values
+		case "exclude-id":
+			sel_opts.ExcludeIds = values
+		case "max-property-bytes", "max-value-bytes":
+
+			v, err := strconv.Atoi(values[0])
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ?%s= parameter for profile '%s', %w", k, name, err)
+			}
+
+			if k == "max-property-bytes" {
+				profile_max_bytes = v
+			} else {
+				profile_max_value_bytes = v
+			}
+
+		case "truncate-property":
+			profile_truncate = values
+		case "truncation-report":
+			profile_report = values[0]
+		case "placetype":
+			sel_opts.Placetypes = splitValues(values)
+		case "exclude-placetype":
+			sel_opts.ExcludePlacetypes = splitValues(values)
+		case "placetype-descendants-of":
+			sel_opts.PlacetypeDescendantsOf = splitValues(values)
+		case "placetype-ancestors-of":
+			sel_opts.PlacetypeAncestorsOf = splitValues(values)
+		case "as-spr", "require-polygons", "coerce-geometry-collections", "explode-geometry-collections", "explode-multipolygons", "include-alt-files":
+
+			v, err := strconv.ParseBool(values[0])
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ?%s= parameter for profile '%s', %w", k, name, err)
+			}
+
+			switch k {
+			case "as-spr":
+				cb_opts.AsSPR = v
+			case "require-polygons":
+				cb_opts.RequirePolygon = v
+			case "coerce-geometry-collections":
+				cb_opts.CoerceGeometryCollections = v
+			case "explode-geometry-collections":
+				cb_opts.ExplodeGeometryCollections = v
+			case "explode-multipolygons":
+				cb_opts.ExplodeMultiPolygons = v
+			default:
+				cb_opts.IncludeAltFiles = v
+			}
+
+		case "dedupe":
+			profile_dedupe = values[0]
+		case "manifest":
+			profile_manifest = values[0]
+		case "tippecanoe-command":
+			profile_command = values[0]
+		default:
+			return nil, fmt.Errorf("Unknown ?%s= parameter for profile '%s'", k, name)
+		}
+	}
+
+	// A profile's valid-at (or valid-between) parameter replaces the range inherited from either flag since the two are
+	// mutually exclusive. Specifying both parameters is still an error.
+
+	if profile_valid_at && !profile_valid_between {
+		sel_opts.ValidBetween = ""
+	}
+
+	if profile_valid_between && !profile_valid_at {
+		sel_opts.ValidAt = ""
+	}
+
+	err = tippecanoe.ApplySelectionOptions(&cb_opts, sel_opts)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameters for profile '%s', %w", name, err)
+	}
+
+	budget, err := newPropertyBudget(ctx, profile_max_bytes, profile_max_value_bytes, profile_truncate...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create property budget for profile '%s', %w", name, err)
+	}
+
+	if profile_report != "" && budget == nil {
+		return nil, fmt.Errorf("?truncation-report= parameter for profile '%s' requires one or more of the max-property-bytes, max-value-bytes or truncate-property parameters (or flags)", name)
+	}
+
+	// Each profile has its own budget so that trimmed properties are reported per-profile
+
+	cb_opts.PropertyBudget = budget
+
+	if len(profile_writer_uris) == 0 {
+		return nil, fmt.Errorf("Profile '%s' is missing ?writer-uri= parameter", name)
+	}
+
+	var wr writer.Writer
+
+	wr, err = writerFromURIs(ctx, profile_writer_uris...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create writer for profile '%s', %w", name, err)
+	}
+
+	ap := &appProfile{
+		uri:           uri,
+		manifest_path: profile_manifest,
+		command_path:  profile_command,
+		budget:        budget,
+		report_path:   profile_report,
+	}
+
+	if profile_manifest != "" {
+		ap.manifest_wr = tippecanoe.NewManifestWriter(wr)
+		wr = ap.manifest_wr
+	}
+
+	if profile_command != "" {
+		ap.command_wr = tippecanoe.NewCommandWriter(wr)
+		wr = ap.command_wr
+	}
+
+	if profile_dedupe != "" {
+
+		// Each profile stores its deduplication state in its own subdirectory so that profiles don't overwrite
+		// each other's ID bitmap or retained records
+
+		var path string
+
+		if dedupe_path != "" {
+			path = filepath.Join(dedupe_path, name)
+		}
+
+		dd, err := newDeduplicator(ctx, profile_dedupe, path)
+
+		if err != nil {
+			wr.Close(ctx)
+			return nil, fmt.Errorf("Failed to create deduplicator for profile '%s', %w", name, err)
+		}
+
+		ap.deduplicator = dd
+		cb_opts.Deduplicator = dd
+	} else {
+		cb_opts.Deduplicator = nil
+	}
+
+	p, err := tippecanoe.DefaultPipelineWithTransformers(ctx, &cb_opts, profile_transform_uris...)
+
+	if err != nil {
+
+		wr.Close(ctx)
+
+		if ap.deduplicator != nil {
+			ap.deduplicator.Close()
+		}
+
+		return nil, fmt.Errorf("Failed to create pipeline for profile '%s', %w", name, err)
+	}
+
+	ap.profile = &tippecanoe.Profile{
+		Name:     name,
+		Options:  &cb_opts,
+		Pipeline: p,
+		Writer:   wr,
+	}
+
+	return ap, nil
+}
+
+// Close flushes any deduplicated records and then closes the writer for 'ap'. The writer is closed even if
+// flushing deduplicated records fails.
+func (ap *appProfile) Close(ctx context.Context) error {
+
+	errs := make([]error, 0)
+
+	if ap.deduplicator != nil {
+
+		err := ap.deduplicator.Flush(ctx)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to flush deduplicator for profile '%s', %w", ap.profile.Name, err))
+		}
+	}
+
+	err := ap.profile.Writer.Close(ctx)
+
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed to close writer for profile '%s', %w", ap.profile.Name, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// closeAppProfiles closes the writers for each profile in 'app_profiles', without flushing deduplicated records, after
+// a failure to set up or run the profiles.
+func closeAppProfiles(ctx context.Context, app_profiles []*appProfile) {
+
+	for _, ap := range app_profiles {
+		ap.profile.Writer.Close(ctx)
+	}
+}
+
+// splitValues returns the comma-separated elements of each item in 'values'.
+func splitValues(values []string) []string {
+
+	split := make([]string, 0)
+
+	for _, v := range values {
+
+		for _, s := range strings.Split(v, ",") {
+
+			s = strings.TrimSpace(s)
+
+			if s != "" {
+				split = append(split, s)
+			}
+		}
+	}
+
+	return split
+}
@@ -0,0 +1,83 @@
+package features
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+)
+
+func TestNewAppProfileValidRange(t *testing.T) {
+
+	ctx := context.Background()
+
+	// A profile's valid-at parameter replaces, rather than conflicts with, the -valid-between flag
+
+	selection_flags.ValidBetween = "1950,1962"
+	defer func() { selection_flags.ValidBetween = "" }()
+
+	defaults := &tippecanoe.IterwriterCallbackFuncBuilderOptions{}
+
+	valid := []string{
+		"profile://inherited?writer-uri=constant://?val=null://",
+		"profile://at?writer-uri=constant://?val=null://&valid-at=1900",
+		"profile://between?writer-uri=constant://?val=null://&valid-between=1900,1910",
+	}
+
+	for _, uri := range valid {
+
+		ap, err := newAppProfile(ctx, uri, defaults)
+
+		if err != nil {
+			t.Fatalf("Expected %s to be valid, %v", uri, err)
+		}
+
+		if ap.profile.Options.ValidRange == nil {
+			t.Fatalf("Expected %s to have a valid range", uri)
+		}
+
+		err = ap.Close(ctx)
+
+		if err != nil {
+			t.Fatalf("Failed to close profile for %s, %v", uri, err)
+		}
+	}
+
+	invalid := []string{
+		"profile://both?writer-uri=constant://?val=null://&valid-at=1900&valid-between=1900,1910",
+		"profile://bad?writer-uri=constant://?val=null://&valid-at=not-a-date",
+	}
+
+	for _, uri := range invalid {
+
+		_, err := newAppProfile(ctx, uri, defaults)
+
+		if err == nil {
+			t.Fatalf("Expected %s to be invalid", uri)
+		}
+	}
+}
+
+func TestNewAppProfileSPRAppendProperty(t *testing.T) {
+
+	ctx := context.Background()
+
+	defaults := &tippecanoe.IterwriterCallbackFuncBuilderOptions{}
+
+	uri := "profile://spr?writer-uri=constant://?val=null://&spr-append-property=wof:hierarchy,wof:belongsto&spr-append-property=wof:repo"
+
+	ap, err := newAppProfile(ctx, uri, defaults)
+
+	if err != nil {
+		t.Fatalf("Failed to create profile, %v", err)
+	}
+
+	defer ap.Close(ctx)
+
+	expected := []string{"wof:hierarchy", "wof:belongsto", "wof:repo"}
+
+	if !slices.Equal(ap.profile.Options.AppendSPRProperties, expected) {
+		t.Fatalf("Expected %v, got %v", expected, ap.profile.Options.AppendSPRProperties)
+	}
+}
@@ -0,0 +1,98 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sfomuseum/go-flags/lookup"
+	"github.com/sfomuseum/go-flags/multi"
+	"github.com/sfomuseum/runtimevar"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// closeHookWriter wraps a `writer.Writer` instance and invokes zero or more functions before
+// the underlying writer is closed. This is necessary because iterwriter.RunWithOptions closes
+// its writer before returning. Every function is invoked, and the underlying writer is always closed, even
+// if a function returns an error.
+type closeHookWriter struct {
+	writer.Writer
+	hooks []func(context.Context) error
+}
+
+func (wr *closeHookWriter) Close(ctx context.Context) error {
+
+	errs := make([]error, 0)
+
+	for _, fn := range wr.hooks {
+
+		err := fn(ctx)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err := wr.Writer.Close(ctx)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// writerFromFlagSet returns a new `writer.Writer` instance derived from the -writer-uri flags in 'fs'
+// in the same way that iterwriter.RunWithOptions does.
+func writerFromFlagSet(ctx context.Context, fs *flag.FlagSet) (writer.Writer, error) {
+
+	v, err := lookup.Lookup(fs, "writer-uri")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive writer URIs, %w", err)
+	}
+
+	writer_uris := v.(multi.MultiCSVString)
+
+	return writerFromURIs(ctx, writer_uris...)
+}
+
+// writerFromURIs returns a new `writer.Writer` instance (a multi writer) for each (gocloud.dev/runtimevar
+// encoded) URI in 'runtimevar_uris'.
+func writerFromURIs(ctx context.Context, runtimevar_uris ...string) (writer.Writer, error) {
+
+	writers := make([]writer.Writer, len(runtimevar_uris))
+
+	wr_ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	for idx, runtimevar_uri := range runtimevar_uris {
+
+		wr_uri, err := runtimevar.StringVar(wr_ctx, runtimevar_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive writer URI for %s, %w", runtimevar_uri, err)
+		}
+
+		wr_uri = strings.TrimSpace(wr_uri)
+
+		wr, err := writer.NewWriter(ctx, wr_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create new writer for %s, %w", runtimevar_uri, err)
+		}
+
+		writers[idx] = wr
+	}
+
+	mw, err := writer.NewMultiWriter(ctx, writers...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create multi writer, %w", err)
+	}
+
+	return mw, nil
+}
@@ -0,0 +1,59 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// testClosingWriter is a `writer.Writer` instance which records whether it has been closed.
+type testClosingWriter struct {
+	writer.Writer
+	closed bool
+}
+
+func (wr *testClosingWriter) Close(ctx context.Context) error {
+	wr.closed = true
+	return nil
+}
+
+func TestCloseHookWriterRunsEveryHook(t *testing.T) {
+
+	ctx := context.Background()
+
+	err_first := errors.New("first")
+	err_last := errors.New("last")
+
+	called := 0
+
+	hook := func(err error) func(context.Context) error {
+
+		return func(context.Context) error {
+			called += 1
+			return err
+		}
+	}
+
+	wr := &testClosingWriter{}
+
+	hook_wr := &closeHookWriter{
+		Writer: wr,
+		hooks:  []func(context.Context) error{hook(err_first), hook(nil), hook(err_last)},
+	}
+
+	err := hook_wr.Close(ctx)
+
+	if !errors.Is(err, err_first) || !errors.Is(err, err_last) {
+		t.Fatalf("Expected errors from every hook, %v", err)
+	}
+
+	if called != 3 {
+		t.Fatalf("Expected 3 hooks to be called, got %d", called)
+	}
+
+	if !wr.closed {
+		t.Fatalf("Expected underlying writer to be closed")
+	}
+}
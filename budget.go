@@ -0,0 +1,465 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// The string appended to string values which have been truncated.
+const BUDGET_TRUNCATION_SUFFIX string = "…"
+
+// A property value that was shortened (strings) or had trailing elements removed (arrays).
+const BUDGET_ACTION_TRUNCATED string = "truncated"
+
+// A property value that could not be shortened and was removed.
+const BUDGET_ACTION_REMOVED string = "removed"
+
+// BUDGET_DEFAULT_PROTECTED_PROPERTIES are the properties which are never truncated or removed by a `PropertyBudget`
+// unless `PropertyBudgetOptions.Protected` is defined.
+var BUDGET_DEFAULT_PROTECTED_PROPERTIES = []string{
+	"wof:id",
+	"wof:name",
+	"wof:placetype",
+	"wof:parent_id",
+}
+
+// PropertyBudgetOptions defines configuration options for a `PropertyBudget` instance.
+type PropertyBudgetOptions struct {
+	// MaxBytes is the maximum size, in bytes, of the (JSON-encoded) properties of a feature. Zero means no limit.
+	MaxBytes int
+	// MaxValueBytes is the default maximum size, in bytes, of any one (JSON-encoded) property value. Zero means no limit.
+	MaxValueBytes int
+	// Rules is an optional dictionary mapping property names to the maximum size, in bytes, of their (JSON-encoded)
+	// values. Rules override MaxValueBytes; a rule of zero means no limit for that property.
+	Rules map[string]int
+	// Protected is an optional list of properties which are never truncated or removed. If nil
+	// `BUDGET_DEFAULT_PROTECTED_PROPERTIES` is used.
+	Protected []string
+}
+
+// TrimmedProperty is a struct describing a property that was truncated or removed by a `PropertyBudget`.
+type TrimmedProperty struct {
+	// Id is the Who's On First ID of the feature.
+	Id int64 `json:"id"`
+	// RelPath is the relative path of the feature.
+	RelPath string `json:"rel_path"`
+	// Property is the name of the property.
+	Property string `json:"property"`
+	// Action is either `BUDGET_ACTION_TRUNCATED` or `BUDGET_ACTION_REMOVED`.
+	Action string `json:"action"`
+	// OriginalBytes is the size, in bytes, of the (JSON-encoded) value before it was trimmed.
+	OriginalBytes int `json:"original_bytes"`
+	// Bytes is the size, in bytes, of the (JSON-encoded) value after it was trimmed. Zero if it was removed.
+	Bytes int `json:"bytes"`
+}
+
+// PropertyBudgetReport is a struct describing the properties trimmed by a `PropertyBudget`.
+type PropertyBudgetReport struct {
+	// Created is the time the report was created.
+	Created time.Time `json:"created"`
+	// MaxBytes is the maximum size, in bytes, of the properties of a feature.
+	MaxBytes int `json:"max_bytes,omitempty"`
+	// MaxValueBytes is the default maximum size, in bytes, of any one property value.
+	MaxValueBytes int `json:"max_value_bytes,omitempty"`
+	// Rules is the dictionary of per-property size limits.
+	Rules map[string]int `json:"rules,omitempty"`
+	// Features is the number of features with at least one trimmed property.
+	Features int `json:"features"`
+	// Properties is a dictionary mapping each property name to the number of times it was trimmed.
+	Properties map[string]int `json:"properties"`
+	// Trimmed is the list of trimmed properties, sorted by relative path and then property name.
+	Trimmed []*TrimmedProperty `json:"trimmed"`
+}
+
+// PropertyBudget limits the size of the properties of features, to keep tiles under tippecanoe's size limits, and
+// records which properties on which features were trimmed. Per-value limits are applied first. Then, if the properties
+// of a feature still exceed the per-feature limit, the largest (unprotected) property is trimmed by as much as necessary
+// until they fit or there is nothing left to trim. Strings are shortened (and suffixed with `BUDGET_TRUNCATION_SUFFIX`)
+// and arrays have trailing elements removed; values which can't be shortened, or would be left empty, are removed. It
+// is safe for concurrent use.
+type PropertyBudget struct {
+	max_bytes       int
+	max_value_bytes int
+	rules           map[string]int
+	protected       []string
+	trimmed         map[string][]*TrimmedProperty
+	mu              *sync.Mutex
+}
+
+type budgetProperty struct {
+	key     string
+	raw     string
+	removed bool
+}
+
+// NewPropertyBudget returns a new `PropertyBudget` instance configured by 'opts'.
+func NewPropertyBudget(ctx context.Context, opts *PropertyBudgetOptions) (*PropertyBudget, error) {
+
+	if opts.MaxBytes < 0 {
+		return nil, fmt.Errorf("Invalid maximum bytes, must be zero or greater")
+	}
+
+	if opts.MaxValueBytes < 0 {
+		return nil, fmt.Errorf("Invalid maximum value bytes, must be zero or greater")
+	}
+
+	rules := make(map[string]int)
+
+	for k, v := range opts.Rules {
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid rule for %s, must be zero or greater", k)
+		}
+
+		rules[k] = v
+	}
+
+	protected := opts.Protected
+
+	if protected == nil {
+		protected = BUDGET_DEFAULT_PROTECTED_PROPERTIES
+	}
+
+	b := &PropertyBudget{
+		max_bytes:       opts.MaxBytes,
+		max_value_bytes: opts.MaxValueBytes,
+		rules:           rules,
+		protected:       protected,
+		trimmed:         make(map[string][]*TrimmedProperty),
+		mu:              new(sync.Mutex),
+	}
+
+	return b, nil
+}
+
+// ParsePropertyBudgetRule parses 'str', in the form of {PROPERTY}={BYTES}, returning the property name and
+// the maximum size in bytes.
+func ParsePropertyBudgetRule(str string) (string, int, error) {
+
+	k, str_v, ok := strings.Cut(str, "=")
+
+	k = strings.TrimSpace(k)
+
+	if !ok || k == "" {
+		return "", 0, fmt.Errorf("Invalid rule '%s', expected {PROPERTY}={BYTES}", str)
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(str_v))
+
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed to parse bytes for rule '%s', %w", str, err)
+	}
+
+	if v < 0 {
+		return "", 0, fmt.Errorf("Invalid rule '%s', bytes must be zero or greater", str)
+	}
+
+	return k, v, nil
+}
+
+// Apply trims the properties of 'f' so that they fit the limits defined by 'b' and records the properties
+// which were trimmed. It is the equivalent of calling `Trim` followed by `Record`.
+func (b *PropertyBudget) Apply(ctx context.Context, f *Feature) error {
+
+	trimmed, err := b.Trim(ctx, f)
+
+	if err != nil {
+		return err
+	}
+
+	b.Record(f.RelPath, trimmed)
+	return nil
+}
+
+// Trim trims the properties of 'f' so that they fit the limits defined by 'b' returning the properties which
+// were trimmed. Nothing is recorded by 'b' so that callers which may discard 'f' (for example because it is
+// a duplicate) can invoke `Record` once they know it has been written.
+func (b *PropertyBudget) Trim(ctx context.Context, f *Feature) ([]*TrimmedProperty, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	props_rsp := gjson.GetBytes(body, "properties")
+
+	if !props_rsp.IsObject() {
+		return nil, nil
+	}
+
+	props := make([]*budgetProperty, 0)
+
+	props_rsp.ForEach(func(k gjson.Result, v gjson.Result) bool {
+		props = append(props, &budgetProperty{key: k.String(), raw: v.Raw})
+		return true
+	})
+
+	trimmed := make([]*TrimmedProperty, 0)
+
+	trim := func(p *budgetProperty, limit int) {
+
+		t := &TrimmedProperty{
+			Id:            f.Id,
+			RelPath:       f.RelPath,
+			Property:      p.key,
+			OriginalBytes: len(p.raw),
+		}
+
+		raw, ok := truncateValue(p.raw, limit)
+
+		if ok {
+			p.raw = raw
+			t.Action = BUDGET_ACTION_TRUNCATED
+			t.Bytes = len(raw)
+		} else {
+			p.removed = true
+			t.Action = BUDGET_ACTION_REMOVED
+		}
+
+		// A property may be trimmed twice (by its own limit and then the feature limit) so
+		// only record the original size once
+
+		for _, prev := range trimmed {
+
+			if prev.Property == p.key {
+				prev.Action = t.Action
+				prev.Bytes = t.Bytes
+				return
+			}
+		}
+
+		trimmed = append(trimmed, t)
+	}
+
+	for _, p := range props {
+
+		if slices.Contains(b.protected, p.key) {
+			continue
+		}
+
+		limit, ok := b.rules[p.key]
+
+		if !ok {
+			limit = b.max_value_bytes
+		}
+
+		if limit > 0 && len(p.raw) > limit {
+			trim(p, limit)
+		}
+	}
+
+	if b.max_bytes > 0 {
+
+		for {
+
+			size := len(encodeBudgetProperties(props))
+
+			if size <= b.max_bytes {
+				break
+			}
+
+			var largest *budgetProperty
+
+			for _, p := range props {
+
+				if p.removed || slices.Contains(b.protected, p.key) {
+					continue
+				}
+
+				if largest == nil || len(p.raw) > len(largest.raw) {
+					largest = p
+				}
+			}
+
+			if largest == nil {
+				slog.Warn("Unable to fit properties in budget", "rel_path", f.RelPath, "bytes", size, "max bytes", b.max_bytes)
+				break
+			}
+
+			trim(largest, len(largest.raw)-(size-b.max_bytes))
+		}
+	}
+
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	body, err = sjson.SetRawBytes(body, "properties", encodeBudgetProperties(props))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to assign trimmed properties for %s, %w", f.Path, err)
+	}
+
+	f.SetBody(body)
+
+	for _, t := range trimmed {
+		slog.Debug("Trimmed property", "rel_path", t.RelPath, "property", t.Property, "action", t.Action, "original bytes", t.OriginalBytes, "bytes", t.Bytes)
+	}
+
+	return trimmed, nil
+}
+
+// Record records 'trimmed' as the properties trimmed for the feature written as 'key' (a relative path), replacing
+// anything previously recorded for 'key'. This ensures that a record which supersedes another record with the same
+// key (for example when deduplicating by last modification date) is the only one reported. An empty list removes
+// anything previously recorded for 'key'.
+func (b *PropertyBudget) Record(key string, trimmed []*TrimmedProperty) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(trimmed) == 0 {
+		delete(b.trimmed, key)
+		return
+	}
+
+	b.trimmed[key] = trimmed
+}
+
+// Report returns a new `PropertyBudgetReport` instance describing the properties trimmed by 'b'.
+func (b *PropertyBudget) Report() *PropertyBudgetReport {
+
+	b.mu.Lock()
+
+	trimmed := make([]*TrimmedProperty, 0)
+
+	for _, v := range b.trimmed {
+		trimmed = append(trimmed, v...)
+	}
+
+	b.mu.Unlock()
+
+	sort.Slice(trimmed, func(i, j int) bool {
+
+		if trimmed[i].RelPath != trimmed[j].RelPath {
+			return trimmed[i].RelPath < trimmed[j].RelPath
+		}
+
+		return trimmed[i].Property < trimmed[j].Property
+	})
+
+	features := make(map[string]bool)
+	properties := make(map[string]int)
+
+	for _, t := range trimmed {
+		features[t.RelPath] = true
+		properties[t.Property] += 1
+	}
+
+	r := &PropertyBudgetReport{
+		Created:       time.Now(),
+		MaxBytes:      b.max_bytes,
+		MaxValueBytes: b.max_value_bytes,
+		Rules:         b.rules,
+		Features:      len(features),
+		Properties:    properties,
+		Trimmed:       trimmed,
+	}
+
+	return r
+}
+
+func encodeBudgetProperties(props []*budgetProperty) []byte {
+
+	buf := []byte("{")
+	first := true
+
+	for _, p := range props {
+
+		if p.removed {
+			continue
+		}
+
+		if !first {
+			buf = append(buf, ',')
+		}
+
+		enc_k, _ := json.Marshal(p.key)
+
+		buf = append(buf, enc_k...)
+		buf = append(buf, ':')
+		buf = append(buf, p.raw...)
+
+		first = false
+	}
+
+	buf = append(buf, '}')
+	return buf
+}
+
+// truncateValue returns the longest prefix of the (JSON-encoded) string or array 'raw' whose encoded size is
+// no larger than 'limit' bytes. The second return value is false if 'raw' can not be truncated or the
+// result would be empty.
+func truncateValue(raw string, limit int) (string, bool) {
+
+	v := gjson.Parse(raw)
+
+	switch {
+	case v.Type == gjson.String:
+
+		runes := []rune(v.String())
+
+		encode := func(n int) string {
+			enc, _ := json.Marshal(string(runes[:n]) + BUDGET_TRUNCATION_SUFFIX)
+			return string(enc)
+		}
+
+		// Find the largest number of runes whose encoded size fits
+
+		n := sort.Search(len(runes), func(i int) bool {
+			return len(encode(i+1)) > limit
+		})
+
+		if n == 0 {
+			return "", false
+		}
+
+		return encode(n), true
+
+	case v.IsArray():
+
+		items := v.Array()
+
+		buf := "["
+		count := 0
+
+		for idx, item := range items {
+
+			next := buf
+
+			if idx > 0 {
+				next += ","
+			}
+
+			next += item.Raw
+
+			if len(next)+1 > limit {
+				break
+			}
+
+			buf = next
+			count += 1
+		}
+
+		if count == 0 {
+			return "", false
+		}
+
+		return buf + "]", true
+
+	default:
+		return "", false
+	}
+}
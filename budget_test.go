@@ -0,0 +1,99 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestTruncateValue(t *testing.T) {
+
+	tests := []struct {
+		raw      string
+		limit    int
+		expected string
+		ok       bool
+	}{
+		{`"abcdefgh"`, 8, `"abc…"`, true},
+		{`"abcdefgh"`, 5, "", false},
+		{`"ééé"`, 8, `"é…"`, true},
+		{`[1,2,3]`, 6, `[1,2]`, true},
+		{`[123]`, 3, "", false},
+		{`123456`, 3, "", false},
+		{`{"a":"bcdef"}`, 6, "", false},
+	}
+
+	for _, test := range tests {
+
+		v, ok := truncateValue(test.raw, test.limit)
+
+		if ok != test.ok {
+			t.Fatalf("Expected %t truncating %s to %d bytes, got %t", test.ok, test.raw, test.limit, ok)
+		}
+
+		if v != test.expected {
+			t.Fatalf("Expected %s truncating %s to %d bytes, got %s", test.expected, test.raw, test.limit, v)
+		}
+
+		if ok && len(v) > test.limit {
+			t.Fatalf("Truncated value %s exceeds %d bytes", v, test.limit)
+		}
+	}
+}
+
+func TestPropertyBudgetRecordsWrittenFeatures(t *testing.T) {
+
+	ctx := context.Background()
+
+	b, err := NewPropertyBudget(ctx, &PropertyBudgetOptions{MaxValueBytes: 10})
+
+	if err != nil {
+		t.Fatalf("Failed to create property budget, %v", err)
+	}
+
+	dd, err := NewDeduplicator(ctx, &DeduplicatorOptions{Policy: DEDUPE_POLICY_FIRST})
+
+	if err != nil {
+		t.Fatalf("Failed to create deduplicator, %v", err)
+	}
+
+	defer dd.Close()
+
+	opts := &IterwriterCallbackFuncBuilderOptions{
+		PropertyBudget: b,
+		Deduplicator:   dd,
+	}
+
+	bodies := []string{
+		`{"type":"Feature","properties":{"wof:id":101736545,"name:eng_x_preferred":"Montreal"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		// A duplicate, which will be discarded, with a property which will be trimmed
+		`{"type":"Feature","properties":{"wof:id":101736545,"name:eng_x_preferred":"Montreal, Quebec"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+	}
+
+	wr := newCaptureWriter()
+
+	for _, body := range bodies {
+
+		f, err := NewFeature("101/736/545/101736545.geojson", bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		err = writeFeature(ctx, opts, f, wr)
+
+		if err != nil {
+			t.Fatalf("Failed to write feature, %v", err)
+		}
+	}
+
+	if len(wr.Drain()) != 1 {
+		t.Fatalf("Expected a single feature to be written")
+	}
+
+	r := b.Report()
+
+	if len(r.Trimmed) != 0 {
+		t.Fatalf("Expected no trimmed properties for discarded duplicate, got %d", len(r.Trimmed))
+	}
+}
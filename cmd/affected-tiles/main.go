@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	_ "github.com/whosonfirst/go-whosonfirst-iterate-git/v3/github"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe/app/affectedtiles"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := affectedtiles.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to derive affected tiles, %v", err)
+	}
+}
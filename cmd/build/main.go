@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/whosonfirst/go-whosonfirst-iterate-git/v3/github"
+	_ "github.com/whosonfirst/go-writer-featurecollection/v3"
+	_ "github.com/whosonfirst/go-writer-jsonl/v3"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe"
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe/app/build"
+)
+
+func main() {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := build.Run(ctx)
+
+	if err != nil {
+
+		var process_err *tippecanoe.ProcessError
+
+		if errors.As(err, &process_err) && process_err.ExitCode > 0 {
+			log.Printf("Failed to build tileset, %v", err)
+			os.Exit(process_err.ExitCode)
+		}
+
+		log.Fatalf("Failed to build tileset, %v", err)
+	}
+}
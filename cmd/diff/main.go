@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/whosonfirst/go-whosonfirst-tippecanoe/app/diff"
+)
+
+func main() {
+
+	ctx := context.Background()
+	err := diff.Run(ctx)
+
+	if err != nil {
+		log.Fatalf("Failed to diff features, %v", err)
+	}
+}
@@ -0,0 +1,628 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// The tippecanoe attribute type for string values.
+const ATTRIBUTE_TYPE_STRING string = "string"
+
+// The tippecanoe attribute type for floating point values.
+const ATTRIBUTE_TYPE_FLOAT string = "float"
+
+// The tippecanoe attribute type for integer values.
+const ATTRIBUTE_TYPE_INT string = "int"
+
+// The tippecanoe attribute type for boolean values.
+const ATTRIBUTE_TYPE_BOOL string = "bool"
+
+// The maximum zoom level suggested by `CommandWriter`.
+const COMMAND_MAX_ZOOM int = 16
+
+// The maximum number of features in a single tile (tippecanoe's default `--maximum-tile-features` limit) used by
+// `CommandWriter` to suggest a minimum zoom.
+const COMMAND_MAX_TILE_FEATURES int64 = 200000
+
+// The maximum number of points in a single tile at which `CommandWriter` considers them to be distinguishable, used
+// to suggest a maximum zoom for points.
+const COMMAND_MAX_TILE_POINTS int64 = 100
+
+// The property used by `--use-attribute-for-id` if it is present, and an integer, for every feature and no features are exploded parts.
+const COMMAND_ID_ATTRIBUTE string = "wof:id"
+
+// CommandOptions is a struct defining options for the tippecanoe command returned by `CommandWriter.Command`.
+type CommandOptions struct {
+	// Output is the path of the tileset to be produced. If empty "OUTPUT.pmtiles" is used.
+	Output string
+	// Layer is the name of the (single) layer for features. Ignored if `Inputs` is not empty.
+	Layer string
+	// Inputs is an optional dictionary mapping layer names to the paths of input files. If empty features
+	// are expected to be read from STDIN.
+	Inputs map[string]string
+}
+
+// CommandWriter implements the `whosonfirst/go-writer/v3.Writer` interface, wrapping another `Writer` instance,
+// observing the properties and geometries of each document written in order to recommend a tippecanoe command.
+type CommandWriter struct {
+	writer.Writer
+	writer   writer.Writer
+	count    int64
+	types    map[string]map[string]int64
+	id_count int64
+	zooms    []int64
+	extent   [4]float64
+	tiles    map[maptile.Tile]*tileCount
+	mu       *sync.Mutex
+}
+
+// tileCount is a struct recording the number of features, and the number of those features which are points (have no size),
+// whose centers fall in a tile.
+type tileCount struct {
+	features int64
+	points   int64
+}
+
+// NewCommandWriter returns a new `CommandWriter` instance which will observe each document before passing it to 'wr'.
+func NewCommandWriter(wr writer.Writer) *CommandWriter {
+
+	c := &CommandWriter{
+		writer: wr,
+		types:  make(map[string]map[string]int64),
+		zooms:  make([]int64, COMMAND_MAX_ZOOM+1),
+		extent: [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+		tiles:  make(map[maptile.Tile]*tileCount),
+		mu:     new(sync.Mutex),
+	}
+
+	return c
+}
+
+// Write observes the contents of 'r' and then writes it to the underlying writer.
+func (c *CommandWriter) Write(ctx context.Context, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	c.Observe(body)
+
+	return c.writer.Write(ctx, key, bytes.NewReader(body))
+}
+
+// WriterURI returns the URI for 'key' derived from the underlying writer.
+func (c *CommandWriter) WriterURI(ctx context.Context, key string) string {
+	return c.writer.WriterURI(ctx, key)
+}
+
+// Flush flushes the underlying writer.
+func (c *CommandWriter) Flush(ctx context.Context) error {
+	return c.writer.Flush(ctx)
+}
+
+// Close closes the underlying writer.
+func (c *CommandWriter) Close(ctx context.Context) error {
+	return c.writer.Close(ctx)
+}
+
+// SetLogger assigns 'logger' to the underlying writer.
+func (c *CommandWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return c.writer.SetLogger(ctx, logger)
+}
+
+// Observe records the types of each property, and the size and location of the geometry, of the feature 'body'.
+func (c *CommandWriter) Observe(body []byte) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count += 1
+
+	props_rsp := gjson.GetBytes(body, "properties")
+
+	// The parts of exploded features share the same "wof:id" property so it can't be used as a (unique) feature ID
+	is_part := props_rsp.Get(EXPLODE_PART_PROPERTY).Exists()
+
+	props_rsp.ForEach(func(k gjson.Result, v gjson.Result) bool {
+
+		t := attributeType(v)
+
+		if t == "" {
+			return true
+		}
+
+		name := k.String()
+
+		_, exists := c.types[name]
+
+		if !exists {
+			c.types[name] = make(map[string]int64)
+		}
+
+		c.types[name][t] += 1
+
+		if name == COMMAND_ID_ATTRIBUTE && t == ATTRIBUTE_TYPE_INT && !is_part {
+			c.id_count += 1
+		}
+
+		return true
+	})
+
+	bbox, ok := featureBounds(body)
+
+	if !ok {
+		return
+	}
+
+	c.extent[0] = math.Min(c.extent[0], bbox[0])
+	c.extent[1] = math.Min(c.extent[1], bbox[1])
+	c.extent[2] = math.Max(c.extent[2], bbox[2])
+	c.extent[3] = math.Max(c.extent[3], bbox[3])
+
+	size := math.Max(bbox[2]-bbox[0], bbox[3]-bbox[1])
+
+	if size > 0 {
+		c.zooms[featureZoom(size)] += 1
+	}
+
+	center := orb.Point{(bbox[0] + bbox[2]) / 2.0, (bbox[1] + bbox[3]) / 2.0}
+	tile := maptile.At(center, maptile.Zoom(COMMAND_MAX_ZOOM))
+
+	// maptile.At returns an invalid tile for points on the antimeridian
+	tile.X = min(tile.X, uint32(1<<COMMAND_MAX_ZOOM)-1)
+	tile.Y = min(tile.Y, uint32(1<<COMMAND_MAX_ZOOM)-1)
+
+	tc, exists := c.tiles[tile]
+
+	if !exists {
+		tc = new(tileCount)
+		c.tiles[tile] = tc
+	}
+
+	tc.features += 1
+
+	if size == 0 {
+		tc.points += 1
+	}
+}
+
+// AttributeTypes returns a dictionary mapping each property observed to its tippecanoe attribute type. Properties
+// with more than one type are assigned the most general type that can represent all of their values.
+func (c *CommandWriter) AttributeTypes() map[string]string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	types := make(map[string]string, len(c.types))
+
+	for name, counts := range c.types {
+
+		_, is_string := counts[ATTRIBUTE_TYPE_STRING]
+		_, is_float := counts[ATTRIBUTE_TYPE_FLOAT]
+		_, is_int := counts[ATTRIBUTE_TYPE_INT]
+		_, is_bool := counts[ATTRIBUTE_TYPE_BOOL]
+
+		switch {
+		case is_string, is_bool && (is_float || is_int):
+			types[name] = ATTRIBUTE_TYPE_STRING
+		case is_float:
+			types[name] = ATTRIBUTE_TYPE_FLOAT
+		case is_int:
+			types[name] = ATTRIBUTE_TYPE_INT
+		default:
+			types[name] = ATTRIBUTE_TYPE_BOOL
+		}
+	}
+
+	return types
+}
+
+// Zooms returns the suggested minimum and maximum zoom levels for the features observed, derived from their extent, sizes
+// and density (the number of features whose centers fall in each tile). The minimum zoom is the highest zoom at which the
+// extent of all the features fits in a single tile unless the densest tile at that zoom contains more than
+// `COMMAND_MAX_TILE_FEATURES` features, in which case it is the lowest zoom at which no tile does. The maximum zoom is the
+// zoom at which nine out of ten features with an area span at least a quarter of a tile or, for points, the lowest zoom
+// at which nine out of ten points are in tiles containing no more than `COMMAND_MAX_TILE_POINTS` points, whichever is higher.
+func (c *CommandWriter) Zooms() (int, int) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count == 0 || math.IsInf(c.extent[0], 1) {
+		return 0, COMMAND_MAX_ZOOM
+	}
+
+	extent_w := c.extent[2] - c.extent[0]
+	extent_h := c.extent[3] - c.extent[1]
+
+	min_zoom := 0
+
+	if extent_w > 0 || extent_h > 0 {
+		min_zoom = clampZoom(int(math.Floor(math.Log2(360.0 / math.Max(extent_w, extent_h)))))
+	}
+
+	// Derive the number of features in the densest tile, and the number of points in tiles which contain no more
+	// than COMMAND_MAX_TILE_POINTS points, at each zoom level by walking up the tile pyramid
+
+	densest := make([]int64, COMMAND_MAX_ZOOM+1)
+	sparse_points := make([]int64, COMMAND_MAX_ZOOM+1)
+
+	points := int64(0)
+	counts := c.tiles
+
+	for z := COMMAND_MAX_ZOOM; z >= 0; z-- {
+
+		parents := make(map[maptile.Tile]*tileCount)
+
+		for tile, tc := range counts {
+
+			densest[z] = max(densest[z], tc.features)
+
+			if tc.points <= COMMAND_MAX_TILE_POINTS {
+				sparse_points[z] += tc.points
+			}
+
+			if z == COMMAND_MAX_ZOOM {
+				points += tc.points
+			}
+
+			if z > 0 {
+
+				parent := tile.Parent()
+				p_tc, exists := parents[parent]
+
+				if !exists {
+					p_tc = new(tileCount)
+					parents[parent] = p_tc
+				}
+
+				p_tc.features += tc.features
+				p_tc.points += tc.points
+			}
+		}
+
+		counts = parents
+	}
+
+	for z, n := range densest {
+
+		if n <= COMMAND_MAX_TILE_FEATURES {
+			min_zoom = max(min_zoom, z)
+			break
+		}
+	}
+
+	sized := int64(0)
+
+	for _, n := range c.zooms {
+		sized += n
+	}
+
+	if sized == 0 && extent_w <= 0 && extent_h <= 0 {
+		return min_zoom, COMMAND_MAX_ZOOM
+	}
+
+	max_zoom := 0
+
+	if sized > 0 {
+
+		threshold := int64(math.Ceil(float64(sized) * 0.9))
+		seen := int64(0)
+
+		for z, n := range c.zooms {
+
+			seen += n
+
+			if seen >= threshold {
+				max_zoom = z
+				break
+			}
+		}
+	}
+
+	if points > 0 {
+
+		threshold := int64(math.Ceil(float64(points) * 0.9))
+		points_zoom := COMMAND_MAX_ZOOM
+
+		for z, n := range sparse_points {
+
+			if n >= threshold {
+				points_zoom = z
+				break
+			}
+		}
+
+		max_zoom = max(max_zoom, points_zoom)
+	}
+
+	if max_zoom < min_zoom {
+		max_zoom = min_zoom
+	}
+
+	return min_zoom, max_zoom
+}
+
+// Command returns the list of arguments for a recommended tippecanoe command for the features observed. A `-T`
+// argument is included for each property except those returned by `UntypedAttributes`.
+func (c *CommandWriter) Command(opts *CommandOptions) []string {
+
+	output := opts.Output
+
+	if output == "" {
+		output = "OUTPUT.pmtiles"
+	}
+
+	args := []string{
+		"tippecanoe",
+		"-o", output,
+	}
+
+	if len(opts.Inputs) > 0 {
+
+		layers := make([]string, 0, len(opts.Inputs))
+
+		for l := range opts.Inputs {
+			layers = append(layers, l)
+		}
+
+		sort.Strings(layers)
+
+		for _, l := range layers {
+			args = append(args, "-L", fmt.Sprintf("%s:%s", l, opts.Inputs[l]))
+		}
+
+	} else if opts.Layer != "" {
+		args = append(args, "-l", opts.Layer)
+	}
+
+	min_zoom, max_zoom := c.Zooms()
+
+	args = append(args, "-Z", fmt.Sprintf("%d", min_zoom))
+	args = append(args, "-z", fmt.Sprintf("%d", max_zoom))
+
+	if c.useAttributeForId() {
+		args = append(args, fmt.Sprintf("--use-attribute-for-id=%s", COMMAND_ID_ATTRIBUTE))
+	}
+
+	types := c.AttributeTypes()
+
+	names := make([]string, 0, len(types))
+
+	for name := range types {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		if !isTypeableAttribute(name) {
+			continue
+		}
+
+		args = append(args, "-T", fmt.Sprintf("%s:%s", name, types[name]))
+	}
+
+	return args
+}
+
+// UntypedAttributes returns a dictionary mapping each property observed, whose type can not be passed to tippecanoe
+// using the `-T` flag, to its tippecanoe attribute type. Tippecanoe splits the value of the `-T` flag on the first
+// ":" character and has no means of escaping it so properties whose names contain a ":" (for example "wof:id")
+// are omitted from the arguments returned by `Command`. See `RenameTransformerURI` for a means of typing them.
+func (c *CommandWriter) UntypedAttributes() map[string]string {
+
+	untyped := make(map[string]string)
+
+	for name, t := range c.AttributeTypes() {
+
+		if !isTypeableAttribute(name) {
+			untyped[name] = t
+		}
+	}
+
+	return untyped
+}
+
+// RenamedAttributes returns a dictionary mapping each property returned by `UntypedAttributes` to a new name, with each
+// ":" replaced by "_", whose type can be passed to tippecanoe using the `-T` flag. Properties whose new name is already
+// used by another property, and the `COMMAND_ID_ATTRIBUTE` property if it is used for feature IDs, are excluded.
+func (c *CommandWriter) RenamedAttributes() map[string]string {
+
+	types := c.AttributeTypes()
+	use_id := c.useAttributeForId()
+
+	renamed := make(map[string]string)
+
+	for name := range types {
+
+		if isTypeableAttribute(name) || (name == COMMAND_ID_ATTRIBUTE && use_id) {
+			continue
+		}
+
+		new_name := strings.ReplaceAll(name, ":", "_")
+
+		_, exists := types[new_name]
+
+		if exists {
+			continue
+		}
+
+		renamed[name] = new_name
+	}
+
+	return renamed
+}
+
+// RenameTransformerURI returns a `rename://` transformer URI (see `NewRenameTransformer`) which renames each property
+// returned by `RenamedAttributes` so that, when applied, their types are included in the arguments returned by `Command`.
+// If there are no properties to rename an empty string is returned.
+func (c *CommandWriter) RenameTransformerURI() string {
+
+	renamed := c.RenamedAttributes()
+
+	if len(renamed) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(renamed))
+
+	for name := range renamed {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	q := url.Values{}
+
+	for _, name := range names {
+		q.Add("from", name)
+		q.Add("to", renamed[name])
+	}
+
+	return fmt.Sprintf("rename://?%s", q.Encode())
+}
+
+// useAttributeForId returns true if every feature observed has an integer `COMMAND_ID_ATTRIBUTE` property and is not an exploded part.
+func (c *CommandWriter) useAttributeForId() bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.count > 0 && c.id_count == c.count
+}
+
+// CommandString returns 'args' as a (multi-line) shell command, quoting arguments as necessary.
+func CommandString(args []string) string {
+
+	var buf strings.Builder
+
+	for idx, a := range args {
+
+		if idx > 0 {
+
+			if strings.HasPrefix(a, "-") {
+				buf.WriteString(" \\\n\t")
+			} else {
+				buf.WriteString(" ")
+			}
+		}
+
+		buf.WriteString(shellQuote(a))
+	}
+
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// isTypeableAttribute returns true if the type of the property 'name' can be passed to tippecanoe using the `-T` flag.
+func isTypeableAttribute(name string) bool {
+	return name != "" && !strings.Contains(name, ":")
+}
+
+func attributeType(v gjson.Result) string {
+
+	switch v.Type {
+	case gjson.Null:
+		return ""
+	case gjson.True, gjson.False:
+		return ATTRIBUTE_TYPE_BOOL
+	case gjson.Number:
+
+		if strings.ContainsAny(v.Raw, ".eE") {
+			return ATTRIBUTE_TYPE_FLOAT
+		}
+
+		return ATTRIBUTE_TYPE_INT
+	default:
+		// Tippecanoe stringifies arrays and objects
+		return ATTRIBUTE_TYPE_STRING
+	}
+}
+
+// featureBounds returns the bounding box of 'body', derived from its "bbox" property or its coordinates.
+func featureBounds(body []byte) ([4]float64, bool) {
+
+	bbox := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+	bbox_rsp := gjson.GetBytes(body, "bbox")
+
+	if bbox_rsp.IsArray() && len(bbox_rsp.Array()) == 4 {
+
+		for idx, v := range bbox_rsp.Array() {
+			bbox[idx] = v.Float()
+		}
+
+		return bbox, true
+	}
+
+	ok := false
+
+	var walk func(r gjson.Result)
+
+	walk = func(r gjson.Result) {
+
+		arr := r.Array()
+
+		if len(arr) >= 2 && arr[0].Type == gjson.Number {
+
+			x := arr[0].Float()
+			y := arr[1].Float()
+
+			bbox[0] = math.Min(bbox[0], x)
+			bbox[1] = math.Min(bbox[1], y)
+			bbox[2] = math.Max(bbox[2], x)
+			bbox[3] = math.Max(bbox[3], y)
+
+			ok = true
+			return
+		}
+
+		for _, child := range arr {
+			walk(child)
+		}
+	}
+
+	walk(gjson.GetBytes(body, "geometry.coordinates"))
+
+	gjson.GetBytes(body, "geometry.geometries").ForEach(func(k gjson.Result, v gjson.Result) bool {
+		walk(v.Get("coordinates"))
+		return true
+	})
+
+	return bbox, ok
+}
+
+// featureZoom returns the zoom level at which a feature 'size' decimal degrees wide spans a quarter of a tile.
+func featureZoom(size float64) int {
+	return clampZoom(int(math.Ceil(math.Log2(360.0/size))) - 2)
+}
+
+func clampZoom(z int) int {
+	return max(0, min(z, COMMAND_MAX_ZOOM))
+}
+
+func shellQuote(str string) string {
+
+	if str != "" && strings.Trim(str, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,/:+@%") == "" {
+		return str
+	}
+
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
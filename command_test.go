@@ -0,0 +1,152 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCommandWriterAttributeTypes(t *testing.T) {
+
+	ctx := context.Background()
+
+	wr := NewCommandWriter(newCaptureWriter())
+
+	bodies := []string{
+		`{"type":"Feature","properties":{"wof:id":101736545,"population_class":5,"name":"Montreal"},"geometry":{"type":"Point","coordinates":[-73.5,45.5]}}`,
+		`{"type":"Feature","properties":{"wof:id":85922583,"population_class":7.5,"name":"San Francisco"},"geometry":{"type":"Point","coordinates":[-122.4,37.7]}}`,
+	}
+
+	for _, body := range bodies {
+
+		_, err := wr.Write(ctx, "test.geojson", bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to write feature, %v", err)
+		}
+	}
+
+	args := wr.Command(&CommandOptions{})
+
+	for _, expected := range []string{"population_class:float", "name:string"} {
+
+		if !slices.Contains(args, expected) {
+			t.Fatalf("Expected command to include -T %s, %v", expected, args)
+		}
+	}
+
+	for _, a := range args {
+
+		if a == "wof:id:int" {
+			t.Fatalf("Expected command to exclude -T for wof:id")
+		}
+	}
+
+	untyped := wr.UntypedAttributes()
+
+	if untyped["wof:id"] != ATTRIBUTE_TYPE_INT {
+		t.Fatalf("Expected wof:id to be an untyped integer attribute, %v", untyped)
+	}
+
+	if len(untyped) != 1 {
+		t.Fatalf("Expected a single untyped attribute, got %d", len(untyped))
+	}
+}
+
+func TestCommandWriterRenamedAttributes(t *testing.T) {
+
+	ctx := context.Background()
+
+	wr := NewCommandWriter(newCaptureWriter())
+
+	body := []byte(`{"type":"Feature","properties":{"wof:id":101736545,"mz:is_current":1,"wof:name":"Montreal","wof_name":"Montreal","name":"Montreal"},"geometry":{"type":"Point","coordinates":[-73.5,45.5]}}`)
+
+	wr.Observe(body)
+
+	renamed := wr.RenamedAttributes()
+
+	// wof:id is used for feature IDs and wof_name is already used
+
+	if len(renamed) != 1 || renamed["mz:is_current"] != "mz_is_current" {
+		t.Fatalf("Unexpected renamed attributes, %v", renamed)
+	}
+
+	uri := wr.RenameTransformerURI()
+
+	tr, err := NewTransformer(ctx, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer for %s, %v", uri, err)
+	}
+
+	f, err := NewFeature("101736545.geojson", bytes.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	err = tr.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	renamed_body, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to derive body, %v", err)
+	}
+
+	renamed_wr := NewCommandWriter(newCaptureWriter())
+	renamed_wr.Observe(renamed_body)
+
+	if !slices.Contains(renamed_wr.Command(&CommandOptions{}), "mz_is_current:int") {
+		t.Fatalf("Expected command for renamed feature to include -T mz_is_current:int")
+	}
+
+	if renamed_wr.RenameTransformerURI() != "" {
+		t.Fatalf("Expected no properties to rename after renaming")
+	}
+}
+
+func TestCommandWriterZooms(t *testing.T) {
+
+	point := func(x float64, y float64) []byte {
+		return []byte(fmt.Sprintf(`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[%f,%f]}}`, x, y))
+	}
+
+	// Nine out of ten points are clustered in a small part of the extent so the maximum zoom is derived from
+	// the density of the cluster rather than the average spacing of points across the extent
+
+	clustered := NewCommandWriter(newCaptureWriter())
+
+	for i := 0; i < 900; i++ {
+		clustered.Observe(point(float64(i%30)*0.01, float64(i/30)*0.01))
+	}
+
+	for i := 0; i < 100; i++ {
+		clustered.Observe(point(10.0+float64(i%10), 10.0+float64(i/10)))
+	}
+
+	min_zoom, max_zoom := clustered.Zooms()
+
+	if min_zoom != 4 || max_zoom != 12 {
+		t.Fatalf("Unexpected zooms for clustered points, %d-%d", min_zoom, max_zoom)
+	}
+
+	// The densest tile at the zoom where the extent fits in a single tile contains too many features
+
+	dense := NewCommandWriter(newCaptureWriter())
+
+	for i := int64(0); i <= COMMAND_MAX_TILE_FEATURES; i++ {
+		dense.Observe(point(-100.0+float64(i%2)*200.0, 0))
+	}
+
+	min_zoom, _ = dense.Zooms()
+
+	if min_zoom != 1 {
+		t.Fatalf("Expected minimum zoom for dense points to be 1, got %d", min_zoom)
+	}
+}
@@ -0,0 +1,137 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/tidwall/sjson"
+	"github.com/whosonfirst/go-whosonfirst-feature/properties"
+)
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "concordances", NewConcordancesTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ConcordancesTransformer implements the `Transformer` interface copying values from the "wof:concordances" property
+// of a feature in to flat properties whose names are derived by replacing ":" with "_" in the concordance key. For
+// example the "wd:id" concordance is copied to a "wd_id" property. Concordances are read from the original body of
+// the feature so this can be used with SPR output.
+type ConcordancesTransformer struct {
+	Transformer
+	// Prefixes is the list of concordance prefixes (for example "wd" or "gn") or complete concordance keys (for
+	// example "wd:id") to copy.
+	Prefixes []string
+}
+
+// NewConcordancesTransformer returns a new `ConcordancesTransformer` instance configured by 'uri' in the form of:
+//
+//	concordances://?prefix={PREFIX}
+//
+// Where {PREFIX} is one or more (required) concordance prefixes or keys. Multiple prefixes may be passed as separate
+// parameters or as a comma-separated list.
+func NewConcordancesTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	prefixes := make([]string, 0)
+
+	for _, str_prefix := range q["prefix"] {
+
+		for _, prefix := range strings.Split(str_prefix, ",") {
+
+			prefix = strings.TrimSpace(prefix)
+
+			if prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("Missing ?prefix= parameter")
+	}
+
+	t := &ConcordancesTransformer{
+		Prefixes: prefixes,
+	}
+
+	return t, nil
+}
+
+// Transform copies each of the concordances of 'f' which match 't.Prefixes' in to flat properties.
+func (t *ConcordancesTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	original, err := f.Original()
+
+	if err != nil {
+		return err
+	}
+
+	concordances := properties.Concordances(original)
+
+	if len(concordances) == 0 {
+		return nil
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(concordances))
+
+	for k := range concordances {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	for _, k := range keys {
+
+		if !t.matches(k) {
+			continue
+		}
+
+		path := fmt.Sprintf("properties.%s", strings.ReplaceAll(k, ":", "_"))
+
+		body, err = sjson.SetBytes(body, path, concordances[k])
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign %s, %w", path, err)
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+func (t *ConcordancesTransformer) matches(key string) bool {
+
+	prefix, _, _ := strings.Cut(key, ":")
+
+	for _, p := range t.Prefixes {
+
+		if p == key || p == prefix {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,146 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func TestConcordancesTransformerMatches(t *testing.T) {
+
+	tests := []struct {
+		prefixes []string
+		key      string
+		expected bool
+	}{
+		// Prefixes match every key in their namespace
+		{[]string{"wd"}, "wd:id", true},
+		{[]string{"wd"}, "wd:other", true},
+		{[]string{"wd"}, "wdx:id", false},
+		{[]string{"gn", "wd"}, "gn:id", true},
+		// Complete keys only match themselves
+		{[]string{"gn:id"}, "gn:id", true},
+		{[]string{"gn:id"}, "gn:other", false},
+		{[]string{"gn:i"}, "gn:id", false},
+		{[]string{"gn:id"}, "gn", false},
+		// Keys without a namespace
+		{[]string{"osm"}, "osm", true},
+	}
+
+	for _, test := range tests {
+
+		tr := &ConcordancesTransformer{
+			Prefixes: test.prefixes,
+		}
+
+		if tr.matches(test.key) != test.expected {
+			t.Fatalf("Expected %v matching '%s' to be %t", test.prefixes, test.key, test.expected)
+		}
+	}
+}
+
+func TestNewConcordancesTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	tr, err := NewConcordancesTransformer(ctx, "concordances://?prefix=wd,%20gn:id&prefix=qs_pg")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	if prefixes := strings.Join(tr.(*ConcordancesTransformer).Prefixes, ","); prefixes != "wd,gn:id,qs_pg" {
+		t.Fatalf("Unexpected prefixes, %s", prefixes)
+	}
+
+	for _, uri := range []string{"concordances://", "concordances://?prefix=", "concordances://?prefix=,"} {
+
+		_, err := NewConcordancesTransformer(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+}
+
+func TestConcordancesTransformerSPR(t *testing.T) {
+
+	ctx := context.Background()
+
+	path := "101/736/545/101736545.geojson"
+
+	body, err := sjson.SetBytes([]byte(testStagesRecords[path]), "properties.wof:concordances", map[string]any{
+		"wd:id":    "Q340",
+		"gn:id":    6077243,
+		"gn:other": "x",
+		"qs_pg:id": 1234,
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to assign concordances, %v", err)
+	}
+
+	expected := map[string]string{
+		"wd_id": "Q340",
+		"gn_id": "6077243",
+	}
+
+	// Concordances are read from the original body so they are still copied when properties are replaced by SPR
+
+	for _, as_spr := range []bool{false, true} {
+
+		opts := &IterwriterCallbackFuncBuilderOptions{
+			AsSPR:        as_spr,
+			Concordances: []string{"wd", "gn:id"},
+		}
+
+		f, err := NewFeature(path, bytes.NewReader(body))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		features, err := DefaultPipeline(opts).ProcessAll(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to process feature (as-spr=%t), %v", as_spr, err)
+		}
+
+		out, err := features[0].Body()
+
+		if err != nil {
+			t.Fatalf("Failed to derive body, %v", err)
+		}
+
+		if as_spr && gjson.GetBytes(out, "properties.wof:hierarchy").Exists() {
+			t.Fatalf("Expected properties to be replaced by SPR")
+		}
+
+		for k, v := range expected {
+
+			if str_v := gjson.GetBytes(out, "properties."+k).String(); str_v != v {
+				t.Fatalf("Expected %s to be %s (as-spr=%t), got %s", k, v, as_spr, str_v)
+			}
+		}
+
+		names := testPropertyNames(out)
+
+		for _, k := range []string{"gn_other", "qs_pg_id"} {
+
+			if slices.Contains(names, k) {
+				t.Fatalf("Expected %s not to be copied (as-spr=%t)", k, as_spr)
+			}
+		}
+
+		// Concordances are copied in sorted order so that output is the same for every run
+
+		if slices.Index(names, "gn_id") > slices.Index(names, "wd_id") {
+			t.Fatalf("Expected concordances to be copied in sorted order, %v", names)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// Keep the first record encountered for a given ID.
+const DEDUPE_POLICY_FIRST string = "first"
+
+// Keep the record with the most recent "wof:lastmodified" property for a given ID.
+const DEDUPE_POLICY_LASTMODIFIED string = "lastmodified"
+
+// Keep the record whose "wof:repo" property matches a preferred repository for a given ID.
+const DEDUPE_POLICY_REPO string = "repo"
+
+// DeduplicatorOptions defines configuration options for a `Deduplicator` instance.
+type DeduplicatorOptions struct {
+	// Policy is the policy used to decide which record to keep when the same ID is encountered more than once.
+	Policy string
+	// PreferRepo is the name of the repository whose records should win when Policy is `DEDUPE_POLICY_REPO`.
+	PreferRepo string
+	// Path is an optional directory used to store deduplication state (and deferred records) on disk rather than in memory.
+	Path string
+}
+
+// The suffix of the files, stored alongside each spooled record, containing the details of deferred candidates.
+const dedupe_meta_suffix string = ".meta"
+
+// Deduplicator ensures that only one record for a given Who's On First ID is written. The `DEDUPE_POLICY_FIRST`
+// policy writes records as they are encountered. All other policies defer writing records until the `Flush`
+// method is invoked, since the winning record can only be known once every record has been seen. If a path
+// is defined then deferred records, and the details used to compare them, are stored on disk so that memory
+// use does not grow with the number of records. Alternate geometry files are deduplicated separately from
+// their principal records. It is safe for concurrent use.
+type Deduplicator struct {
+	policy      string
+	prefer_repo string
+	path        string
+	ids         IdSet
+	alt_keys    map[string]bool
+	candidates  map[string]*dedupeCandidate
+	writers     []writer.Writer
+	spool_errs  []error
+	mu          *sync.Mutex
+}
+
+// dedupeCandidate is the record retained for a given key by deferred policies. When state is stored on disk
+// it is encoded as JSON alongside the spooled record rather than kept in memory.
+type dedupeCandidate struct {
+	Key          string `json:"key"`
+	LastModified int64  `json:"lastmodified"`
+	Repo         string `json:"repo"`
+	// Writer is the index of the `writer.Writer` the record was passed with in `Deduplicator.writers`.
+	Writer int `json:"writer"`
+	body   []byte
+}
+
+// NewDeduplicator returns a new `Deduplicator` instance configured by 'opts'.
+func NewDeduplicator(ctx context.Context, opts *DeduplicatorOptions) (*Deduplicator, error) {
+
+	switch opts.Policy {
+	case DEDUPE_POLICY_FIRST, DEDUPE_POLICY_LASTMODIFIED:
+		// pass
+	case DEDUPE_POLICY_REPO:
+
+		if opts.PreferRepo == "" {
+			return nil, fmt.Errorf("Policy '%s' requires a preferred repository", opts.Policy)
+		}
+
+	default:
+		return nil, fmt.Errorf("Invalid dedupe policy '%s'", opts.Policy)
+	}
+
+	var ids IdSet
+
+	if opts.Path != "" {
+
+		err := os.MkdirAll(opts.Path, 0755)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create %s, %w", opts.Path, err)
+		}
+
+		s, err := NewDiskIdSet(filepath.Join(opts.Path, "ids.bitmap"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create on-disk ID set, %w", err)
+		}
+
+		ids = s
+
+	} else {
+		ids = NewMemoryIdSet()
+	}
+
+	d := &Deduplicator{
+		policy:      opts.Policy,
+		prefer_repo: opts.PreferRepo,
+		path:        opts.Path,
+		ids:         ids,
+		alt_keys:    make(map[string]bool),
+		candidates:  make(map[string]*dedupeCandidate),
+		mu:          new(sync.Mutex),
+	}
+
+	return d, nil
+}
+
+// Write writes the content of 'r' to 'wr' as 'key' (a relative Who's On First URI) if the ID derived from
+// 'key' has not been seen before. For policies other than `DEDUPE_POLICY_FIRST` the record is retained
+// until the `Flush` method is invoked. Records which are skipped, as duplicates, return zero bytes. For
+// deferred policies the return value is the number of bytes accepted (retained as a candidate), not written,
+// since a retained record may still be replaced by a later duplicate before `Flush` is invoked.
+func (d *Deduplicator) Write(ctx context.Context, wr writer.Writer, key string, r io.ReadSeeker) (int64, error) {
+
+	id, uri_args, err := uri.ParseURI(key)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse %s, %w", key, err)
+	}
+
+	if d.policy != DEDUPE_POLICY_FIRST {
+		return d.defer_write(ctx, wr, key, r)
+	}
+
+	var is_new bool
+
+	if uri_args.IsAlternate {
+
+		d.mu.Lock()
+		is_new = !d.alt_keys[key]
+		d.alt_keys[key] = true
+		d.mu.Unlock()
+
+	} else {
+
+		v, err := d.ids.Add(ctx, id)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to record ID %d, %w", id, err)
+		}
+
+		is_new = v
+	}
+
+	if !is_new {
+		slog.Debug("Skipping duplicate record", "key", key, "id", id)
+		return 0, nil
+	}
+
+	return wr.Write(ctx, key, r)
+}
+
+// defer_write retains 'r' as the candidate for 'key' if there is no existing candidate or it should replace the
+// existing candidate. It returns the number of bytes accepted, rather than written, or zero if 'r' was skipped.
+func (d *Deduplicator) defer_write(ctx context.Context, wr writer.Writer, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := &dedupeCandidate{
+		Key:          key,
+		LastModified: gjson.GetBytes(body, "properties.wof:lastmodified").Int(),
+		Repo:         gjson.GetBytes(body, "properties.wof:repo").String(),
+		Writer:       d.writerIndex(wr),
+	}
+
+	existing, exists, err := d.candidate(key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if exists && !d.replace(existing, c) {
+		slog.Debug("Skipping duplicate record", "key", key)
+		return 0, nil
+	}
+
+	if d.path == "" {
+		c.body = body
+		d.candidates[key] = c
+		return int64(len(body)), nil
+	}
+
+	err = os.WriteFile(d.spoolPath(key), body, 0644)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to spool %s, %w", key, err)
+	}
+
+	enc_c, err := json.Marshal(c)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to marshal details for %s, %w", key, err)
+	}
+
+	err = os.WriteFile(d.spoolPath(key)+dedupe_meta_suffix, enc_c, 0644)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to spool details for %s, %w", key, err)
+	}
+
+	return int64(len(body)), nil
+}
+
+// candidate returns the candidate retained for 'key', reading it from disk if necessary, and a boolean value
+// indicating whether there is one. The lock for 'd' must be held.
+func (d *Deduplicator) candidate(key string) (*dedupeCandidate, bool, error) {
+
+	if d.path == "" {
+		c, exists := d.candidates[key]
+		return c, exists, nil
+	}
+
+	return d.readCandidate(d.spoolPath(key) + dedupe_meta_suffix)
+}
+
+// readCandidate reads the (JSON-encoded) candidate stored in 'path' returning false if it does not exist.
+func (d *Deduplicator) readCandidate(path string) (*dedupeCandidate, bool, error) {
+
+	enc_c, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to read %s, %w", path, err)
+	}
+
+	var c *dedupeCandidate
+
+	err = json.Unmarshal(enc_c, &c)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to unmarshal %s, %w", path, err)
+	}
+
+	return c, true, nil
+}
+
+// writerIndex returns the index of 'wr' in the list of writers records have been passed with, adding it if
+// necessary, so that candidates only need to store an index. The lock for 'd' must be held.
+func (d *Deduplicator) writerIndex(wr writer.Writer) int {
+
+	for idx, v := range d.writers {
+
+		if v == wr {
+			return idx
+		}
+	}
+
+	d.writers = append(d.writers, wr)
+	return len(d.writers) - 1
+}
+
+func (d *Deduplicator) replace(existing *dedupeCandidate, c *dedupeCandidate) bool {
+
+	switch d.policy {
+	case DEDUPE_POLICY_LASTMODIFIED:
+		return c.LastModified > existing.LastModified
+	case DEDUPE_POLICY_REPO:
+		return existing.Repo != d.prefer_repo && c.Repo == d.prefer_repo
+	default:
+		return false
+	}
+}
+
+// Flush writes any records retained by deferred policies to the `writer.Writer` instances they were
+// originally passed with, ordered by key (or, if state is stored on disk, by the file name of each key).
+// It must be invoked before those writers are closed.
+func (d *Deduplicator) Flush(ctx context.Context) error {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.path != "" {
+		return d.flushSpool(ctx)
+	}
+
+	keys := make([]string, 0, len(d.candidates))
+
+	for k := range d.candidates {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		c := d.candidates[k]
+
+		_, err := d.writers[c.Writer].Write(ctx, k, bytes.NewReader(c.body))
+
+		if err != nil {
+			return fmt.Errorf("Failed to write %s, %w", k, err)
+		}
+
+		delete(d.candidates, k)
+	}
+
+	return nil
+}
+
+// flushSpool writes each of the candidates stored on disk, reading them one at a time in the order returned
+// by `os.ReadDir`. The lock for 'd' must be held.
+func (d *Deduplicator) flushSpool(ctx context.Context) error {
+
+	paths, err := d.spooledCandidates()
+
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+
+		c, exists, err := d.readCandidate(path)
+
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			continue
+		}
+
+		body, err := os.ReadFile(d.spoolPath(c.Key))
+
+		if err != nil {
+			return fmt.Errorf("Failed to read spooled record for %s, %w", c.Key, err)
+		}
+
+		_, err = d.writers[c.Writer].Write(ctx, c.Key, bytes.NewReader(body))
+
+		if err != nil {
+			return fmt.Errorf("Failed to write %s, %w", c.Key, err)
+		}
+
+		d.removeSpool(c.Key)
+	}
+
+	return nil
+}
+
+// spooledCandidates returns the paths of the candidates stored on disk, sorted by file name.
+func (d *Deduplicator) spooledCandidates() ([]string, error) {
+
+	entries, err := os.ReadDir(d.path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", d.path, err)
+	}
+
+	paths := make([]string, 0)
+
+	for _, e := range entries {
+
+		if e.IsDir() || !strings.HasSuffix(e.Name(), dedupe_meta_suffix) {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(d.path, e.Name()))
+	}
+
+	return paths, nil
+}
+
+// Close releases any resources used by 'd', including state stored on disk. Errors removing spooled records,
+// including those encountered by the `Flush` method, are returned along with any error closing the ID set.
+func (d *Deduplicator) Close() error {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	errs := make([]error, 0)
+
+	err := d.ids.Close()
+
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Failed to close ID set, %w", err))
+	}
+
+	if d.path != "" {
+
+		paths, err := d.spooledCandidates()
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		for _, path := range paths {
+			d.removeSpool(strings.TrimSuffix(filepath.Base(path), dedupe_meta_suffix))
+		}
+	}
+
+	errs = append(errs, d.spool_errs...)
+	d.spool_errs = nil
+
+	return errors.Join(errs...)
+}
+
+// removeSpool removes the spooled record, and candidate details, for 'key', retaining any error to be returned by the `Close` method. The
+// lock for 'd' must be held.
+func (d *Deduplicator) removeSpool(key string) {
+
+	for _, path := range []string{d.spoolPath(key), d.spoolPath(key) + dedupe_meta_suffix} {
+
+		err := os.Remove(path)
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			d.spool_errs = append(d.spool_errs, fmt.Errorf("Failed to remove spooled record for %s, %w", key, err))
+		}
+	}
+}
+
+func (d *Deduplicator) spoolPath(key string) string {
+	fname := filepath.Base(key)
+	return filepath.Join(d.path, fname)
+}
@@ -0,0 +1,294 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDeduplicatorPolicies(t *testing.T) {
+
+	ctx := context.Background()
+
+	key := "101/736/545/101736545.geojson"
+
+	records := []string{
+		`{"type":"Feature","properties":{"wof:id":101736545,"wof:lastmodified":2,"wof:repo":"whosonfirst-data-admin-ca"}}`,
+		`{"type":"Feature","properties":{"wof:id":101736545,"wof:lastmodified":3,"wof:repo":"whosonfirst-data-admin-xy"}}`,
+		`{"type":"Feature","properties":{"wof:id":101736545,"wof:lastmodified":1,"wof:repo":"whosonfirst-data-admin-us"}}`,
+	}
+
+	tests := []struct {
+		opts     *DeduplicatorOptions
+		expected string
+	}{
+		{&DeduplicatorOptions{Policy: DEDUPE_POLICY_FIRST}, "whosonfirst-data-admin-ca"},
+		{&DeduplicatorOptions{Policy: DEDUPE_POLICY_LASTMODIFIED}, "whosonfirst-data-admin-xy"},
+		{&DeduplicatorOptions{Policy: DEDUPE_POLICY_REPO, PreferRepo: "whosonfirst-data-admin-us"}, "whosonfirst-data-admin-us"},
+		{&DeduplicatorOptions{Policy: DEDUPE_POLICY_REPO, PreferRepo: "whosonfirst-data-admin-zz"}, "whosonfirst-data-admin-ca"},
+	}
+
+	for _, on_disk := range []bool{false, true} {
+
+		for _, test := range tests {
+
+			opts := *test.opts
+
+			if on_disk {
+				opts.Path = t.TempDir()
+			}
+
+			dd, err := NewDeduplicator(ctx, &opts)
+
+			if err != nil {
+				t.Fatalf("Failed to create deduplicator for '%s' policy, %v", opts.Policy, err)
+			}
+
+			wr := newCaptureWriter()
+
+			for _, body := range records {
+
+				_, err := dd.Write(ctx, wr, key, strings.NewReader(body))
+
+				if err != nil {
+					t.Fatalf("Failed to write record with '%s' policy, %v", opts.Policy, err)
+				}
+			}
+
+			err = dd.Flush(ctx)
+
+			if err != nil {
+				t.Fatalf("Failed to flush '%s' policy, %v", opts.Policy, err)
+			}
+
+			err = dd.Close()
+
+			if err != nil {
+				t.Fatalf("Failed to close '%s' policy, %v", opts.Policy, err)
+			}
+
+			captured := wr.Drain()
+
+			if len(captured) != 1 {
+				t.Fatalf("Expected 1 record for '%s' policy (on disk: %t), got %d", opts.Policy, on_disk, len(captured))
+			}
+
+			repo := gjson.GetBytes(captured[0].body, "properties.wof:repo").String()
+
+			if repo != test.expected {
+				t.Fatalf("Expected record from %s for '%s' policy (on disk: %t), got %s", test.expected, opts.Policy, on_disk, repo)
+			}
+		}
+	}
+}
+
+func TestNewDeduplicatorInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []*DeduplicatorOptions{
+		{Policy: "latest"},
+		{Policy: DEDUPE_POLICY_REPO},
+	}
+
+	for _, opts := range tests {
+
+		_, err := NewDeduplicator(ctx, opts)
+
+		if err == nil {
+			t.Fatalf("Expected options %v to be invalid", opts)
+		}
+	}
+}
+
+func TestDeduplicatorConcurrentWrite(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, path := range []string{"", t.TempDir()} {
+
+		dd, err := NewDeduplicator(ctx, &DeduplicatorOptions{Policy: DEDUPE_POLICY_FIRST, Path: path})
+
+		if err != nil {
+			t.Fatalf("Failed to create deduplicator, %v", err)
+		}
+
+		wr := newCaptureWriter()
+
+		ids := []int64{101736545, 85922583, 1108830809}
+
+		wg := new(sync.WaitGroup)
+		errs := make(chan error, 100*len(ids))
+
+		for i := 0; i < 100; i++ {
+
+			for _, id := range ids {
+
+				wg.Add(1)
+
+				go func(id int64) {
+
+					defer wg.Done()
+
+					key := fmt.Sprintf("%d.geojson", id)
+					body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d}}`, id)
+
+					_, err := dd.Write(ctx, wr, key, strings.NewReader(body))
+
+					if err != nil {
+						errs <- err
+					}
+				}(id)
+			}
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Fatalf("Failed to write record, %v", err)
+		}
+
+		err = dd.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to close deduplicator, %v", err)
+		}
+
+		captured := wr.Drain()
+
+		if len(captured) != len(ids) {
+			t.Fatalf("Expected %d records to be written (path: '%s'), got %d", len(ids), path, len(captured))
+		}
+	}
+}
+
+func TestDeduplicatorOnDiskCandidates(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	dd, err := NewDeduplicator(ctx, &DeduplicatorOptions{Policy: DEDUPE_POLICY_LASTMODIFIED, Path: root})
+
+	if err != nil {
+		t.Fatalf("Failed to create deduplicator, %v", err)
+	}
+
+	wr := newCaptureWriter()
+
+	for _, id := range []int64{85922583, 101736545} {
+
+		for lastmod := 1; lastmod <= 3; lastmod++ {
+
+			key := fmt.Sprintf("%d.geojson", id)
+			body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d,"wof:lastmodified":%d}}`, id, lastmod)
+
+			_, err := dd.Write(ctx, wr, key, strings.NewReader(body))
+
+			if err != nil {
+				t.Fatalf("Failed to write record, %v", err)
+			}
+		}
+	}
+
+	// The details of each candidate are stored on disk rather than in memory
+
+	if len(dd.candidates) != 0 {
+		t.Fatalf("Expected no candidates in memory, got %d", len(dd.candidates))
+	}
+
+	paths, err := dd.spooledCandidates()
+
+	if err != nil {
+		t.Fatalf("Failed to list candidates, %v", err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 candidates on disk, got %d", len(paths))
+	}
+
+	err = dd.Flush(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to flush, %v", err)
+	}
+
+	captured := wr.Drain()
+
+	if len(captured) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(captured))
+	}
+
+	for _, c := range captured {
+
+		if v := gjson.GetBytes(c.body, "properties.wof:lastmodified").Int(); v != 3 {
+			t.Fatalf("Expected most recent record for %s, got lastmodified %d", c.key, v)
+		}
+	}
+
+	err = dd.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close deduplicator, %v", err)
+	}
+
+	entries, err := os.ReadDir(root)
+
+	if err != nil {
+		t.Fatalf("Failed to read %s, %v", root, err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("Expected state to be removed, found %d files", len(entries))
+	}
+}
+
+func TestDeduplicatorCloseSpoolErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	dd, err := NewDeduplicator(ctx, &DeduplicatorOptions{Policy: DEDUPE_POLICY_LASTMODIFIED, Path: root})
+
+	if err != nil {
+		t.Fatalf("Failed to create deduplicator, %v", err)
+	}
+
+	key := "101736545.geojson"
+
+	_, err = dd.Write(ctx, newCaptureWriter(), key, strings.NewReader(`{"type":"Feature","properties":{"wof:id":101736545}}`))
+
+	if err != nil {
+		t.Fatalf("Failed to write record, %v", err)
+	}
+
+	// Replace the spooled record with a (non-empty) directory so that it can't be removed
+
+	spool_path := filepath.Join(root, key)
+
+	err = os.Remove(spool_path)
+
+	if err != nil {
+		t.Fatalf("Failed to remove %s, %v", spool_path, err)
+	}
+
+	err = os.MkdirAll(filepath.Join(spool_path, "child"), 0755)
+
+	if err != nil {
+		t.Fatalf("Failed to create %s, %v", spool_path, err)
+	}
+
+	err = dd.Close()
+
+	if err == nil {
+		t.Fatalf("Expected error removing spooled record")
+	}
+}
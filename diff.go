@@ -0,0 +1,551 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"iter"
+	"math"
+	"sort"
+
+	"github.com/tidwall/gjson"
+)
+
+// The feature exists in the current output but not the previous output.
+const DIFF_ADDED string = "added"
+
+// The feature exists in the previous output but not the current output.
+const DIFF_REMOVED string = "removed"
+
+// The feature exists in both outputs but its geometry and/or properties differ.
+const DIFF_MODIFIED string = "modified"
+
+// FeatureDiff is a struct describing how a feature differs between two outputs.
+type FeatureDiff struct {
+	// Id is the key for the feature: its ID plus the alternate geometry label if applicable.
+	Id string `json:"id"`
+	// Change is the type of change: `DIFF_ADDED`, `DIFF_REMOVED` or `DIFF_MODIFIED`.
+	Change string `json:"change"`
+	// Geometry is true if the feature's geometry has changed.
+	Geometry bool `json:"geometry,omitempty"`
+	// Properties is the sorted list of property keys that were added, removed or changed.
+	Properties []string `json:"properties,omitempty"`
+	// Body is the current version of the feature for added and modified features.
+	Body []byte `json:"-"`
+}
+
+// RawFeature is a struct wrapping a GeoJSON Feature read from JSON-L or FeatureCollection output.
+type RawFeature struct {
+	// Offset is the byte offset of the feature in its source.
+	Offset int64
+	// Body is the raw (JSON-encoded) feature.
+	Body []byte
+}
+
+type diffIndexEntry struct {
+	matched    bool
+	offset     int64
+	geometry   uint64
+	properties uint64
+}
+
+// ReadFeatures returns an `iter.Seq2[*RawFeature, error]` for each GeoJSON Feature in 'r', which may be either
+// a stream of (line-separated) features or a GeoJSON FeatureCollection. Features are read incrementally so
+// 'r' may be larger than available memory. The body of each feature is the exact bytes read from 'r'.
+func ReadFeatures(ctx context.Context, r io.Reader) iter.Seq2[*RawFeature, error] {
+
+	return func(yield func(*RawFeature, error) bool) {
+
+		rec := &recordingReader{
+			r: r,
+		}
+
+		dec := json.NewDecoder(rec)
+
+		for {
+
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			default:
+				// pass
+			}
+
+			rec.Discard(dec.InputOffset())
+
+			tok, err := dec.Token()
+
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to read token, %w", err))
+				return
+			}
+
+			if d, ok := tok.(json.Delim); !ok || d != '{' {
+				yield(nil, fmt.Errorf("Unexpected token '%v' at offset %d", tok, dec.InputOffset()))
+				return
+			}
+
+			start := dec.InputOffset() - 1
+			is_collection := false
+
+			for dec.More() {
+
+				k_tok, err := dec.Token()
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to read key, %w", err))
+					return
+				}
+
+				k := k_tok.(string)
+
+				if k != "features" {
+
+					var raw json.RawMessage
+
+					err := dec.Decode(&raw)
+
+					if err != nil {
+						yield(nil, fmt.Errorf("Failed to decode '%s', %w", k, err))
+						return
+					}
+
+					continue
+				}
+
+				is_collection = true
+
+				_, err = dec.Token()
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to read features, %w", err))
+					return
+				}
+
+				for dec.More() {
+
+					var raw json.RawMessage
+
+					err := dec.Decode(&raw)
+
+					if err != nil {
+						yield(nil, fmt.Errorf("Failed to decode feature, %w", err))
+						return
+					}
+
+					f := &RawFeature{
+						Offset: dec.InputOffset() - int64(len(raw)),
+						Body:   raw,
+					}
+
+					if !yield(f, nil) {
+						return
+					}
+
+					rec.Discard(dec.InputOffset())
+				}
+
+				_, err = dec.Token()
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to read end of features, %w", err))
+					return
+				}
+			}
+
+			_, err = dec.Token()
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to read end of object, %w", err))
+				return
+			}
+
+			if is_collection {
+				continue
+			}
+
+			f := &RawFeature{
+				Offset: start,
+				Body:   rec.Bytes(start, dec.InputOffset()),
+			}
+
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// recordingReader is an `io.Reader` which retains the bytes read from an underlying reader, until they are discarded,
+// so that the exact bytes for a range of offsets can be retrieved.
+type recordingReader struct {
+	r    io.Reader
+	buf  []byte
+	base int64
+}
+
+// Read reads from the underlying reader, retaining the bytes read.
+func (rec *recordingReader) Read(p []byte) (int, error) {
+	n, err := rec.r.Read(p)
+	rec.buf = append(rec.buf, p[:n]...)
+	return n, err
+}
+
+// Bytes returns a copy of the bytes read between the offsets 'start' and 'end'.
+func (rec *recordingReader) Bytes(start int64, end int64) []byte {
+	b := make([]byte, end-start)
+	copy(b, rec.buf[start-rec.base:end-rec.base])
+	return b
+}
+
+// Discard forgets the bytes read before the offset 'offset'.
+func (rec *recordingReader) Discard(offset int64) {
+
+	if offset <= rec.base {
+		return
+	}
+
+	rec.buf = rec.buf[offset-rec.base:]
+	rec.base = offset
+}
+
+// DiffFeatures returns an `iter.Seq2[*FeatureDiff, error]` for each feature which has been added, removed or modified
+// between 'previous' and 'current', each of which may be a stream of (line-separated) features or a GeoJSON FeatureCollection.
+// Only a compact index of 'previous' is kept in memory; features are re-read from 'previous' as necessary to determine which
+// properties have changed. Removed features are yielded, sorted by key, after all the features in 'current' have been read.
+func DiffFeatures(ctx context.Context, previous io.ReaderAt, current io.Reader) iter.Seq2[*FeatureDiff, error] {
+
+	return func(yield func(*FeatureDiff, error) bool) {
+
+		index := make(map[string]*diffIndexEntry)
+
+		prev_r := io.NewSectionReader(previous, 0, math.MaxInt64)
+
+		for f, err := range ReadFeatures(ctx, prev_r) {
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to read previous features, %w", err))
+				return
+			}
+
+			index[FeatureKey(f.Body)] = &diffIndexEntry{
+				offset:     f.Offset,
+				geometry:   hashGeometry(f.Body),
+				properties: hashProperties(f.Body),
+			}
+		}
+
+		added := make(map[string]bool)
+
+		for f, err := range ReadFeatures(ctx, current) {
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to read current features, %w", err))
+				return
+			}
+
+			k := FeatureKey(f.Body)
+			e, exists := index[k]
+
+			// Only consider the first instance of a feature that appears more than once
+
+			if added[k] || (exists && e.matched) {
+				continue
+			}
+
+			if !exists {
+
+				added[k] = true
+
+				d := &FeatureDiff{
+					Id:     k,
+					Change: DIFF_ADDED,
+					Body:   f.Body,
+				}
+
+				if !yield(d, nil) {
+					return
+				}
+
+				continue
+			}
+
+			e.matched = true
+
+			geom_changed := e.geometry != hashGeometry(f.Body)
+			props_changed := e.properties != hashProperties(f.Body)
+
+			if !geom_changed && !props_changed {
+				continue
+			}
+
+			d := &FeatureDiff{
+				Id:       k,
+				Change:   DIFF_MODIFIED,
+				Geometry: geom_changed,
+				Body:     f.Body,
+			}
+
+			if props_changed {
+
+				prev_body, err := readFeatureAt(previous, e.offset)
+
+				if err != nil {
+					yield(nil, fmt.Errorf("Failed to read previous version of %s, %w", k, err))
+					return
+				}
+
+				d.Properties = diffProperties(prev_body, f.Body)
+			}
+
+			if !yield(d, nil) {
+				return
+			}
+		}
+
+		removed := make([]string, 0, len(index))
+
+		for k, e := range index {
+
+			if !e.matched {
+				removed = append(removed, k)
+			}
+		}
+
+		sort.Strings(removed)
+
+		for _, k := range removed {
+
+			d := &FeatureDiff{
+				Id:     k,
+				Change: DIFF_REMOVED,
+			}
+
+			if !yield(d, nil) {
+				return
+			}
+		}
+	}
+}
+
+// DiffManifests returns the list of features which have been added, removed or modified between two `Manifest` instances.
+// Since manifests only record a hash for each feature the details of modifications are not known.
+func DiffManifests(previous *Manifest, current *Manifest) []*FeatureDiff {
+
+	diffs := make([]*FeatureDiff, 0)
+
+	keys := make([]string, 0, len(current.Features))
+
+	for k := range current.Features {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		prev_hash, exists := previous.Features[k]
+
+		switch {
+		case !exists:
+			diffs = append(diffs, &FeatureDiff{Id: k, Change: DIFF_ADDED})
+		case prev_hash != current.Features[k]:
+			diffs = append(diffs, &FeatureDiff{Id: k, Change: DIFF_MODIFIED})
+		}
+	}
+
+	removed := make([]string, 0)
+
+	for k := range previous.Features {
+
+		_, exists := current.Features[k]
+
+		if !exists {
+			removed = append(removed, k)
+		}
+	}
+
+	sort.Strings(removed)
+
+	for _, k := range removed {
+		diffs = append(diffs, &FeatureDiff{Id: k, Change: DIFF_REMOVED})
+	}
+
+	return diffs
+}
+
+// FeatureKey returns the key used to identify the feature 'body' across outputs. If 'body' has a "part_key" property,
+// assigned to the parts of exploded features, that is returned as-is. Otherwise the key is its "wof:id" property (or
+// top-level "id" if absent) plus, for features created by exploding a geometry, the "part_index" property and,
+// for alternate geometries, the "src:alt_label" property. Keys take the form "{ID}", "{ID}-alt-{LABEL}",
+// "{ID}-alt-part-{INDEX}" or "{ID}-alt-part-{INDEX}-{LABEL}". These are the same as the keys used in `Manifest`
+// instances, which are the file names (without extension) of the relative paths that features are written to.
+func FeatureKey(body []byte) string {
+
+	// The parts of alternate geometries would otherwise share the keys of the parts of the principal geometry
+
+	key_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", EXPLODE_PART_KEY_PROPERTY))
+
+	if key_rsp.Exists() && key_rsp.String() != "" {
+		return key_rsp.String()
+	}
+
+	id_rsp := gjson.GetBytes(body, "properties.wof:id")
+
+	if !id_rsp.Exists() {
+		id_rsp = gjson.GetBytes(body, "id")
+	}
+
+	k := id_rsp.String()
+
+	part_rsp := gjson.GetBytes(body, fmt.Sprintf("properties.%s", EXPLODE_PART_PROPERTY))
+
+	if part_rsp.Exists() {
+		k = fmt.Sprintf("%s-alt-%s-%d", k, FEATURE_PART_SOURCE, part_rsp.Int())
+	}
+
+	label_rsp := gjson.GetBytes(body, "properties.src:alt_label")
+
+	if label_rsp.Exists() && label_rsp.String() != "" {
+
+		if part_rsp.Exists() {
+			k = fmt.Sprintf("%s-%s", k, label_rsp.String())
+		} else {
+			k = fmt.Sprintf("%s-alt-%s", k, label_rsp.String())
+		}
+	}
+
+	return k
+}
+
+func readFeatureAt(r io.ReaderAt, offset int64) ([]byte, error) {
+
+	sr := io.NewSectionReader(r, offset, math.MaxInt64-offset)
+	dec := json.NewDecoder(sr)
+
+	var raw json.RawMessage
+
+	err := dec.Decode(&raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+func hashGeometry(body []byte) uint64 {
+
+	// Hash the geometry type and coordinates separately since different writers
+	// encode the keys of the geometry object in different orders.
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	h := fnv.New64a()
+	h.Write([]byte(geom_rsp.Get("type").String()))
+	h.Write([]byte{0})
+	h.Write(canonicalJSON(geom_rsp.Get("coordinates").Raw, false))
+	h.Write([]byte{0})
+	h.Write(canonicalJSON(geom_rsp.Get("geometries").Raw, true))
+
+	return h.Sum64()
+}
+
+// hashProperties returns a hash of the properties in 'body' which is independent of the order of those properties.
+func hashProperties(body []byte) uint64 {
+
+	sum := uint64(0)
+
+	for k, v := range propertyValues(body) {
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(v)
+		sum += h.Sum64()
+	}
+
+	return sum
+}
+
+func diffProperties(previous []byte, current []byte) []string {
+
+	prev_props := propertyValues(previous)
+	curr_props := propertyValues(current)
+
+	keys := make([]string, 0)
+
+	for k, v := range curr_props {
+
+		prev_v, exists := prev_props[k]
+
+		if !exists || !bytes.Equal(v, prev_v) {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range prev_props {
+
+		_, exists := curr_props[k]
+
+		if !exists {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func propertyValues(body []byte) map[string][]byte {
+
+	values := make(map[string][]byte)
+
+	props_rsp := gjson.GetBytes(body, "properties")
+
+	props_rsp.ForEach(func(k gjson.Result, v gjson.Result) bool {
+		values[k.String()] = canonicalJSON(v.Raw, true)
+		return true
+	})
+
+	return values
+}
+
+// canonicalJSON returns a compact representation of 'raw'. If 'sort_keys' is true then objects (and objects nested
+// in arrays) are re-encoded so that their keys are sorted.
+func canonicalJSON(raw string, sort_keys bool) []byte {
+
+	if sort_keys && len(raw) > 0 && (raw[0] == '{' || raw[0] == '[') {
+
+		var v interface{}
+
+		err := json.Unmarshal([]byte(raw), &v)
+
+		if err == nil {
+
+			enc, err := json.Marshal(v)
+
+			if err == nil {
+				return enc
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err := json.Compact(&buf, []byte(raw))
+
+	if err != nil {
+		return []byte(raw)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,173 @@
+package tippecanoe
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFeatureKey(t *testing.T) {
+
+	tests := map[string]string{
+		`{"properties":{"wof:id":101736545}}`:                                                                "101736545",
+		`{"id":101736545,"properties":{}}`:                                                                   "101736545",
+		`{"properties":{"wof:id":101736545,"src:alt_label":"quattroshapes"}}`:                                "101736545-alt-quattroshapes",
+		`{"properties":{"wof:id":101736545,"src:alt_label":""}}`:                                             "101736545",
+		`{"properties":{"wof:id":101736545,"part_index":0}}`:                                                 "101736545-alt-part-0",
+		`{"properties":{"wof:id":101736545,"part_index":2}}`:                                                 "101736545-alt-part-2",
+		`{"properties":{"wof:id":101736545,"part_index":2,"src:alt_label":"quattroshapes"}}`:                 "101736545-alt-part-2-quattroshapes",
+		`{"properties":{"wof:id":101736545,"part_index":0,"part_key":"101736545-alt-quattroshapes-part-0"}}`: "101736545-alt-quattroshapes-part-0",
+	}
+
+	for body, expected := range tests {
+
+		k := FeatureKey([]byte(body))
+
+		if k != expected {
+			t.Fatalf("Expected key '%s' for %s, got '%s'", expected, body, k)
+		}
+	}
+}
+
+func TestReadFeaturesPreservesBytes(t *testing.T) {
+
+	ctx := context.Background()
+
+	lines := []string{
+		`{"type":"Feature","properties":{"wof:name":"A&W <drive-in>","wof:id":1},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		`{"properties":{"wof:id":2},  "type":"Feature","geometry":{"coordinates":[1,1],"type":"Point"}}`,
+	}
+
+	inputs := map[string][]string{
+		strings.Join(lines, "\n") + "\n":                                             lines,
+		`{"type":"FeatureCollection","features":[` + strings.Join(lines, ",") + `]}`: lines,
+	}
+
+	for input, expected := range inputs {
+
+		// Read one byte at a time to ensure that features which span multiple reads are preserved
+
+		i := 0
+
+		for f, err := range ReadFeatures(ctx, iotest.OneByteReader(strings.NewReader(input))) {
+
+			if err != nil {
+				t.Fatalf("Failed to read features, %v", err)
+			}
+
+			if string(f.Body) != expected[i] {
+				t.Fatalf("Expected feature %d to be '%s', got '%s'", i, expected[i], f.Body)
+			}
+
+			if input[f.Offset:f.Offset+int64(len(f.Body))] != expected[i] {
+				t.Fatalf("Expected offset %d for feature %d to point to its body", f.Offset, i)
+			}
+
+			i += 1
+		}
+
+		if i != len(expected) {
+			t.Fatalf("Expected %d features, got %d", len(expected), i)
+		}
+	}
+}
+
+func TestDiffFeatures(t *testing.T) {
+
+	ctx := context.Background()
+
+	previous := strings.Join([]string{
+		`{"type":"Feature","properties":{"wof:id":1,"wof:name":"Unchanged"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		`{"type":"Feature","properties":{"wof:id":2,"wof:name":"Moved"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		`{"type":"Feature","properties":{"wof:id":3,"wof:name":"Renamed","wof:placetype":"locality"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		`{"type":"Feature","properties":{"wof:id":4,"wof:name":"Removed"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+	}, "\n")
+
+	// The current output is a FeatureCollection whose features encode their keys in a different order
+
+	current := `{"type":"FeatureCollection","features":[` + strings.Join([]string{
+		`{"geometry":{"coordinates":[0,0],"type":"Point"},"properties":{"wof:name":"Unchanged","wof:id":1},"type":"Feature"}`,
+		`{"type":"Feature","properties":{"wof:id":2,"wof:name":"Moved"},"geometry":{"type":"Point","coordinates":[1,1]}}`,
+		`{"type":"Feature","properties":{"wof:id":3,"wof:name":"Renamed again","wof:population":10},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		`{"type":"Feature","properties":{"wof:id":5,"wof:name":"Added"},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+	}, ",") + `]}`
+
+	expected := map[string]*FeatureDiff{
+		"2": {Change: DIFF_MODIFIED, Geometry: true},
+		"3": {Change: DIFF_MODIFIED, Properties: []string{"wof:name", "wof:placetype", "wof:population"}},
+		"5": {Change: DIFF_ADDED},
+		"4": {Change: DIFF_REMOVED},
+	}
+
+	count := 0
+
+	for d, err := range DiffFeatures(ctx, strings.NewReader(previous), strings.NewReader(current)) {
+
+		if err != nil {
+			t.Fatalf("Failed to diff features, %v", err)
+		}
+
+		e, ok := expected[d.Id]
+
+		if !ok {
+			t.Fatalf("Unexpected diff for %s (%s)", d.Id, d.Change)
+		}
+
+		if d.Change != e.Change || d.Geometry != e.Geometry || strings.Join(d.Properties, ",") != strings.Join(e.Properties, ",") {
+			t.Fatalf("Unexpected diff for %s, expected %s/%t/%v, got %s/%t/%v", d.Id, e.Change, e.Geometry, e.Properties, d.Change, d.Geometry, d.Properties)
+		}
+
+		if (d.Change == DIFF_REMOVED) != (len(d.Body) == 0) {
+			t.Fatalf("Expected body only for added and modified features, %s", d.Id)
+		}
+
+		count += 1
+	}
+
+	if count != len(expected) {
+		t.Fatalf("Expected %d diffs, got %d", len(expected), count)
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+
+	previous := &Manifest{
+		Features: map[string]string{
+			"1":                   "aaa",
+			"2":                   "bbb",
+			"3":                   "ccc",
+			"3-alt-quattroshapes": "ddd",
+		},
+	}
+
+	current := &Manifest{
+		Features: map[string]string{
+			"1":                   "aaa",
+			"2":                   "bbb2",
+			"3-alt-quattroshapes": "ddd",
+			"4":                   "eee",
+		},
+	}
+
+	expected := []string{
+		"2:" + DIFF_MODIFIED,
+		"4:" + DIFF_ADDED,
+		"3:" + DIFF_REMOVED,
+	}
+
+	diffs := DiffManifests(previous, current)
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d diffs, got %d", len(expected), len(diffs))
+	}
+
+	for i, d := range diffs {
+
+		str_d := d.Id + ":" + d.Change
+
+		if str_d != expected[i] {
+			t.Fatalf("Expected diff %d to be '%s', got '%s'", i, expected[i], str_d)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sfomuseum/go-edtf"
+	"github.com/sfomuseum/go-edtf/parser"
+	"github.com/tidwall/gjson"
+)
+
+// Encode EDTF attributes as Unix timestamps (seconds).
+const EDTF_FORMAT_UNIX string = "unix"
+
+// Encode EDTF attributes as decimal years.
+const EDTF_FORMAT_YEAR string = "year"
+
+// EDTF_MIN_TIME is the time used for the lower bound of open-ended EDTF values (for example an inception date of "..").
+var EDTF_MIN_TIME = time.Date(-edtf.MAX_YEARS, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// EDTF_MAX_TIME is the time used for the upper bound of open-ended EDTF values (for example a cessation date of "..").
+var EDTF_MAX_TIME = time.Date(edtf.MAX_YEARS, 12, 31, 23, 59, 59, 0, time.UTC)
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "edtf", NewEDTFTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ParseEDTF parses 'edtf_str' replacing deprecated (pre-2019 specification) values like "uuuu" and "open" in the
+// same way that whosonfirst/go-whosonfirst-spr does.
+func ParseEDTF(edtf_str string) (*edtf.EDTFDate, error) {
+
+	d, err := parser.ParseString(edtf_str)
+
+	if err == nil {
+		return d, nil
+	}
+
+	if !edtf.IsDeprecated(edtf_str) {
+		return nil, err
+	}
+
+	replacement, err := edtf.ReplaceDeprecated(edtf_str)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ParseString(replacement)
+}
+
+// EDTFBounds returns the lower and upper bounds of 'edtf_str'. If 'edtf_str' is open ("..") then both bounds are 'open'.
+// Open bounds in intervals (for example "2020/..") are `EDTF_MIN_TIME` or `EDTF_MAX_TIME`. Unknown (or unspecified) bounds
+// are returned as nil.
+func EDTFBounds(edtf_str string, open time.Time) (*time.Time, *time.Time, error) {
+
+	d, err := ParseEDTF(edtf_str)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse EDTF string '%s', %w", edtf_str, err)
+	}
+
+	if edtf.IsOpen(d.EDTF) {
+		return &open, &open, nil
+	}
+
+	var lower *time.Time
+	var upper *time.Time
+
+	if d.Start != nil && d.Start.Lower != nil {
+
+		switch {
+		case d.Start.Lower.Timestamp != nil:
+			lower = d.Start.Lower.Timestamp.Time()
+		case d.Start.Lower.Open:
+			t := EDTF_MIN_TIME
+			lower = &t
+		}
+	}
+
+	if d.End != nil && d.End.Upper != nil {
+
+		switch {
+		case d.End.Upper.Timestamp != nil:
+			upper = d.End.Upper.Timestamp.Time()
+		case d.End.Upper.Open:
+			t := EDTF_MAX_TIME
+			upper = &t
+		}
+	}
+
+	return lower, upper, nil
+}
+
+// EDTFTransformer implements the `Transformer` interface adding numeric "inception_lower", "inception_upper", "cessation_lower"
+// and "cessation_upper" properties derived from the "edtf:inception" and "edtf:cessation" properties of a feature so that they
+// can be used in (MapLibre) filter expressions. Open inception dates are encoded as `EDTF_MIN_TIME` and open cessation dates as
+// `EDTF_MAX_TIME`. Properties for unknown (or unspecified) dates are not added.
+type EDTFTransformer struct {
+	Transformer
+	// Format is the encoding for numeric properties. Valid options are `EDTF_FORMAT_UNIX` and `EDTF_FORMAT_YEAR`.
+	Format string
+}
+
+// NewEDTFTransformer returns a new `EDTFTransformer` instance configured by 'uri' in the form of:
+//
+//	edtf://?format={FORMAT}
+//
+// Where {FORMAT} is (optionally) "unix" (Unix timestamps, the default) or "year" (decimal years).
+func NewEDTFTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	format := EDTF_FORMAT_UNIX
+
+	q := u.Query()
+
+	if q.Has("format") {
+		format = q.Get("format")
+	}
+
+	switch format {
+	case EDTF_FORMAT_UNIX, EDTF_FORMAT_YEAR:
+		// pass
+	default:
+		return nil, fmt.Errorf("Invalid '?format=' parameter, %s", format)
+	}
+
+	t := &EDTFTransformer{
+		Format: format,
+	}
+
+	return t, nil
+}
+
+// Transform adds numeric EDTF properties to 'f'.
+func (t *EDTFTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	dates := []struct {
+		property string
+		prefix   string
+		open     time.Time
+	}{
+		{"properties.edtf:inception", "inception", EDTF_MIN_TIME},
+		{"properties.edtf:cessation", "cessation", EDTF_MAX_TIME},
+	}
+
+	for _, d := range dates {
+
+		rsp := gjson.GetBytes(body, d.property)
+
+		if !rsp.Exists() {
+			continue
+		}
+
+		lower, upper, err := EDTFBounds(rsp.String(), d.open)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive bounds for %s (%s), %w", d.property, f.Path, err)
+		}
+
+		bounds := make([]property, 0)
+
+		if lower != nil {
+			bounds = append(bounds, property{fmt.Sprintf("%s_lower", d.prefix), t.encode(*lower)})
+		}
+
+		if upper != nil {
+			bounds = append(bounds, property{fmt.Sprintf("%s_upper", d.prefix), t.encode(*upper)})
+		}
+
+		body, err = setPropertiesInOrder(body, bounds)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+func (t *EDTFTransformer) encode(v time.Time) interface{} {
+
+	if t.Format == EDTF_FORMAT_YEAR {
+		return DecimalYear(v)
+	}
+
+	return v.Unix()
+}
+
+// DecimalYear returns 't' as a decimal year, for example 1962.5 for the middle of 1962.
+func DecimalYear(t time.Time) float64 {
+
+	t = t.UTC()
+
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(t.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return float64(t.Year()) + t.Sub(start).Seconds()/end.Sub(start).Seconds()
+}
+
+// EDTFRange is a struct defining a range of time used to filter features by their EDTF inception and cessation dates.
+type EDTFRange struct {
+	// Start is the start of the range.
+	Start time.Time
+	// End is the end of the range.
+	End time.Time
+}
+
+// ParseValidAt returns a new `EDTFRange` instance spanning the lower and upper bounds of 'str', which may be any
+// valid EDTF string (for example "1962", "1962-06" or "1962-06-01").
+func ParseValidAt(str string) (*EDTFRange, error) {
+	return ParseValidBetween(str, str)
+}
+
+// ParseValidBetween returns a new `EDTFRange` instance spanning the lower bound of 'start' and the upper bound of 'end',
+// both of which may be any valid EDTF string.
+func ParseValidBetween(start string, end string) (*EDTFRange, error) {
+
+	start_lower, _, err := EDTFBounds(start, EDTF_MIN_TIME)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, end_upper, err := EDTFBounds(end, EDTF_MAX_TIME)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if start_lower == nil || end_upper == nil {
+		return nil, fmt.Errorf("Range can not have unknown bounds")
+	}
+
+	if end_upper.Before(*start_lower) {
+		return nil, fmt.Errorf("End of range (%s) is before start of range (%s)", end, start)
+	}
+
+	r := &EDTFRange{
+		Start: *start_lower,
+		End:   *end_upper,
+	}
+
+	return r, nil
+}
+
+// Overlaps returns true if the interval defined by 'lower' and 'upper' overlaps 'r'. A nil value for either 'lower'
+// or 'upper' is treated as unbounded.
+func (r *EDTFRange) Overlaps(lower *time.Time, upper *time.Time) bool {
+
+	if lower != nil && lower.After(r.End) {
+		return false
+	}
+
+	if upper != nil && upper.Before(r.Start) {
+		return false
+	}
+
+	return true
+}
+
+// EDTFRangeFilter implements the `Filter` interface excluding records whose EDTF inception and cessation dates do not
+// overlap a range of time.
+type EDTFRangeFilter struct {
+	Filter
+	// Range is the `EDTFRange` instance that records must overlap.
+	Range *EDTFRange
+}
+
+// Include returns true if the interval between the lower bound of the "edtf:inception" property and the upper bound of
+// the "edtf:cessation" property of 'f' overlaps 'filter.Range'. Unknown (or missing) dates are treated as unbounded so
+// records are only excluded when they are known to fall outside the range.
+func (filter *EDTFRangeFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	var lower *time.Time
+	var upper *time.Time
+
+	inception_rsp := gjson.GetBytes(body, "properties.edtf:inception")
+
+	if inception_rsp.Exists() {
+
+		l, _, err := EDTFBounds(inception_rsp.String(), EDTF_MIN_TIME)
+
+		if err != nil {
+			return false, fmt.Errorf("Failed to derive bounds for inception date (%s), %w", f.Path, err)
+		}
+
+		lower = l
+	}
+
+	cessation_rsp := gjson.GetBytes(body, "properties.edtf:cessation")
+
+	if cessation_rsp.Exists() {
+
+		_, u, err := EDTFBounds(cessation_rsp.String(), EDTF_MAX_TIME)
+
+		if err != nil {
+			return false, fmt.Errorf("Failed to derive bounds for cessation date (%s), %w", f.Path, err)
+		}
+
+		upper = u
+	}
+
+	return filter.Range.Overlaps(lower, upper), nil
+}
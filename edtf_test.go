@@ -0,0 +1,117 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestEDTFBounds(t *testing.T) {
+
+	open := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string][2]string{
+		"2020-06-01":    {"2020-06-01", "2020-06-01"},
+		"2020":          {"2020-01-01", "2020-12-31"},
+		"2019/2021":     {"2019-01-01", "2021-12-31"},
+		"2020/..":       {"2020-01-01", EDTF_MAX_TIME.Format(time.DateOnly)},
+		"../2020-06-01": {EDTF_MIN_TIME.Format(time.DateOnly), "2020-06-01"},
+		"..":            {"2025-01-01", "2025-01-01"},
+		"uuuu":          {"", ""},
+	}
+
+	for edtf_str, expected := range tests {
+
+		lower, upper, err := EDTFBounds(edtf_str, open)
+
+		if err != nil {
+			t.Fatalf("Failed to derive bounds for '%s', %v", edtf_str, err)
+		}
+
+		for i, b := range []*time.Time{lower, upper} {
+
+			str_b := ""
+
+			if b != nil {
+				str_b = b.UTC().Format(time.DateOnly)
+			}
+
+			if str_b != expected[i] {
+				t.Fatalf("Expected bound %d of '%s' to be '%s', got '%s'", i, edtf_str, expected[i], str_b)
+			}
+		}
+	}
+
+	_, _, err := EDTFBounds("not a date", open)
+
+	if err == nil {
+		t.Fatalf("Expected invalid EDTF string to fail")
+	}
+}
+
+// testPropertyNames returns the names of the properties of the feature 'body' in the order they are encoded.
+func testPropertyNames(body []byte) []string {
+
+	names := make([]string, 0)
+
+	gjson.GetBytes(body, "properties").ForEach(func(k gjson.Result, v gjson.Result) bool {
+		names = append(names, k.String())
+		return true
+	})
+
+	return names
+}
+
+func TestEDTFTransformerPropertyOrder(t *testing.T) {
+
+	ctx := context.Background()
+
+	tr, err := NewEDTFTransformer(ctx, "edtf://")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	body := `{"type":"Feature","properties":{"wof:id":1,"edtf:inception":"2019/2021","edtf:cessation":"2022"},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	expected := "wof:id,edtf:inception,edtf:cessation,inception_lower,inception_upper,cessation_lower,cessation_upper"
+
+	var previous []byte
+
+	// Properties must be assigned in the same order for every run so that output (and manifest hashes) don't change
+
+	for i := 0; i < 20; i++ {
+
+		f, err := NewFeature("1.geojson", strings.NewReader(body))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		err = tr.Transform(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to transform feature, %v", err)
+		}
+
+		out, err := f.Body()
+
+		if err != nil {
+			t.Fatalf("Failed to derive body, %v", err)
+		}
+
+		if names := strings.Join(testPropertyNames(out), ","); names != expected {
+			t.Fatalf("Expected properties %s, got %s", expected, names)
+		}
+
+		if previous != nil && !bytes.Equal(out, previous) {
+			t.Fatalf("Expected identical output for every run")
+		}
+
+		previous = out
+	}
+}
@@ -0,0 +1,343 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// The name of the property that the (zero-based) index of each part of an exploded feature is assigned to.
+const EXPLODE_PART_PROPERTY string = "part_index"
+
+// The name of the property that the unique key (see `FeatureKey`) of each part of an exploded feature is assigned to.
+// Parts share the "wof:id" property of the feature they were exploded from so this can be used to distinguish them.
+const EXPLODE_PART_KEY_PROPERTY string = "part_key"
+
+// The layer name assigned to Point and MultiPoint geometries.
+const EXPLODE_LAYER_POINTS string = "points"
+
+// The layer name assigned to LineString and MultiLineString geometries.
+const EXPLODE_LAYER_LINES string = "lines"
+
+// The layer name assigned to Polygon and MultiPolygon geometries.
+const EXPLODE_LAYER_POLYGONS string = "polygons"
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "explode", NewExplodeSplitter)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ExplodeSplitter implements the `Splitter` (and `Transformer`) interface replacing features whose geometry is a
+// GeometryCollection (and optionally a MultiPolygon) with a feature for each member geometry. Nested collections
+// are flattened and, if `MultiPolygons` is true, their MultiPolygon members are exploded too. Each part has the same
+// properties as the original feature plus "part_index" and "part_key" properties and a relative path derived using the
+// `Feature.NewPart` method. Features with other geometry types are left unchanged and features with empty GeometryCollections
+// are removed. If `Layers` is true each part of a GeometryCollection is assigned a "tippecanoe.layer" value of "points",
+// "lines" or "polygons" (preceded by `LayerPrefix`) derived from its geometry type. The parts of MultiPolygons, and
+// features which are not exploded, are never assigned a layer so that all polygons stay in the same layer whether or
+// not they were exploded.
+type ExplodeSplitter struct {
+	Transformer
+	// Collections is a boolean flag signaling that GeometryCollection geometries should be exploded.
+	Collections bool
+	// MultiPolygons is a boolean flag signaling that MultiPolygon geometries should also be exploded.
+	MultiPolygons bool
+	// Layers is a boolean flag signaling that a "tippecanoe.layer" value should be assigned to each part of a GeometryCollection.
+	Layers bool
+	// LayerPrefix is an optional string prepended to layer names.
+	LayerPrefix string
+}
+
+// NewExplodeSplitter returns a new `ExplodeSplitter` instance configured by 'uri' in the form of:
+//
+//	explode://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?collections=` A boolean value indicating whether GeometryCollection geometries should be exploded. Default is true.
+// * `?multipolygons=` A boolean value indicating whether MultiPolygon geometries should also be exploded. Default is false.
+// * `?layers=` A boolean value indicating whether a "tippecanoe.layer" value should be assigned to each part of a GeometryCollection. Default is true.
+// * `?layer-prefix=` An optional string prepended to layer names.
+//
+// The return value is a `Transformer` so that it can be registered using `RegisterTransformer`; `Pipeline.AddTransformer`
+// adds it as a `Splitter` stage.
+func NewExplodeSplitter(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	s := &ExplodeSplitter{
+		Collections: true,
+		Layers:      true,
+	}
+
+	q := u.Query()
+
+	bools := map[string]*bool{
+		"collections":   &s.Collections,
+		"multipolygons": &s.MultiPolygons,
+		"layers":        &s.Layers,
+	}
+
+	for k, ptr := range bools {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.ParseBool(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?%s=' parameter, %w", k, err)
+		}
+
+		*ptr = v
+	}
+
+	if q.Has("layer-prefix") {
+		s.LayerPrefix = q.Get("layer-prefix")
+	}
+
+	return s, nil
+}
+
+// Transform returns an error since 's' replaces features rather than modifying them. Use the `Split` method (or
+// `Pipeline.AddTransformer` which adds 's' as a `Splitter` stage) instead.
+func (s *ExplodeSplitter) Transform(ctx context.Context, f *Feature) error {
+	return fmt.Errorf("ExplodeSplitter must be used as a Splitter")
+}
+
+// Split returns a new `Feature` for each member of the geometry of 'f' if it is a GeometryCollection (and 's.Collections'
+// is true) or a MultiPolygon (and 's.MultiPolygons' is true) or a single-item list containing 'f'.
+func (s *ExplodeSplitter) Split(ctx context.Context, f *Feature) ([]*Feature, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []orb.Geometry
+
+	is_collection := false
+
+	switch gjson.GetBytes(body, "geometry.type").String() {
+	case "GeometryCollection":
+
+		if s.Collections {
+
+			geom, err := f.Geometry()
+
+			if err != nil {
+				return nil, err
+			}
+
+			parts = flattenCollection(geom.Geometry(), s.MultiPolygons)
+			is_collection = true
+		}
+
+	case "MultiPolygon":
+
+		if s.MultiPolygons {
+
+			geom, err := f.Geometry()
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, poly := range geom.Geometry().(orb.MultiPolygon) {
+				parts = append(parts, poly)
+			}
+		}
+	}
+
+	if parts == nil {
+		return []*Feature{f}, nil
+	}
+
+	features := make([]*Feature, len(parts))
+
+	for idx, g := range parts {
+
+		part_body, err := s.partBody(body, idx, g)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive part %d for %s, %w", idx, f.Path, err)
+		}
+
+		part, err := f.NewPart(idx, part_body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.assignKey(part)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if is_collection {
+
+			err = s.assignLayer(part)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		features[idx] = part
+	}
+
+	return features, nil
+}
+
+func (s *ExplodeSplitter) partBody(body []byte, idx int, g orb.Geometry) ([]byte, error) {
+
+	enc, err := json.Marshal(geojson.NewGeometry(g))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal geometry, %w", err)
+	}
+
+	// Copy body so that each part is updated independently
+
+	part_body := make([]byte, len(body))
+	copy(part_body, body)
+
+	part_body, err = sjson.SetRawBytes(part_body, "geometry", enc)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to assign geometry, %w", err)
+	}
+
+	part_body, err = sjson.SetBytes(part_body, fmt.Sprintf("properties.%s", EXPLODE_PART_PROPERTY), idx)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to assign %s, %w", EXPLODE_PART_PROPERTY, err)
+	}
+
+	if gjson.GetBytes(part_body, "bbox").Exists() {
+
+		b := g.Bound()
+
+		part_body, err = sjson.SetBytes(part_body, "bbox", []float64{b.Min.X(), b.Min.Y(), b.Max.X(), b.Max.Y()})
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to assign bbox, %w", err)
+		}
+	}
+
+	return part_body, nil
+}
+
+// assignKey assigns the `FeatureKey` of the part 'f' to its "part_key" property. The key is derived from the
+// relative path of 'f', which is unique to each part, rather than its properties since "src:alt_label" may
+// not be present in the properties of alternate geometries.
+func (s *ExplodeSplitter) assignKey(f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", EXPLODE_PART_KEY_PROPERTY), partKey(f))
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign %s, %w", EXPLODE_PART_KEY_PROPERTY, err)
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+// partKey returns the key assigned to the "part_key" property of 'f' which is the base name, without an extension,
+// of its relative path (for example "1234-alt-part-0").
+func partKey(f *Feature) string {
+	return strings.TrimSuffix(filepath.Base(f.RelPath), filepath.Ext(f.RelPath))
+}
+
+func (s *ExplodeSplitter) assignLayer(f *Feature) error {
+
+	if !s.Layers {
+		return nil
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	var layer string
+
+	switch gjson.GetBytes(body, "geometry.type").String() {
+	case "Point", "MultiPoint":
+		layer = EXPLODE_LAYER_POINTS
+	case "LineString", "MultiLineString":
+		layer = EXPLODE_LAYER_LINES
+	case "Polygon", "MultiPolygon":
+		layer = EXPLODE_LAYER_POLYGONS
+	default:
+		return nil
+	}
+
+	body, err = sjson.SetBytes(body, "tippecanoe.layer", s.LayerPrefix+layer)
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign tippecanoe.layer, %w", err)
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+// flattenCollection returns the list of member geometries in 'g', flattening nested collections and, if 'multipolygons'
+// is true, replacing MultiPolygons with their polygons.
+func flattenCollection(g orb.Geometry, multipolygons bool) []orb.Geometry {
+
+	parts := make([]orb.Geometry, 0)
+
+	switch geom := g.(type) {
+	case orb.Collection:
+
+		for _, c := range geom {
+			parts = append(parts, flattenCollection(c, multipolygons)...)
+		}
+
+	case orb.MultiPolygon:
+
+		if !multipolygons {
+			parts = append(parts, g)
+			break
+		}
+
+		for _, poly := range geom {
+			parts = append(parts, poly)
+		}
+
+	default:
+		parts = append(parts, g)
+	}
+
+	return parts
+}
@@ -0,0 +1,243 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const testCollectionFeature string = `{"type":"Feature","properties":{"wof:id":1234,"wof:placetype":"venue"},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[0,0],[1,1]]},{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}]}}`
+
+func TestExplodeSplitter(t *testing.T) {
+
+	ctx := context.Background()
+
+	s := &ExplodeSplitter{
+		Collections: true,
+		Layers:      true,
+	}
+
+	f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(testCollectionFeature)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	parts, err := s.Split(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to split feature, %v", err)
+	}
+
+	expected := []string{EXPLODE_LAYER_POINTS, EXPLODE_LAYER_LINES, EXPLODE_LAYER_POLYGONS}
+
+	if len(parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(parts))
+	}
+
+	for idx, part := range parts {
+
+		body, err := part.Body()
+
+		if err != nil {
+			t.Fatalf("Failed to read part %d, %v", idx, err)
+		}
+
+		if layer := gjson.GetBytes(body, "tippecanoe.layer").String(); layer != expected[idx] {
+			t.Fatalf("Expected layer '%s' for part %d, got '%s'", expected[idx], idx, layer)
+		}
+
+		key := gjson.GetBytes(body, "properties.part_key").String()
+
+		if key != FeatureKey(body) {
+			t.Fatalf("Expected part_key '%s' to match feature key '%s'", key, FeatureKey(body))
+		}
+	}
+
+	// Features which aren't exploded are not assigned a layer
+
+	point, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(`{"type":"Feature","properties":{"wof:id":1234},"geometry":{"type":"Point","coordinates":[0,0]}}`)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	unchanged, err := s.Split(ctx, point)
+
+	if err != nil {
+		t.Fatalf("Failed to split feature, %v", err)
+	}
+
+	body, _ := unchanged[0].Body()
+
+	if gjson.GetBytes(body, "tippecanoe").Exists() {
+		t.Fatalf("Expected feature which was not exploded to not be assigned a layer")
+	}
+}
+
+func TestFeatureNewPartAlternate(t *testing.T) {
+
+	tests := map[string]string{
+		"123/4/1234.geojson":                            "123/4/1234-alt-part-2.geojson",
+		"123/4/1234-alt-quattroshapes.geojson":          "123/4/1234-alt-part-2-quattroshapes.geojson",
+		"123/4/1234-alt-whosonfirst-reversegeo.geojson": "123/4/1234-alt-part-2-whosonfirst-reversegeo.geojson",
+	}
+
+	for path, expected := range tests {
+
+		f, err := NewFeature(path, bytes.NewReader([]byte(`{}`)))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature for %s, %v", path, err)
+		}
+
+		part, err := f.NewPart(2, []byte(`{}`))
+
+		if err != nil {
+			t.Fatalf("Failed to create part for %s, %v", path, err)
+		}
+
+		if part.RelPath != expected {
+			t.Fatalf("Expected %s for part of %s, got %s", expected, path, part.RelPath)
+		}
+	}
+}
+
+func TestDefaultPipelineExplodeAndCoerce(t *testing.T) {
+
+	ctx := context.Background()
+
+	opts := &IterwriterCallbackFuncBuilderOptions{
+		IncludeAltFiles:            true,
+		CoerceGeometryCollections:  true,
+		ExplodeGeometryCollections: true,
+	}
+
+	f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(testCollectionFeature)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	features, err := DefaultPipeline(opts).ProcessAll(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to process feature, %v", err)
+	}
+
+	if len(features) != 3 {
+		t.Fatalf("Expected Point and LineString members to be kept, got %d features", len(features))
+	}
+}
+
+func TestExplodeSplitterMultiPolygons(t *testing.T) {
+
+	ctx := context.Background()
+
+	multipolygon := `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]],[[[2,2],[3,2],[3,3],[2,3],[2,2]]]]}`
+
+	tests := []struct {
+		label       string
+		splitter    *ExplodeSplitter
+		geometry    string
+		expected    []string
+		with_layers bool
+	}{
+		// The parts of MultiPolygons stay in the default layer, like Polygons which are not exploded
+		{"multipolygon", &ExplodeSplitter{MultiPolygons: true, Layers: true}, multipolygon, []string{"Polygon", "Polygon"}, false},
+		{"multipolygon without exploding", &ExplodeSplitter{Collections: true, Layers: true}, multipolygon, []string{"MultiPolygon"}, false},
+		// The MultiPolygon members of collections are exploded if both flags are set
+		{"collection", &ExplodeSplitter{Collections: true, MultiPolygons: true, Layers: true}, `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},` + multipolygon + `]}`, []string{"Point", "Polygon", "Polygon"}, true},
+		{"collection without multipolygons", &ExplodeSplitter{Collections: true, Layers: true}, `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},` + multipolygon + `]}`, []string{"Point", "MultiPolygon"}, true},
+	}
+
+	for _, test := range tests {
+
+		body := `{"type":"Feature","properties":{"wof:id":1234},"geometry":` + test.geometry + `}`
+
+		f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature for %s, %v", test.label, err)
+		}
+
+		parts, err := test.splitter.Split(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to split %s, %v", test.label, err)
+		}
+
+		if len(parts) != len(test.expected) {
+			t.Fatalf("Expected %d parts for %s, got %d", len(test.expected), test.label, len(parts))
+		}
+
+		for idx, part := range parts {
+
+			part_body, err := part.Body()
+
+			if err != nil {
+				t.Fatalf("Failed to derive body for %s, %v", test.label, err)
+			}
+
+			if geom_type := gjson.GetBytes(part_body, "geometry.type").String(); geom_type != test.expected[idx] {
+				t.Fatalf("Expected part %d of %s to be a %s, got %s", idx, test.label, test.expected[idx], geom_type)
+			}
+
+			if gjson.GetBytes(part_body, "tippecanoe.layer").Exists() != test.with_layers {
+				t.Fatalf("Unexpected tippecanoe.layer for part %d of %s, %s", idx, test.label, part_body)
+			}
+		}
+	}
+}
+
+func TestDefaultPipelineExplodeAsSPR(t *testing.T) {
+
+	ctx := context.Background()
+
+	opts := &IterwriterCallbackFuncBuilderOptions{
+		AsSPR:                      true,
+		ExplodeGeometryCollections: true,
+	}
+
+	// SPR output requires more properties than testCollectionFeature has
+
+	body := `{"type":"Feature","properties":{"wof:id":1234,"wof:name":"Example","wof:placetype":"venue","wof:parent_id":-1,"wof:country":"XY","wof:repo":"whosonfirst-data-venue-xy","wof:lastmodified":1700000000,"wof:hierarchy":[{"venue_id":1234}]},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[0,0]},{"type":"LineString","coordinates":[[0,0],[1,1]]},{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}]}}`
+
+	f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	features, err := DefaultPipeline(opts).ProcessAll(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to process feature, %v", err)
+	}
+
+	if len(features) != 3 {
+		t.Fatalf("Expected 3 features, got %d", len(features))
+	}
+
+	for idx, part := range features {
+
+		part_body, err := part.Body()
+
+		if err != nil {
+			t.Fatalf("Failed to read part %d, %v", idx, err)
+		}
+
+		if v := gjson.GetBytes(part_body, "properties."+EXPLODE_PART_PROPERTY).Int(); v != int64(idx) {
+			t.Fatalf("Expected %s of %d for part %d, got %d", EXPLODE_PART_PROPERTY, idx, idx, v)
+		}
+
+		expected := fmt.Sprintf("1234-alt-part-%d", idx)
+
+		if v := gjson.GetBytes(part_body, "properties."+EXPLODE_PART_KEY_PROPERTY).String(); v != expected {
+			t.Fatalf("Expected %s of %s for part %d, got '%s'", EXPLODE_PART_KEY_PROPERTY, expected, idx, v)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"log/slog"
+	"sync"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// Features returns an `iter.Seq2[*Feature, error]` for each record emitted by the whosonfirst/go-whosonfirst-iterate/v3
+// iterator defined by 'iterator_uri' for 'iterator_paths' which is included, and transformed, by the `Pipeline` returned
+// by `DefaultPipeline` for 'opts'. This is the equivalent of `IterwriterCallbackFuncBuilder` for code which wants processed
+// features directly rather than writing them to a whosonfirst/go-writer/v3.Writer instance.
+func Features(ctx context.Context, iterator_uri string, iterator_paths []string, opts *IterwriterCallbackFuncBuilderOptions) iter.Seq2[*Feature, error] {
+	p := DefaultPipeline(opts)
+	return FeaturesWithPipeline(ctx, iterator_uri, iterator_paths, p, opts)
+}
+
+// FeaturesWithPipeline returns an `iter.Seq2[*Feature, error]` for each record emitted by the whosonfirst/go-whosonfirst-iterate/v3
+// iterator defined by 'iterator_uri' for 'iterator_paths' which is included, and transformed, by 'p'. The body of each feature is
+// read before it is yielded so features remain valid after iteration continues. The `Forgiving`, `PropertyBudget` and `Deduplicator`
+// properties of 'opts' are applied the same way they are by `IterwriterCallbackFuncBuilderWithPipeline`. Features retained by
+// deferred deduplication policies are yielded, ordered by relative path, once iteration is complete; since only their bodies are
+// retained they are new `Feature` instances whose `Path` is their relative path.
+func FeaturesWithPipeline(ctx context.Context, iterator_uri string, iterator_paths []string, p *Pipeline, opts *IterwriterCallbackFuncBuilderOptions) iter.Seq2[*Feature, error] {
+
+	return func(yield func(*Feature, error) bool) {
+
+		it, err := iterate.NewIterator(ctx, iterator_uri)
+
+		if err != nil {
+			yield(nil, fmt.Errorf("Failed to create new iterator, %w", err))
+			return
+		}
+
+		defer it.Close()
+
+		wr := newCaptureWriter()
+
+		for rec, err := range it.Iterate(ctx, iterator_paths...) {
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			features, err := processRecord(ctx, p, rec)
+			rec.Body.Close()
+
+			if err != nil {
+
+				if opts.Forgiving {
+					slog.Error("Failed to process feature", "path", rec.Path, "error", err)
+					continue
+				}
+
+				yield(nil, err)
+				return
+			}
+
+			lookup := make(map[string]*Feature)
+
+			for _, f := range features {
+
+				err := writeFeature(ctx, opts, f, wr)
+
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				lookup[f.RelPath] = f
+			}
+
+			for _, c := range wr.Drain() {
+
+				f, ok := lookup[c.key]
+
+				if !ok {
+					yield(nil, fmt.Errorf("Unexpected feature written for %s", c.key))
+					return
+				}
+
+				if !yield(f, nil) {
+					return
+				}
+			}
+		}
+
+		if opts.Deduplicator == nil {
+			return
+		}
+
+		err = opts.Deduplicator.Flush(ctx)
+
+		if err != nil {
+			yield(nil, fmt.Errorf("Failed to flush deduplicator, %w", err))
+			return
+		}
+
+		for _, c := range wr.Drain() {
+
+			f, err := NewFeature(c.key, bytes.NewReader(c.body))
+
+			if err == nil {
+				_, err = f.Body()
+			}
+
+			if err != nil {
+				yield(nil, fmt.Errorf("Failed to create feature for %s, %w", c.key, err))
+				return
+			}
+
+			if !yield(f, nil) {
+				return
+			}
+		}
+	}
+}
+
+// processRecord applies 'p' to 'rec' returning the resulting features whose bodies have been read. An empty list
+// means that 'rec' was excluded by 'p'.
+func processRecord(ctx context.Context, p *Pipeline, rec *iterate.Record) ([]*Feature, error) {
+
+	f, err := NewFeatureFromRecord(rec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	features, err := p.ProcessAll(ctx, f)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to process %s, %w", rec.Path, err)
+	}
+
+	for _, f := range features {
+
+		_, err = f.Body()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return features, nil
+}
+
+// captureWriter implements the `whosonfirst/go-writer/v3.Writer` interface retaining each document written
+// so that `FeaturesWithPipeline` can share the same code path (`writeFeature`) as the iterwriter callback.
+type captureWriter struct {
+	writer.Writer
+	captured []*capturedDocument
+	mu       *sync.Mutex
+}
+
+type capturedDocument struct {
+	key  string
+	body []byte
+}
+
+func newCaptureWriter() *captureWriter {
+
+	wr := &captureWriter{
+		captured: make([]*capturedDocument, 0),
+		mu:       new(sync.Mutex),
+	}
+
+	return wr
+}
+
+// Write retains the content of 'r' for 'key'.
+func (wr *captureWriter) Write(ctx context.Context, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	wr.mu.Lock()
+	wr.captured = append(wr.captured, &capturedDocument{key: key, body: body})
+	wr.mu.Unlock()
+
+	return int64(len(body)), nil
+}
+
+// Drain returns, and then forgets, the documents written to 'wr'.
+func (wr *captureWriter) Drain() []*capturedDocument {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	captured := wr.captured
+	wr.captured = make([]*capturedDocument, 0)
+
+	return captured
+}
+
+// WriterURI returns the value of 'key'.
+func (wr *captureWriter) WriterURI(ctx context.Context, key string) string {
+	return key
+}
+
+// Flush is a no-op to conform to the `Writer` interface and returns nil.
+func (wr *captureWriter) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op to conform to the `Writer` interface and returns nil.
+func (wr *captureWriter) Close(ctx context.Context) error {
+	return nil
+}
+
+// SetLogger is a no-op to conform to the `Writer` interface and returns nil.
+func (wr *captureWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return nil
+}
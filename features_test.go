@@ -0,0 +1,80 @@
+package tippecanoe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFeaturesDeduplicator(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	records := map[string]string{
+		"a/data/101/736/545/101736545.geojson": `{"type":"Feature","properties":{"wof:id":101736545,"wof:lastmodified":1},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		"b/data/101/736/545/101736545.geojson": `{"type":"Feature","properties":{"wof:id":101736545,"wof:lastmodified":2},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+		"b/data/859/225/83/85922583.geojson":   `{"type":"Feature","properties":{"wof:id":85922583,"wof:lastmodified":1},"geometry":{"type":"Point","coordinates":[0,0]}}`,
+	}
+
+	for rel_path, body := range records {
+
+		path := filepath.Join(root, rel_path)
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory, %v", err)
+		}
+
+		err = os.WriteFile(path, []byte(body), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+	}
+
+	paths := []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "b"),
+	}
+
+	for _, policy := range []string{DEDUPE_POLICY_FIRST, DEDUPE_POLICY_LASTMODIFIED} {
+
+		dd, err := NewDeduplicator(ctx, &DeduplicatorOptions{Policy: policy})
+
+		if err != nil {
+			t.Fatalf("Failed to create deduplicator, %v", err)
+		}
+
+		opts := &IterwriterCallbackFuncBuilderOptions{
+			Deduplicator: dd,
+		}
+
+		seen := make(map[int64]int)
+
+		for f, err := range Features(ctx, "repo://", paths, opts) {
+
+			if err != nil {
+				t.Fatalf("Failed to iterate features with '%s' policy, %v", policy, err)
+			}
+
+			seen[f.Id] += 1
+		}
+
+		dd.Close()
+
+		if len(seen) != 2 {
+			t.Fatalf("Expected 2 features with '%s' policy, got %d", policy, len(seen))
+		}
+
+		for id, count := range seen {
+
+			if count != 1 {
+				t.Fatalf("Expected %d to be yielded once with '%s' policy, got %d", id, policy, count)
+			}
+		}
+	}
+}
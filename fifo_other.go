@@ -0,0 +1,13 @@
+//go:build !unix
+
+package tippecanoe
+
+import (
+	"fmt"
+)
+
+func mkfifo(path string) error {
+	return fmt.Errorf("Named pipes are not supported on this platform")
+}
+
+func unblockFifo(path string) {}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package tippecanoe
+
+import (
+	"os"
+	"syscall"
+)
+
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0600)
+}
+
+// unblockFifo opens (and closes) the named pipe at 'path' for reading so that a pending open for writing returns.
+func unblockFifo(path string) {
+
+	fh, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+
+	if err == nil {
+		fh.Close()
+	}
+}
@@ -0,0 +1,175 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// GEOMETRY_TYPES is the list of valid GeoJSON geometry types.
+var GEOMETRY_TYPES = []string{
+	"Point",
+	"MultiPoint",
+	"LineString",
+	"MultiLineString",
+	"Polygon",
+	"MultiPolygon",
+	"GeometryCollection",
+}
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "polygonal", NewPolygonalTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IsValidGeometryType returns true if 't' is a valid GeoJSON geometry type.
+func IsValidGeometryType(t string) bool {
+	return slices.Contains(GEOMETRY_TYPES, t)
+}
+
+// GeometryTypeFilter implements the `Filter` interface including or excluding records by their GeoJSON geometry type.
+type GeometryTypeFilter struct {
+	Filter
+	// IncludeTypes is an optional list of geometry types. If not empty only records with one of these geometry types are included.
+	IncludeTypes []string
+	// ExcludeTypes is an optional list of geometry types. Records with one of these geometry types are excluded.
+	ExcludeTypes []string
+}
+
+// NewGeometryTypeFilter returns a new `GeometryTypeFilter` instance for 'include' and 'exclude'. An error is returned if any
+// of the geometry types are not valid GeoJSON geometry types.
+func NewGeometryTypeFilter(include []string, exclude []string) (*GeometryTypeFilter, error) {
+
+	for _, types := range [][]string{include, exclude} {
+
+		for _, t := range types {
+
+			if !IsValidGeometryType(t) {
+				return nil, fmt.Errorf("Invalid geometry type '%s'", t)
+			}
+		}
+	}
+
+	filter := &GeometryTypeFilter{
+		IncludeTypes: include,
+		ExcludeTypes: exclude,
+	}
+
+	return filter, nil
+}
+
+// Include returns false if the geometry type of 'f' is not in 'filter.IncludeTypes' or is in 'filter.ExcludeTypes'.
+func (filter *GeometryTypeFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	geom_type := gjson.GetBytes(body, "geometry.type").String()
+
+	if slices.Contains(filter.ExcludeTypes, geom_type) {
+		return false, nil
+	}
+
+	if len(filter.IncludeTypes) > 0 && !slices.Contains(filter.IncludeTypes, geom_type) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// PolygonalTransformer implements the `Transformer` interface replacing GeometryCollections with their polygonal parts.
+// A collection with a single polygon is replaced by a Polygon and a collection with more than one polygon by a MultiPolygon.
+// Nested collections are included. Collections without any polygonal parts, and all other geometry types, are left unchanged.
+type PolygonalTransformer struct {
+	Transformer
+}
+
+// NewPolygonalTransformer returns a new `PolygonalTransformer` instance configured by 'uri' in the form of:
+//
+//	polygonal://
+func NewPolygonalTransformer(ctx context.Context, uri string) (Transformer, error) {
+	t := &PolygonalTransformer{}
+	return t, nil
+}
+
+// Transform replaces the geometry of 'f' with its polygonal parts if it is a GeometryCollection.
+func (t *PolygonalTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	if gjson.GetBytes(body, "geometry.type").String() != "GeometryCollection" {
+		return nil
+	}
+
+	geom, err := f.Geometry()
+
+	if err != nil {
+		return err
+	}
+
+	polys := polygonalParts(geom.Geometry())
+
+	var coerced orb.Geometry
+
+	switch len(polys) {
+	case 0:
+		return nil
+	case 1:
+		coerced = polys[0]
+	default:
+		coerced = polys
+	}
+
+	enc, err := json.Marshal(geojson.NewGeometry(coerced))
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal polygonal geometry for %s, %w", f.Path, err)
+	}
+
+	body, err = sjson.SetRawBytes(body, "geometry", enc)
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign polygonal geometry for %s, %w", f.Path, err)
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+func polygonalParts(g orb.Geometry) orb.MultiPolygon {
+
+	polys := orb.MultiPolygon{}
+
+	switch geom := g.(type) {
+	case orb.Polygon:
+		polys = append(polys, geom)
+	case orb.MultiPolygon:
+		polys = append(polys, geom...)
+	case orb.Collection:
+
+		for _, c := range geom {
+			polys = append(polys, polygonalParts(c)...)
+		}
+	}
+
+	return polys
+}
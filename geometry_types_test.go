@@ -0,0 +1,43 @@
+package tippecanoe
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestPolygonalParts(t *testing.T) {
+
+	square := orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	other := orb.Polygon{{{2, 2}, {3, 2}, {3, 3}, {2, 3}, {2, 2}}}
+
+	tests := map[string]struct {
+		geom     orb.Geometry
+		expected int
+	}{
+		"point":        {orb.Point{0, 0}, 0},
+		"linestring":   {orb.LineString{{0, 0}, {1, 1}}, 0},
+		"polygon":      {square, 1},
+		"multipolygon": {orb.MultiPolygon{square, other}, 2},
+		"collection": {orb.Collection{
+			orb.Point{0, 0},
+			orb.LineString{{0, 0}, {1, 1}},
+			square,
+			orb.MultiPolygon{square, other},
+		}, 3},
+		"nested collection": {orb.Collection{
+			orb.Collection{square, orb.Point{0, 0}},
+			other,
+		}, 2},
+		"empty collection": {orb.Collection{}, 0},
+	}
+
+	for label, test := range tests {
+
+		polys := polygonalParts(test.geom)
+
+		if len(polys) != test.expected {
+			t.Fatalf("Expected %d polygons for %s, got %d", test.expected, label, len(polys))
+		}
+	}
+}
@@ -3,9 +3,15 @@ module github.com/whosonfirst/go-whosonfirst-tippecanoe
 go 1.24.5
 
 require (
+	github.com/aaronland/go-roster v1.0.0
+	github.com/go-git/go-git/v5 v5.16.2
+	github.com/paulmach/orb v0.10.0
+	github.com/sfomuseum/go-edtf v1.1.1
 	github.com/sfomuseum/go-flags v0.11.0
+	github.com/sfomuseum/runtimevar v1.3.2
 	github.com/tidwall/gjson v1.18.0
 	github.com/tidwall/sjson v1.2.5
+	github.com/whosonfirst/go-whosonfirst-feature v0.0.27
 	github.com/whosonfirst/go-whosonfirst-iterate-git/v3 v3.0.5
 	github.com/whosonfirst/go-whosonfirst-iterate/v3 v3.2.0
 	github.com/whosonfirst/go-whosonfirst-iterwriter/v4 v4.0.3
@@ -14,6 +20,7 @@ require (
 	github.com/whosonfirst/go-writer-featurecollection/v3 v3.0.2
 	github.com/whosonfirst/go-writer-jsonl/v3 v3.0.2
 	github.com/whosonfirst/go-writer/v3 v3.1.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -23,7 +30,6 @@ require (
 	github.com/aaronland/go-aws-auth v1.7.0 // indirect
 	github.com/aaronland/go-aws-auth/v2 v2.0.1 // indirect
 	github.com/aaronland/go-json-query v0.1.6 // indirect
-	github.com/aaronland/go-roster v1.0.0 // indirect
 	github.com/aaronland/gocloud-blob v0.6.1 // indirect
 	github.com/aws/aws-sdk-go v1.55.7 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.36.6 // indirect
@@ -56,7 +62,6 @@ require (
 	github.com/g8rswimmer/error-chain v1.0.0 // indirect
 	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
 	github.com/go-git/go-billy/v5 v5.6.2 // indirect
-	github.com/go-git/go-git/v5 v5.16.2 // indirect
 	github.com/go-ini/ini v1.67.0 // indirect
 	github.com/go-logr/logr v1.4.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
@@ -70,16 +75,12 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/kevinburke/ssh_config v1.2.0 // indirect
 	github.com/natefinch/atomic v1.0.1 // indirect
-	github.com/paulmach/orb v0.10.0 // indirect
 	github.com/pjbgf/sha1cd v0.3.2 // indirect
 	github.com/sergi/go-diff v1.3.2-0.20230802210424-5b0b94c5c0d3 // indirect
-	github.com/sfomuseum/go-edtf v1.1.1 // indirect
-	github.com/sfomuseum/runtimevar v1.3.2 // indirect
 	github.com/skeema/knownhosts v1.3.1 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/whosonfirst/go-ioutil v1.0.2 // indirect
-	github.com/whosonfirst/go-whosonfirst-feature v0.0.27 // indirect
 	github.com/whosonfirst/go-whosonfirst-flags v0.5.1 // indirect
 	github.com/whosonfirst/go-whosonfirst-github v0.9.2 // indirect
 	github.com/whosonfirst/go-whosonfirst-sources v0.1.0 // indirect
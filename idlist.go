@@ -0,0 +1,197 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IdList is a sorted, de-duplicated list of Who's On First IDs. It uses 8 bytes per ID and lookups are performed
+// using a binary search so lists with millions of IDs remain cheap to store and query.
+type IdList struct {
+	ids []int64
+}
+
+// NewIdList returns a new `IdList` instance derived from 'values'. Each value may be a comma-separated list of
+// IDs or the path to a file containing IDs. Values which are the path of an existing file are always treated as
+// files, even if they are also valid IDs. Files may contain one ID per line or be CSV documents in which case
+// IDs are read from the column labeled "id", "wof:id" or "wof_id" in the first row or, if there is no such column,
+// the first column. Empty lines, lines starting with "#" and values that are not integers (for example other
+// header labels) are ignored.
+func NewIdList(values ...string) (*IdList, error) {
+
+	ids := make([]int64, 0)
+
+	for _, v := range values {
+
+		v = strings.TrimSpace(v)
+
+		if v == "" {
+			continue
+		}
+
+		// Check for a file first so that files whose names are all digits (for example "1234") can be used
+
+		info, err := os.Stat(v)
+
+		if err != nil || !info.Mode().IsRegular() {
+
+			v_ids, err := parseIdValues(v)
+
+			if err == nil {
+				ids = append(ids, v_ids...)
+				continue
+			}
+		}
+
+		f_ids, err := readIdFile(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read IDs from %s, %w", v, err)
+		}
+
+		ids = append(ids, f_ids...)
+	}
+
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	l := &IdList{
+		ids: slices.Clip(ids),
+	}
+
+	return l, nil
+}
+
+// Contains returns true if 'id' is present in 'l'.
+func (l *IdList) Contains(id int64) bool {
+	_, ok := slices.BinarySearch(l.ids, id)
+	return ok
+}
+
+// Len returns the number of IDs in 'l'.
+func (l *IdList) Len() int {
+	return len(l.ids)
+}
+
+// IdFilter implements the `Filter` interface including or excluding records by their Who's On First ID. Only the
+// ID derived from the path of a record is consulted so the body of excluded records is never read.
+type IdFilter struct {
+	Filter
+	// IncludeIds is an optional `IdList` instance. If present only records whose ID is in the list are included.
+	IncludeIds *IdList
+	// ExcludeIds is an optional `IdList` instance. If present records whose ID is in the list are excluded.
+	ExcludeIds *IdList
+}
+
+// Include returns false if the ID of 'f' is not present in 'filter.IncludeIds' or is present in 'filter.ExcludeIds'.
+func (filter *IdFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	if filter.ExcludeIds != nil && filter.ExcludeIds.Contains(f.Id) {
+		return false, nil
+	}
+
+	if filter.IncludeIds != nil && !filter.IncludeIds.Contains(f.Id) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func parseIdValues(str string) ([]int64, error) {
+
+	parts := strings.Split(str, ",")
+	ids := make([]int64, 0, len(parts))
+
+	for _, p := range parts {
+
+		p = strings.TrimSpace(p)
+
+		if p == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(p, 10, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func readIdFile(path string) ([]int64, error) {
+
+	r, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	csv_r := csv.NewReader(r)
+	csv_r.Comment = '#'
+	csv_r.FieldsPerRecord = -1
+	csv_r.TrimLeadingSpace = true
+	csv_r.ReuseRecord = true
+
+	ids := make([]int64, 0)
+	col := 0
+	first := true
+
+	for {
+
+		row, err := csv_r.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if first {
+
+			first = false
+
+			idx := slices.IndexFunc(row, func(label string) bool {
+				switch strings.TrimSpace(label) {
+				case "id", "wof:id", "wof_id":
+					return true
+				default:
+					return false
+				}
+			})
+
+			if idx != -1 {
+				col = idx
+				continue
+			}
+		}
+
+		if col >= len(row) {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSpace(row[col]), 10, 64)
+
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
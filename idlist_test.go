@@ -0,0 +1,69 @@
+package tippecanoe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewIdList(t *testing.T) {
+
+	root := t.TempDir()
+
+	// A file whose name is all digits should be read as a file rather than as an ID
+
+	digits_path := filepath.Join(root, "1234")
+
+	err := os.WriteFile(digits_path, []byte("# curated\n101736545\n85922583\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", digits_path, err)
+	}
+
+	csv_path := filepath.Join(root, "places.csv")
+
+	err = os.WriteFile(csv_path, []byte("name,wof:id\nSan Francisco,85922583\nMontreal,101736545\nnope,abc\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", csv_path, err)
+	}
+
+	tests := []struct {
+		values   []string
+		contains []int64
+		excludes []int64
+	}{
+		{[]string{"101736545,85922583", "1234"}, []int64{101736545, 85922583, 1234}, []int64{102087579}},
+		{[]string{digits_path}, []int64{101736545, 85922583}, []int64{1234}},
+		{[]string{csv_path}, []int64{101736545, 85922583}, []int64{0}},
+	}
+
+	for _, test := range tests {
+
+		l, err := NewIdList(test.values...)
+
+		if err != nil {
+			t.Fatalf("Failed to create ID list for %v, %v", test.values, err)
+		}
+
+		for _, id := range test.contains {
+
+			if !l.Contains(id) {
+				t.Fatalf("Expected ID list for %v to contain %d", test.values, id)
+			}
+		}
+
+		for _, id := range test.excludes {
+
+			if l.Contains(id) {
+				t.Fatalf("Expected ID list for %v to not contain %d", test.values, id)
+			}
+		}
+	}
+
+	_, err = NewIdList(filepath.Join(root, "missing.csv"))
+
+	if err == nil {
+		t.Fatalf("Expected missing file to fail")
+	}
+}
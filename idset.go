@@ -0,0 +1,189 @@
+package tippecanoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// The largest Who's On First ID that can be stored in the on-disk bitmap used by `DiskIdSet`. IDs
+// outside this range are tracked in memory.
+const DISK_IDSET_MAX_ID int64 = 1<<31 - 1
+
+// IdSet is an interface for recording whether a Who's On First ID has been seen. Implementations
+// must be safe for concurrent use.
+type IdSet interface {
+	// Add records an ID and returns true if it was not already present in the set.
+	Add(context.Context, int64) (bool, error)
+	// Contains returns true if an ID is present in the set.
+	Contains(context.Context, int64) (bool, error)
+	// Close releases any resources used by the set.
+	Close() error
+}
+
+// MemoryIdSet implements the `IdSet` interface using an in-memory lookup table.
+type MemoryIdSet struct {
+	IdSet
+	ids map[int64]bool
+	mu  *sync.RWMutex
+}
+
+// NewMemoryIdSet returns a new `MemoryIdSet` instance.
+func NewMemoryIdSet() *MemoryIdSet {
+
+	s := &MemoryIdSet{
+		ids: make(map[int64]bool),
+		mu:  new(sync.RWMutex),
+	}
+
+	return s
+}
+
+// Add records 'id' and returns true if it was not already present in 's'.
+func (s *MemoryIdSet) Add(ctx context.Context, id int64) (bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ids[id] {
+		return false, nil
+	}
+
+	s.ids[id] = true
+	return true, nil
+}
+
+// Contains returns true if 'id' is present in 's'.
+func (s *MemoryIdSet) Contains(ctx context.Context, id int64) (bool, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ids[id], nil
+}
+
+// Close is a no-op to conform to the `IdSet` interface and returns nil.
+func (s *MemoryIdSet) Close() error {
+	return nil
+}
+
+// DiskIdSet implements the `IdSet` interface using a (sparse) bitmap file on disk, one bit per ID.
+// IDs less than zero or greater than `DISK_IDSET_MAX_ID` are tracked in memory.
+type DiskIdSet struct {
+	IdSet
+	path     string
+	fh       *os.File
+	mu       *sync.Mutex
+	overflow *MemoryIdSet
+}
+
+// NewDiskIdSet returns a new `DiskIdSet` instance storing its bitmap in 'path'. Any existing file
+// at 'path' will be truncated.
+func NewDiskIdSet(path string) (*DiskIdSet, error) {
+
+	fh, err := os.Create(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create %s, %w", path, err)
+	}
+
+	s := &DiskIdSet{
+		path:     path,
+		fh:       fh,
+		mu:       new(sync.Mutex),
+		overflow: NewMemoryIdSet(),
+	}
+
+	return s, nil
+}
+
+// Add records 'id' and returns true if it was not already present in 's'.
+func (s *DiskIdSet) Add(ctx context.Context, id int64) (bool, error) {
+
+	if id < 0 || id > DISK_IDSET_MAX_ID {
+		return s.overflow.Add(ctx, id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset, mask := s.position(id)
+
+	b, err := s.readByte(offset)
+
+	if err != nil {
+		return false, err
+	}
+
+	if b&mask != 0 {
+		return false, nil
+	}
+
+	_, err = s.fh.WriteAt([]byte{b | mask}, offset)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to write %d to %s, %w", id, s.path, err)
+	}
+
+	return true, nil
+}
+
+// Contains returns true if 'id' is present in 's'.
+func (s *DiskIdSet) Contains(ctx context.Context, id int64) (bool, error) {
+
+	if id < 0 || id > DISK_IDSET_MAX_ID {
+		return s.overflow.Contains(ctx, id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset, mask := s.position(id)
+
+	b, err := s.readByte(offset)
+
+	if err != nil {
+		return false, err
+	}
+
+	return b&mask != 0, nil
+}
+
+// Close closes and removes the bitmap file used by 's'.
+func (s *DiskIdSet) Close() error {
+
+	err := s.fh.Close()
+
+	if err != nil {
+		return fmt.Errorf("Failed to close %s, %w", s.path, err)
+	}
+
+	return os.Remove(s.path)
+}
+
+func (s *DiskIdSet) position(id int64) (int64, byte) {
+	return id / 8, byte(1 << uint(id%8))
+}
+
+func (s *DiskIdSet) readByte(offset int64) (byte, error) {
+
+	buf := make([]byte, 1)
+
+	// Reading past the end of the (sparse) file is the same as reading a zero byte
+
+	n, err := s.fh.ReadAt(buf, offset)
+
+	if n == 0 {
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("Failed to read %s, %w", s.path, err)
+		}
+
+		return 0, nil
+	}
+
+	return buf[0], nil
+}
@@ -0,0 +1,162 @@
+package tippecanoe
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestIdSets(t *testing.T) {
+
+	ctx := context.Background()
+
+	disk_set, err := NewDiskIdSet(filepath.Join(t.TempDir(), "ids.bitmap"))
+
+	if err != nil {
+		t.Fatalf("Failed to create disk ID set, %v", err)
+	}
+
+	sets := map[string]IdSet{
+		"memory": NewMemoryIdSet(),
+		"disk":   disk_set,
+	}
+
+	ids := []int64{
+		0,
+		7,
+		8,
+		101736545,
+		DISK_IDSET_MAX_ID,
+		DISK_IDSET_MAX_ID + 1,
+		1745882083,
+		-1,
+	}
+
+	for label, s := range sets {
+
+		for _, id := range ids {
+
+			ok, err := s.Contains(ctx, id)
+
+			if err != nil {
+				t.Fatalf("Failed to query %d in %s set, %v", id, label, err)
+			}
+
+			if ok {
+				t.Fatalf("Expected %s set not to contain %d", label, id)
+			}
+
+			added, err := s.Add(ctx, id)
+
+			if err != nil {
+				t.Fatalf("Failed to add %d to %s set, %v", id, label, err)
+			}
+
+			if !added {
+				t.Fatalf("Expected %d to be new to %s set", id, label)
+			}
+
+			added, err = s.Add(ctx, id)
+
+			if err != nil {
+				t.Fatalf("Failed to add %d to %s set, %v", id, label, err)
+			}
+
+			if added {
+				t.Fatalf("Expected %d to already be present in %s set", id, label)
+			}
+
+			ok, err = s.Contains(ctx, id)
+
+			if err != nil {
+				t.Fatalf("Failed to query %d in %s set, %v", id, label, err)
+			}
+
+			if !ok {
+				t.Fatalf("Expected %s set to contain %d", label, id)
+			}
+		}
+
+		// Neighbouring bits in the same byte are not set
+
+		ok, err := s.Contains(ctx, 9)
+
+		if err != nil {
+			t.Fatalf("Failed to query 9 in %s set, %v", label, err)
+		}
+
+		if ok {
+			t.Fatalf("Expected %s set not to contain 9", label)
+		}
+
+		err = s.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to close %s set, %v", label, err)
+		}
+	}
+}
+
+func TestDiskIdSetConcurrentAdd(t *testing.T) {
+
+	ctx := context.Background()
+
+	s, err := NewDiskIdSet(filepath.Join(t.TempDir(), "ids.bitmap"))
+
+	if err != nil {
+		t.Fatalf("Failed to create disk ID set, %v", err)
+	}
+
+	defer s.Close()
+
+	// Every ID shares the same byte in the bitmap so concurrent writes would clobber one another without locking
+
+	var added int64
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < 50; i++ {
+
+		for id := int64(800); id < 808; id++ {
+
+			wg.Add(1)
+
+			go func(id int64) {
+
+				defer wg.Done()
+
+				ok, err := s.Add(ctx, id)
+
+				if err != nil {
+					t.Errorf("Failed to add %d, %v", id, err)
+					return
+				}
+
+				if ok {
+					atomic.AddInt64(&added, 1)
+				}
+			}(id)
+		}
+	}
+
+	wg.Wait()
+
+	if added != 8 {
+		t.Fatalf("Expected 8 IDs to be added, got %d", added)
+	}
+
+	for id := int64(800); id < 808; id++ {
+
+		ok, err := s.Contains(ctx, id)
+
+		if err != nil {
+			t.Fatalf("Failed to query %d, %v", id, err)
+		}
+
+		if !ok {
+			t.Fatalf("Expected set to contain %d", id)
+		}
+	}
+}
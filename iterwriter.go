@@ -1,19 +1,12 @@
 package tippecanoe
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
-	"strings"
 
-	"github.com/tidwall/gjson"
-	"github.com/tidwall/sjson"
 	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
 	"github.com/whosonfirst/go-whosonfirst-iterwriter/v4"
-	"github.com/whosonfirst/go-whosonfirst-spr/v2"
-	"github.com/whosonfirst/go-whosonfirst-uri"
 	"github.com/whosonfirst/go-writer/v3"
 )
 
@@ -23,147 +16,296 @@ type IterwriterCallbackFuncBuilderOptions struct {
 	IncludeAltFiles     bool
 	AppendSPRProperties []string
 	Forgiving           bool
+	// An optional `IdList` instance. If present only records whose ID is in the list are included.
+	IncludeIds *IdList
+	// An optional `IdList` instance. If present records whose ID is in the list are excluded.
+	ExcludeIds *IdList
+	// An optional `Since` instance used to limit output to records modified since a timestamp or Git commit.
+	Since *Since
+	// An optional `Deduplicator` instance used to ensure that only one record for a given ID is written.
+	Deduplicator *Deduplicator
+	// An optional `EDTFRange` instance used to limit output to records whose EDTF inception and cessation dates overlap that range.
+	ValidRange *EDTFRange
+	// Zero or more GeoJSON geometry types. If not empty only records with one of these geometry types are included.
+	GeometryTypes []string
+	// Zero or more GeoJSON geometry types. Records with one of these geometry types are excluded.
+	ExcludeGeometryTypes []string
+	// Replace GeometryCollections with their polygonal parts before geometry types are tested. See `PolygonalTransformer` for details.
+	CoerceGeometryCollections bool
+	// Replace GeometryCollections with a feature for each member geometry, before geometry types are tested. See `ExplodeSplitter` for details.
+	ExplodeGeometryCollections bool
+	// Replace MultiPolygons with a feature for each polygon, before geometry types are tested. See `ExplodeSplitter` for details.
+	ExplodeMultiPolygons bool
+	// An optional `PlacetypeSelection` instance used to limit output to records with specific placetypes.
+	Placetypes *PlacetypeSelection
+	// Zero or more concordance prefixes (or keys) to copy from "wof:concordances" in to flat properties. See `ConcordancesTransformer` for details.
+	Concordances []string
+	// If not empty, add geodesic area, perimeter and vertex count properties using these units. See `MetricsTransformer` for details.
+	GeometryMetrics string
+	// Zero or more placetypes whose geometries should be replaced by centroid points with population properties. See `PointsTransformer` for details.
+	PointPlacetypes []string
+	// If not empty, add numeric EDTF properties to each record encoded using this format. See `EDTFTransformer` for details.
+	EDTFAttributes string
+	// An optional `PropertyBudget` instance used to limit the size of the properties of each record after all other processing.
+	PropertyBudget *PropertyBudget
 }
 
-func IterwriterCallbackFuncBuilder(opts *IterwriterCallbackFuncBuilderOptions) iterwriter.IterwriterCallback {
+// DefaultPipeline returns a new `Pipeline` instance with the built-in stages configured by 'opts'. The stages
+// are, in order: exclude alternate geometries, exclude records by ID, exclude records not modified since a timestamp or commit,
+// exclude records outside a range of time, exclude records by placetype, explode GeometryCollections (and MultiPolygons) in to separate features,
+// replace GeometryCollections with their polygonal parts, explode MultiPolygons (if GeometryCollections are not exploded), exclude non-polygon
+// geometries, exclude records by geometry type, replace properties with SPR, append properties to SPR, add concordance properties, convert
+// placetypes to points (once per source record), add geometry metrics and add numeric EDTF properties. The parts of exploded GeometryCollections are
+// assigned a "tippecanoe.layer" value derived from their geometry type; the parts of exploded MultiPolygons, and features which
+// are not exploded, are left in the default layer.
+func DefaultPipeline(opts *IterwriterCallbackFuncBuilderOptions) *Pipeline {
 
-	fn := func(ctx context.Context, rec *iterate.Record, wr writer.Writer) error {
+	p := NewPipeline()
 
-		logger := slog.Default()
-		logger = logger.With("rec.Path", rec.Path)
+	p.AddFilter(&AlternateGeometryFilter{IncludeAltFiles: opts.IncludeAltFiles})
 
-		id, uri_args, err := uri.ParseURI(rec.Path)
+	if opts.IncludeIds != nil || opts.ExcludeIds != nil {
+		p.AddFilter(&IdFilter{IncludeIds: opts.IncludeIds, ExcludeIds: opts.ExcludeIds})
+	}
 
-		if err != nil {
-			return fmt.Errorf("Unable to parse %s, %w", rec.Path, err)
-		}
+	if opts.Since != nil {
+		p.AddFilter(&SinceFilter{Since: opts.Since})
+	}
 
-		logger = logger.With("id", id)
+	if opts.ValidRange != nil {
+		p.AddFilter(&EDTFRangeFilter{Range: opts.ValidRange})
+	}
 
-		if uri_args.IsAlternate && !opts.IncludeAltFiles {
-			logger.Debug("Is alternate geometry, skipping")
-			return nil
-		}
+	if opts.Placetypes != nil {
+		p.AddFilter(&PlacetypeFilter{Selection: opts.Placetypes})
+	}
+
+	explode := &ExplodeSplitter{
+		Collections:   opts.ExplodeGeometryCollections,
+		MultiPolygons: opts.ExplodeMultiPolygons,
+		Layers:        true,
+	}
 
-		rel_path, err := uri.Id2RelPath(id, uri_args)
+	// GeometryCollections are exploded before they are coerced so that their Point and LineString
+	// members are kept. Otherwise coerced collections may still have their MultiPolygons exploded.
 
-		if err != nil {
-			return fmt.Errorf("Unable to derive relative (WOF) path for %s, %w", rec.Path, err)
+	if opts.ExplodeGeometryCollections {
+		p.AddSplitter(explode)
+	}
+
+	if opts.CoerceGeometryCollections {
+		p.AddTransformer(&PolygonalTransformer{})
+	}
+
+	if opts.ExplodeMultiPolygons && !opts.ExplodeGeometryCollections {
+		p.AddSplitter(explode)
+	}
+
+	if opts.RequirePolygon {
+		p.AddFilter(&PolygonFilter{})
+	}
+
+	if len(opts.GeometryTypes) > 0 || len(opts.ExcludeGeometryTypes) > 0 {
+		p.AddFilter(&GeometryTypeFilter{IncludeTypes: opts.GeometryTypes, ExcludeTypes: opts.ExcludeGeometryTypes})
+	}
+
+	if opts.AsSPR {
+
+		p.AddTransformer(&SPRTransformer{})
+
+		if len(opts.AppendSPRProperties) > 0 {
+			p.AddTransformer(&AppendPropertiesTransformer{Properties: opts.AppendSPRProperties})
 		}
+	}
 
-		logger = logger.With("rel_path", rel_path)
+	if len(opts.Concordances) > 0 {
+		p.AddTransformer(&ConcordancesTransformer{Prefixes: opts.Concordances})
+	}
 
-		var wr_body io.ReadSeeker
-		wr_body = rec.Body
+	if len(opts.PointPlacetypes) > 0 {
 
-		if opts.RequirePolygon || opts.AsSPR {
+		t := &PointsTransformer{
+			Placetypes:    opts.PointPlacetypes,
+			MaxPopulation: POINTS_DEFAULT_MAX_POPULATION,
+			Buckets:       POINTS_DEFAULT_POPULATION_BUCKETS,
+		}
 
-			body, err := io.ReadAll(rec.Body)
+		p.AddSplitter(t)
+	}
 
-			if err != nil {
-				logger.Error("Failed to read body", "error", err)
+	// Metrics are added after features are converted to points so that they describe the geometry that is written
 
-				if opts.Forgiving {
-					return nil
-				}
+	if opts.GeometryMetrics != "" {
+		p.AddTransformer(&MetricsTransformer{Units: opts.GeometryMetrics})
+	}
 
-				return fmt.Errorf("Failed to read body for %s, %w", rec.Path, err)
-			}
+	if opts.EDTFAttributes != "" {
+		p.AddTransformer(&EDTFTransformer{Format: opts.EDTFAttributes})
+	}
 
-			if opts.RequirePolygon {
+	return p
+}
 
-				geom_rsp := gjson.GetBytes(body, "geometry.type")
+// DefaultPipelineWithTransformers returns the `Pipeline` returned by `DefaultPipeline` for 'opts' followed by the
+// transformers created, using `NewTransformer`, for each of 't_uris'.
+func DefaultPipelineWithTransformers(ctx context.Context, opts *IterwriterCallbackFuncBuilderOptions, t_uris ...string) (*Pipeline, error) {
 
-				switch geom_rsp.String() {
-				case "Polygon", "MultiPolygon":
-					// pass
-				default:
-					return nil
-				}
-			}
+	p := DefaultPipeline(opts)
 
-			if opts.AsSPR && !uri_args.IsAlternate {
+	for _, t_uri := range t_uris {
 
-				s, err := spr.WhosOnFirstSPR(body)
+		t, err := NewTransformer(ctx, t_uri)
 
-				if err != nil {
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create transformer for %s, %w", t_uri, err)
+		}
 
-					logger.Error("Failed to derive SPR", "error", err)
+		p.AddTransformer(t)
+	}
 
-					if opts.Forgiving {
-						return nil
-					}
+	return p, nil
+}
 
-					return fmt.Errorf("Failed to create SPR for %s, %w", rec.Path, err)
-				}
+// IterwriterCallbackFuncBuilder returns a `iterwriter.IterwriterCallback` function which processes each record
+// using the `Pipeline` returned by `DefaultPipeline` for 'opts'.
+func IterwriterCallbackFuncBuilder(opts *IterwriterCallbackFuncBuilderOptions) iterwriter.IterwriterCallback {
+	p := DefaultPipeline(opts)
+	return IterwriterCallbackFuncBuilderWithPipeline(p, opts)
+}
 
-				// ideally use go-whosonfirst-spatial.PropertiesResponseResultsWithStandardPlacesResults here
-				// but not sure what the what is yet
+// IterwriterCallbackFuncBuilderWithPipeline returns a `iterwriter.IterwriterCallback` function which processes each
+// record using 'p' and writes the result. Only the `Forgiving`, `PropertyBudget` and `Deduplicator` properties of 'opts'
+// are consulted.
+func IterwriterCallbackFuncBuilderWithPipeline(p *Pipeline, opts *IterwriterCallbackFuncBuilderOptions) iterwriter.IterwriterCallback {
 
-				old_props := gjson.GetBytes(body, "properties")
+	fn := func(ctx context.Context, rec *iterate.Record, wr writer.Writer) error {
 
-				body, err = sjson.SetBytes(body, "properties", s)
+		f, err := NewFeatureFromRecord(rec)
 
-				if err != nil {
-					logger.Error("Failed to update properties with SPR", "error", err)
+		if err != nil {
 
-					if opts.Forgiving {
-						return nil
-					}
+			slog.Error("Failed to create feature", "path", rec.Path, "error", err)
 
-					return fmt.Errorf("Failed to update properties for %s, %w", rec.Path, err)
-				}
+			if opts.Forgiving {
+				return nil
+			}
 
-				if len(opts.AppendSPRProperties) > 0 {
+			return fmt.Errorf("Failed to create feature for %s, %w", rec.Path, err)
+		}
 
-					for _, path := range opts.AppendSPRProperties {
+		return processAndWriteFeature(ctx, p, opts, f, wr)
+	}
 
-						// Because we are deriving this from old_props and not body
-						rel_path := strings.Replace(path, "properties.", "", 1)
+	return fn
+}
 
-						p_rsp := old_props.Get(rel_path)
+// processAndWriteFeature applies 'p' to 'f' and writes each of the resulting features to 'wr' (or the `Deduplicator`
+// defined in 'opts' if present).
+func processAndWriteFeature(ctx context.Context, p *Pipeline, opts *IterwriterCallbackFuncBuilderOptions, f *Feature, wr writer.Writer) error {
 
-						if p_rsp.Exists() {
+	logger := slog.Default()
+	logger = logger.With("rec.Path", f.Path)
+	logger = logger.With("id", f.Id)
+	logger = logger.With("rel_path", f.RelPath)
 
-							abs_path := fmt.Sprintf("properties.%s", rel_path)
+	features, err := p.ProcessAll(ctx, f)
 
-							body, err = sjson.SetBytes(body, abs_path, p_rsp.Value())
+	if err != nil {
 
-							if err != nil {
+		logger.Error("Failed to process feature", "error", err)
 
-								logger.Error("Failed to append property to SPR", "property", abs_path, "error", err)
+		if opts.Forgiving {
+			return nil
+		}
 
-								if opts.Forgiving {
-									return nil
-								}
+		return fmt.Errorf("Failed to process %s, %w", f.Path, err)
+	}
 
-								return fmt.Errorf("Failed to assign %s to properties, %w", abs_path, err)
-							}
-						}
-					}
+	if len(features) == 0 {
+		logger.Debug("Feature excluded by pipeline, skipping")
+		return nil
+	}
 
-				}
-			}
+	for _, out := range features {
 
-			br := bytes.NewReader(body)
-			wr_body = br
+		err := writeFeature(ctx, opts, out, wr)
+
+		if err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+// writeFeature applies the `PropertyBudget` defined in 'opts', if present, to 'f' and then writes it to 'wr' (or the
+// `Deduplicator` defined in 'opts' if present). Trimmed properties are only recorded if 'f' is not discarded as a duplicate.
+func writeFeature(ctx context.Context, opts *IterwriterCallbackFuncBuilderOptions, f *Feature, wr writer.Writer) error {
 
-		_, err = wr.Write(ctx, rel_path, wr_body)
+	logger := slog.Default()
+	logger = logger.With("rec.Path", f.Path)
+	logger = logger.With("id", f.Id)
+	logger = logger.With("rel_path", f.RelPath)
+
+	var trimmed []*TrimmedProperty
+
+	if opts.PropertyBudget != nil {
+
+		v, err := opts.PropertyBudget.Trim(ctx, f)
 
 		if err != nil {
 
-			logger.Error("Failed to write document", "error", err)
+			logger.Error("Failed to apply property budget", "error", err)
 
 			if opts.Forgiving {
 				return nil
 			}
 
-			return fmt.Errorf("Failed to write %s, %v", rec.Path, err)
+			return fmt.Errorf("Failed to apply property budget to %s, %w", f.Path, err)
 		}
 
-		return nil
+		trimmed = v
 	}
 
-	return fn
+	wr_body, err := f.Reader()
+
+	if err != nil {
+
+		logger.Error("Failed to derive body", "error", err)
+
+		if opts.Forgiving {
+			return nil
+		}
+
+		return fmt.Errorf("Failed to derive body for %s, %w", f.Path, err)
+	}
+
+	var n int64
+
+	if opts.Deduplicator != nil {
+		n, err = opts.Deduplicator.Write(ctx, wr, f.RelPath, wr_body)
+	} else {
+		n, err = wr.Write(ctx, f.RelPath, wr_body)
+	}
+
+	if err != nil {
+
+		logger.Error("Failed to write document", "error", err)
+
+		if opts.Forgiving {
+			return nil
+		}
+
+		return fmt.Errorf("Failed to write %s, %v", f.Path, err)
+	}
+
+	// Only record trimmed properties for features which were written (or accepted by the Deduplicator). Features
+	// accepted by deferred dedupe policies may later be replaced but the budget records trimmed properties by key
+	// so the record for a replaced feature is overwritten by the feature that replaces it.
+
+	if opts.PropertyBudget != nil && n > 0 {
+		opts.PropertyBudget.Record(f.RelPath, trimmed)
+	}
+
+	return nil
 }
@@ -0,0 +1,270 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// Manifest is a struct describing the inputs to, and outputs of, a build in order to determine whether
+// two builds were derived from identical input.
+type Manifest struct {
+	// Created is the time the manifest was created.
+	Created time.Time `json:"created"`
+	// Options is a dictionary of the (effective) options used to produce output.
+	Options map[string]string `json:"options,omitempty"`
+	// IteratorURI is the whosonfirst/go-whosonfirst-iterate/v3 URI used to produce output.
+	IteratorURI string `json:"iterator_uri"`
+	// IteratorPaths are the paths (or URIs) iterated over to produce output.
+	IteratorPaths []string `json:"iterator_paths"`
+	// Commits is a dictionary mapping each iterator path that is a Git repository to the commit hash of its HEAD.
+	Commits map[string]string `json:"commits,omitempty"`
+	// Features is a dictionary mapping each feature (its ID, plus the alternate geometry label if applicable) to
+	// the SHA-256 hash of the bytes emitted for that feature.
+	Features map[string]string `json:"features"`
+	// Count is the number of features emitted. This may be larger than the number of entries in Features if the same
+	// key was written more than once (for example the same ID in multiple repositories), in which case Features records
+	// the hash of the last document written for that key.
+	Count int `json:"count"`
+	// Digest is the SHA-256 hash of the (sorted) features dictionary. Two builds with the same digest emitted identical features.
+	Digest string `json:"digest"`
+}
+
+// ManifestWriter implements the `whosonfirst/go-writer/v3.Writer` interface, wrapping another `Writer` instance,
+// recording the SHA-256 hash of each document written for inclusion in a `Manifest`.
+type ManifestWriter struct {
+	writer.Writer
+	writer   writer.Writer
+	features map[string]string
+	count    int
+	mu       *sync.Mutex
+}
+
+// NewManifestWriter returns a new `ManifestWriter` instance which will record the hash of each document before
+// passing it to 'wr'.
+func NewManifestWriter(wr writer.Writer) *ManifestWriter {
+
+	m := &ManifestWriter{
+		writer:   wr,
+		features: make(map[string]string),
+		mu:       new(sync.Mutex),
+	}
+
+	return m
+}
+
+// Write records the SHA-256 hash of the contents of 'r' and then writes it to the underlying writer.
+func (m *ManifestWriter) Write(ctx context.Context, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	sum := sha256.Sum256(body)
+
+	k := strings.TrimSuffix(filepath.Base(key), filepath.Ext(key))
+
+	m.mu.Lock()
+	m.features[k] = hex.EncodeToString(sum[:])
+	m.count += 1
+	m.mu.Unlock()
+
+	return m.writer.Write(ctx, key, bytes.NewReader(body))
+}
+
+// WriterURI returns the URI for 'key' derived from the underlying writer.
+func (m *ManifestWriter) WriterURI(ctx context.Context, key string) string {
+	return m.writer.WriterURI(ctx, key)
+}
+
+// Flush flushes the underlying writer.
+func (m *ManifestWriter) Flush(ctx context.Context) error {
+	return m.writer.Flush(ctx)
+}
+
+// Close closes the underlying writer.
+func (m *ManifestWriter) Close(ctx context.Context) error {
+	return m.writer.Close(ctx)
+}
+
+// SetLogger assigns 'logger' to the underlying writer.
+func (m *ManifestWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return m.writer.SetLogger(ctx, logger)
+}
+
+// Features returns a copy of the dictionary of feature hashes recorded by 'm'.
+func (m *ManifestWriter) Features() map[string]string {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	features := make(map[string]string, len(m.features))
+
+	for k, v := range m.features {
+		features[k] = v
+	}
+
+	return features
+}
+
+// Count returns the number of documents written by 'm'.
+func (m *ManifestWriter) Count() int {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.count
+}
+
+// Manifest returns a new `Manifest` instance for the features recorded by 'm' and the iterator URI and paths
+// used to produce them. Git commits are not recorded since they should be derived, using the `GitCommits` method,
+// before iteration begins.
+func (m *ManifestWriter) Manifest(ctx context.Context, iterator_uri string, iterator_paths ...string) *Manifest {
+
+	features := m.Features()
+
+	manifest := &Manifest{
+		Created:       time.Now(),
+		IteratorURI:   iterator_uri,
+		IteratorPaths: iterator_paths,
+		Features:      features,
+		Count:         m.Count(),
+		Digest:        ManifestDigest(features),
+	}
+
+	return manifest
+}
+
+// ManifestDigest returns the SHA-256 hash for a dictionary of feature hashes. The digest is derived from the
+// sorted list of keys and their values so it is independent of the order in which features were emitted.
+func ManifestDigest(features map[string]string) string {
+
+	keys := make([]string, 0, len(features))
+
+	for k := range features {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	h := sha256.New()
+
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\t%s\n", k, features[k])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GitCommits returns a dictionary mapping each element in 'paths' which is a Git repository (either a local
+// checkout or a remote URI) to the commit hash of its HEAD. Paths which are not Git repositories, including
+// directories inside a Git repository, are ignored. Remote references are only listed for values which are remote
+// Git URLs (see `isRemoteGitURI`) so that other inputs, like file paths or GitHub organization names, never
+// cause network access.
+func GitCommits(ctx context.Context, paths ...string) map[string]string {
+
+	commits := make(map[string]string)
+
+	for _, path := range paths {
+
+		logger := slog.Default()
+		logger = logger.With("path", path)
+
+		hash, err := gitHead(ctx, path)
+
+		if err != nil {
+			logger.Debug("Unable to derive Git commit", "error", err)
+			continue
+		}
+
+		commits[path] = hash
+	}
+
+	return commits
+}
+
+func gitHead(ctx context.Context, path string) (string, error) {
+
+	info, err := os.Stat(path)
+
+	if err == nil && info.IsDir() {
+
+		// Don't walk up the tree looking for a parent repository since its HEAD
+		// does not describe the contents of 'path'
+
+		repo, err := gogit.PlainOpen(path)
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to open repository, %w", err)
+		}
+
+		ref, err := repo.Head()
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to derive HEAD, %w", err)
+		}
+
+		return ref.Hash().String(), nil
+	}
+
+	if !isRemoteGitURI(path) {
+		return "", gogit.ErrRepositoryNotExists
+	}
+
+	remote_cfg := &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{path},
+	}
+
+	remote := gogit.NewRemote(memory.NewStorage(), remote_cfg)
+
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{})
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to list remote references, %w", err)
+	}
+
+	lookup := make(map[plumbing.ReferenceName]*plumbing.Reference)
+
+	for _, r := range refs {
+		lookup[r.Name()] = r
+	}
+
+	head, ok := lookup[plumbing.HEAD]
+
+	if !ok {
+		return "", fmt.Errorf("Remote is missing HEAD")
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+
+		target, ok := lookup[head.Target()]
+
+		if !ok {
+			return "", fmt.Errorf("Remote is missing %s", head.Target())
+		}
+
+		head = target
+	}
+
+	return head.Hash().String(), nil
+}
@@ -0,0 +1,129 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+func TestManifestWriterCount(t *testing.T) {
+
+	ctx := context.Background()
+
+	null_wr, err := writer.NewNullWriter(ctx, "null://")
+
+	if err != nil {
+		t.Fatalf("Failed to create null writer, %v", err)
+	}
+
+	m := NewManifestWriter(null_wr)
+
+	writes := map[string]string{
+		"101/736/545/101736545.geojson":                   `{"a":1}`,
+		"101/736/545/101736545-alt-quattroshapes.geojson": `{"a":2}`,
+		"859/225/83/85922583.geojson":                     `{"a":3}`,
+	}
+
+	for key, body := range writes {
+
+		_, err := m.Write(ctx, key, bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", key, err)
+		}
+	}
+
+	// The same ID from another repository
+
+	_, err = m.Write(ctx, "859/225/83/85922583.geojson", bytes.NewReader([]byte(`{"a":4}`)))
+
+	if err != nil {
+		t.Fatalf("Failed to write duplicate, %v", err)
+	}
+
+	manifest := m.Manifest(ctx, "repo://")
+
+	if manifest.Count != 4 {
+		t.Fatalf("Expected count of 4, got %d", manifest.Count)
+	}
+
+	if len(manifest.Features) != 3 {
+		t.Fatalf("Expected 3 features, got %d", len(manifest.Features))
+	}
+}
+
+func TestManifestDigest(t *testing.T) {
+
+	a := map[string]string{"1": "x", "2": "y"}
+	b := map[string]string{"2": "y", "1": "x"}
+	c := map[string]string{"1": "x", "2": "z"}
+
+	if ManifestDigest(a) != ManifestDigest(b) {
+		t.Fatalf("Expected identical digests")
+	}
+
+	if ManifestDigest(a) == ManifestDigest(c) {
+		t.Fatalf("Expected different digests")
+	}
+}
+
+func TestGitCommitsSubdirectory(t *testing.T) {
+
+	ctx := context.Background()
+
+	root, _ := testGitRepo(t, "101/736/545/101736545.geojson", "859/225/83/85922583.geojson")
+
+	subdir := filepath.Join(root, "data")
+	other := t.TempDir()
+
+	err := os.MkdirAll(subdir, 0755)
+
+	if err != nil {
+		t.Fatalf("Failed to create %s, %v", subdir, err)
+	}
+
+	commits := GitCommits(ctx, root, subdir, other)
+
+	if _, ok := commits[root]; !ok {
+		t.Fatalf("Expected commit for repository root")
+	}
+
+	if _, ok := commits[subdir]; ok {
+		t.Fatalf("Expected directory inside repository to be ignored")
+	}
+
+	if _, ok := commits[other]; ok {
+		t.Fatalf("Expected non-repository to be ignored")
+	}
+}
+
+func TestGitCommitsNotRepositories(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+	path := filepath.Join(root, "features.geojsonl")
+
+	err := os.WriteFile(path, []byte(""), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	// Neither a file nor an organization name is a Git URL so neither should be listed as a remote
+
+	for _, p := range []string{path, "sfomuseum-data"} {
+
+		_, err := gitHead(ctx, p)
+
+		if !errors.Is(err, gogit.ErrRepositoryNotExists) {
+			t.Fatalf("Expected %s not to be a repository, %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/tidwall/gjson"
+)
+
+// Report geometry metrics in metres (square metres for area).
+const METRICS_UNITS_METRES string = "m"
+
+// Report geometry metrics in kilometres (square kilometres for area).
+const METRICS_UNITS_KILOMETRES string = "km"
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "metrics", NewMetricsTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MetricsTransformer implements the `Transformer` interface adding geodesic area, perimeter (or length) and vertex
+// count properties derived from the geometry of a feature. Properties are named "area_{UNITS}2", "perimeter_{UNITS}"
+// and "vertices" where {UNITS} is the value of the `Units` property.
+type MetricsTransformer struct {
+	Transformer
+	// Units is the unit of measurement for area and perimeter properties. Valid options are `METRICS_UNITS_METRES`
+	// and `METRICS_UNITS_KILOMETRES`.
+	Units string
+}
+
+// NewMetricsTransformer returns a new `MetricsTransformer` instance configured by 'uri' in the form of:
+//
+//	metrics://?units={UNITS}
+//
+// Where {UNITS} is (optionally) "m" (metres, the default) or "km" (kilometres).
+func NewMetricsTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	units := METRICS_UNITS_METRES
+
+	q := u.Query()
+
+	if q.Has("units") {
+		units = q.Get("units")
+	}
+
+	switch units {
+	case METRICS_UNITS_METRES, METRICS_UNITS_KILOMETRES:
+		// pass
+	default:
+		return nil, fmt.Errorf("Invalid '?units=' parameter, %s", units)
+	}
+
+	t := &MetricsTransformer{
+		Units: units,
+	}
+
+	return t, nil
+}
+
+// Transform adds geodesic area, perimeter and vertex count properties to 'f'. The perimeter of polygons is the
+// sum of the lengths of all their rings; for lines it is their total length. Both area and perimeter are zero
+// for points. Features without a geometry are left unchanged.
+func (t *MetricsTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() || geom_rsp.Type == gjson.Null {
+		return nil
+	}
+
+	geom, err := f.Geometry()
+
+	if err != nil {
+		return err
+	}
+
+	orb_geom := geom.Geometry()
+
+	area := geo.Area(orb_geom)
+	perimeter := geo.Length(orb_geom)
+
+	if t.Units == METRICS_UNITS_KILOMETRES {
+		area = area / 1000000.0
+		perimeter = perimeter / 1000.0
+	}
+
+	props := []property{
+		{fmt.Sprintf("area_%s2", t.Units), area},
+		{fmt.Sprintf("perimeter_%s", t.Units), perimeter},
+		{"vertices", VertexCount(orb_geom)},
+	}
+
+	body, err = setPropertiesInOrder(body, props)
+
+	if err != nil {
+		return err
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+// VertexCount returns the total number of points in 'g', including the closing point of each polygon ring.
+func VertexCount(g orb.Geometry) int {
+
+	switch geom := g.(type) {
+	case orb.Point:
+		return 1
+	case orb.MultiPoint:
+		return len(geom)
+	case orb.LineString:
+		return len(geom)
+	case orb.Ring:
+		return len(geom)
+	case orb.MultiLineString:
+
+		count := 0
+
+		for _, ls := range geom {
+			count += len(ls)
+		}
+
+		return count
+
+	case orb.Polygon:
+
+		count := 0
+
+		for _, r := range geom {
+			count += len(r)
+		}
+
+		return count
+
+	case orb.MultiPolygon:
+
+		count := 0
+
+		for _, p := range geom {
+			count += VertexCount(p)
+		}
+
+		return count
+
+	case orb.Collection:
+
+		count := 0
+
+		for _, c := range geom {
+			count += VertexCount(c)
+		}
+
+		return count
+
+	default:
+		return 0
+	}
+}
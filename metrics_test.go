@@ -0,0 +1,204 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/tidwall/gjson"
+)
+
+func TestVertexCount(t *testing.T) {
+
+	square := orb.Ring{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	hole := orb.Ring{{0.25, 0.25}, {0.75, 0.25}, {0.75, 0.75}, {0.25, 0.25}}
+
+	tests := map[string]struct {
+		geom     orb.Geometry
+		expected int
+	}{
+		"point":             {orb.Point{0, 0}, 1},
+		"multipoint":        {orb.MultiPoint{{0, 0}, {1, 1}, {2, 2}}, 3},
+		"linestring":        {orb.LineString{{0, 0}, {1, 1}}, 2},
+		"multilinestring":   {orb.MultiLineString{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}, {4, 4}}}, 5},
+		"ring":              {square, 5},
+		"polygon with hole": {orb.Polygon{square, hole}, 9},
+		"multipolygon":      {orb.MultiPolygon{{square}, {square, hole}}, 14},
+		"collection":        {orb.Collection{orb.Point{0, 0}, orb.Polygon{square}}, 6},
+		"bound":             {orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{1, 1}}, 0},
+	}
+
+	for label, test := range tests {
+
+		count := VertexCount(test.geom)
+
+		if count != test.expected {
+			t.Fatalf("Expected %d vertices for %s, got %d", test.expected, label, count)
+		}
+	}
+}
+
+func TestNewMetricsTransformerInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	_, err := NewMetricsTransformer(ctx, "metrics://?units=miles")
+
+	if err == nil {
+		t.Fatalf("Expected invalid units to fail")
+	}
+}
+
+func TestMetricsTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	// A one degree square at the equator is approximately 12,364 square kilometres with a perimeter of approximately 445 kilometres
+
+	square := `{"type":"Feature","properties":{"wof:id":1},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`
+
+	tests := []struct {
+		uri       string
+		body      string
+		area      float64
+		perimeter float64
+		vertices  int64
+		names     string
+	}{
+		{"metrics://?units=km", square, 12364, 445, 5, "wof:id,area_km2,perimeter_km,vertices"},
+		{"metrics://", square, 12364 * 1000000, 445 * 1000, 5, "wof:id,area_m2,perimeter_m,vertices"},
+		{"metrics://", `{"type":"Feature","properties":{"wof:id":1},"geometry":{"type":"Point","coordinates":[0,0]}}`, 0, 0, 1, "wof:id,area_m2,perimeter_m,vertices"},
+	}
+
+	for _, test := range tests {
+
+		tr, err := NewMetricsTransformer(ctx, test.uri)
+
+		if err != nil {
+			t.Fatalf("Failed to create transformer for %s, %v", test.uri, err)
+		}
+
+		f, err := NewFeature("1.geojson", strings.NewReader(test.body))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		err = tr.Transform(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to transform feature with %s, %v", test.uri, err)
+		}
+
+		body, err := f.Body()
+
+		if err != nil {
+			t.Fatalf("Failed to derive body, %v", err)
+		}
+
+		if names := strings.Join(testPropertyNames(body), ","); names != test.names {
+			t.Fatalf("Expected properties %s for %s, got %s", test.names, test.uri, names)
+		}
+
+		units := tr.(*MetricsTransformer).Units
+
+		area := gjson.GetBytes(body, "properties.area_"+units+"2").Float()
+		perimeter := gjson.GetBytes(body, "properties.perimeter_"+units).Float()
+
+		if math.Abs(area-test.area) > test.area*0.01 {
+			t.Fatalf("Expected area of approximately %f for %s, got %f", test.area, test.uri, area)
+		}
+
+		if math.Abs(perimeter-test.perimeter) > test.perimeter*0.01 {
+			t.Fatalf("Expected perimeter of approximately %f for %s, got %f", test.perimeter, test.uri, perimeter)
+		}
+
+		if vertices := gjson.GetBytes(body, "properties.vertices").Int(); vertices != test.vertices {
+			t.Fatalf("Expected %d vertices for %s, got %d", test.vertices, test.uri, vertices)
+		}
+	}
+}
+
+func TestMetricsTransformerMissingGeometry(t *testing.T) {
+
+	ctx := context.Background()
+
+	tr := &MetricsTransformer{
+		Units: METRICS_UNITS_METRES,
+	}
+
+	for _, body := range []string{
+		`{"type":"Feature","properties":{"wof:id":1},"geometry":null}`,
+		`{"type":"Feature","properties":{"wof:id":1}}`,
+	} {
+
+		f, err := NewFeature("1.geojson", strings.NewReader(body))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		err = tr.Transform(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Expected feature without geometry to be skipped, %v", err)
+		}
+
+		out, err := f.Body()
+
+		if err != nil {
+			t.Fatalf("Failed to derive body, %v", err)
+		}
+
+		if !bytes.Equal(out, []byte(body)) {
+			t.Fatalf("Expected feature without geometry to be unchanged, %s", out)
+		}
+	}
+}
+
+func TestDefaultPipelineMetricsAfterPoints(t *testing.T) {
+
+	ctx := context.Background()
+
+	opts := &IterwriterCallbackFuncBuilderOptions{
+		GeometryMetrics: METRICS_UNITS_METRES,
+		PointPlacetypes: []string{"locality"},
+	}
+
+	body := `{"type":"Feature","properties":{"wof:id":1,"wof:placetype":"locality","lbl:latitude":0.5,"lbl:longitude":0.5},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`
+
+	f, err := NewFeature("1.geojson", strings.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	features, err := DefaultPipeline(opts).ProcessAll(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to process feature, %v", err)
+	}
+
+	if len(features) != 1 {
+		t.Fatalf("Expected a single feature, got %d", len(features))
+	}
+
+	out, err := features[0].Body()
+
+	if err != nil {
+		t.Fatalf("Failed to derive body, %v", err)
+	}
+
+	// Metrics describe the point which is written rather than the original polygon
+
+	if gjson.GetBytes(out, "geometry.type").String() != "Point" {
+		t.Fatalf("Expected feature to be converted to a point, %s", out)
+	}
+
+	if gjson.GetBytes(out, "properties.vertices").Int() != 1 || gjson.GetBytes(out, "properties.area_m2").Float() != 0 {
+		t.Fatalf("Expected metrics for a point, %s", out)
+	}
+}
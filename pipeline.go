@@ -0,0 +1,461 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+// FEATURE_PART_SOURCE is the alternate geometry source used in the relative paths of features created by the
+// `Feature.NewPart` method.
+const FEATURE_PART_SOURCE string = "part"
+
+// Feature is a struct wrapping a Who's On First record as it is processed by a `Pipeline`. The body of the
+// record is only read when it is first requested so that stages which only need the ID or path of a record
+// (for example filtering alternate geometries) don't incur the cost of reading it.
+type Feature struct {
+	// Path is the URI of the record as reported by the iterator.
+	Path string
+	// Id is the Who's On First ID of the record.
+	Id int64
+	// RelPath is the Who's On First relative path of the record.
+	RelPath string
+	// URIArgs are the URI arguments (alternate geometry details) derived from Path.
+	URIArgs  *uri.URIArgs
+	reader   io.ReadSeeker
+	original []byte
+	body     []byte
+	part     int
+	is_part  bool
+}
+
+// NewFeature returns a new `Feature` instance derived from 'path' whose body will be read from 'r'.
+func NewFeature(path string, r io.ReadSeeker) (*Feature, error) {
+
+	id, uri_args, err := uri.ParseURI(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s, %w", path, err)
+	}
+
+	rel_path, err := uri.Id2RelPath(id, uri_args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to derive relative (WOF) path for %s, %w", path, err)
+	}
+
+	f := &Feature{
+		Path:    path,
+		Id:      id,
+		RelPath: rel_path,
+		URIArgs: uri_args,
+		reader:  r,
+	}
+
+	return f, nil
+}
+
+// NewFeatureFromRecord returns a new `Feature` instance derived from 'rec'.
+func NewFeatureFromRecord(rec *iterate.Record) (*Feature, error) {
+	return NewFeature(rec.Path, rec.Body)
+}
+
+// IsAlternate returns true if 'f' is an alternate geometry record.
+func (f *Feature) IsAlternate() bool {
+	return f.URIArgs != nil && f.URIArgs.IsAlternate
+}
+
+// Body returns the current (possibly transformed) body of 'f', reading it if necessary.
+func (f *Feature) Body() ([]byte, error) {
+
+	if f.body != nil {
+		return f.body, nil
+	}
+
+	original, err := f.Original()
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.body = original
+	return f.body, nil
+}
+
+// Geometry returns the geometry of the current body of 'f'.
+func (f *Feature) Geometry() (*geojson.Geometry, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() || geom_rsp.Type == gjson.Null {
+		return nil, fmt.Errorf("%s is missing geometry", f.Path)
+	}
+
+	geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal geometry for %s, %w", f.Path, err)
+	}
+
+	return geom, nil
+}
+
+// Properties returns the properties of the current body of 'f'.
+func (f *Feature) Properties() (map[string]interface{}, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]interface{})
+
+	props_rsp := gjson.GetBytes(body, "properties")
+
+	if !props_rsp.Exists() {
+		return props, nil
+	}
+
+	err = json.Unmarshal([]byte(props_rsp.Raw), &props)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal properties for %s, %w", f.Path, err)
+	}
+
+	return props, nil
+}
+
+// SetBody replaces the current body of 'f' with 'body'.
+func (f *Feature) SetBody(body []byte) {
+	f.body = body
+}
+
+// NewPart returns a new `Feature` instance derived from 'f' whose current body is 'body'. Parts share the ID, URI
+// arguments and original body of 'f' but have a distinct relative path, in the form of an alternate geometry URI with
+// the source "part", the function 'idx' and, if 'f' is an alternate geometry, its label as extras (for example
+// "123-alt-part-0.geojson" or "123-alt-part-0-quattroshapes.geojson"), so that each part can be written (and deduplicated)
+// separately. Because the URI arguments are shared the `IsAlternate` method of a part reflects 'f'.
+func (f *Feature) NewPart(idx int, body []byte) (*Feature, error) {
+
+	original, err := f.Original()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var extras []string
+
+	// Include the label of alternate geometries so that their parts don't collide with the parts of the
+	// principal geometry (or other alternate geometries)
+
+	if f.URIArgs != nil && f.URIArgs.IsAlternate {
+
+		label, err := f.URIArgs.AltGeom.String()
+
+		if err != nil {
+			return nil, fmt.Errorf("Unable to derive alternate geometry label for %s, %w", f.Path, err)
+		}
+
+		extras = strings.Split(label, "-")
+	}
+
+	part_args := uri.NewAlternateURIArgs(FEATURE_PART_SOURCE, strconv.Itoa(idx), extras...)
+
+	rel_path, err := uri.Id2RelPath(f.Id, part_args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to derive relative (WOF) path for part %d of %s, %w", idx, f.Path, err)
+	}
+
+	part := &Feature{
+		Path:     f.Path,
+		Id:       f.Id,
+		RelPath:  rel_path,
+		URIArgs:  f.URIArgs,
+		original: original,
+		body:     body,
+		part:     idx,
+		is_part:  true,
+	}
+
+	return part, nil
+}
+
+// Part returns the (zero-based) index of 'f' if it was created by the `NewPart` method and a boolean value
+// indicating whether it was.
+func (f *Feature) Part() (int, bool) {
+	return f.part, f.is_part
+}
+
+// Original returns the body of 'f' as it was read, before any transformations were applied.
+func (f *Feature) Original() ([]byte, error) {
+
+	if f.original != nil {
+		return f.original, nil
+	}
+
+	if f.reader == nil {
+		return nil, fmt.Errorf("Feature has no body")
+	}
+
+	body, err := io.ReadAll(f.reader)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read body for %s, %w", f.Path, err)
+	}
+
+	f.original = body
+	return f.original, nil
+}
+
+// Reader returns an `io.ReadSeeker` for the current body of 'f'. If the body has never been read the
+// original reader is returned as-is.
+func (f *Feature) Reader() (io.ReadSeeker, error) {
+
+	if f.body == nil && f.original == nil && f.reader != nil {
+		return f.reader, nil
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(body), nil
+}
+
+// Filter is an interface for deciding whether a `Feature` should be included in output.
+type Filter interface {
+	// Include returns true if a `Feature` should be included in output.
+	Include(context.Context, *Feature) (bool, error)
+}
+
+// Transformer is an interface for modifying a `Feature` before it is written.
+type Transformer interface {
+	// Transform modifies a `Feature` in place, typically by calling its `SetBody` method.
+	Transform(context.Context, *Feature) error
+}
+
+// Splitter is an interface for replacing a `Feature` with zero or more new features, for example to split a
+// GeometryCollection into features for each of its members.
+type Splitter interface {
+	// Split returns the features that replace a `Feature`. Returning a single-item list containing the original
+	// `Feature` leaves it unchanged.
+	Split(context.Context, *Feature) ([]*Feature, error)
+}
+
+// FilterFunc is a function that implements the `Filter` interface.
+type FilterFunc func(context.Context, *Feature) (bool, error)
+
+// Include invokes 'fn' with 'f'.
+func (fn FilterFunc) Include(ctx context.Context, f *Feature) (bool, error) {
+	return fn(ctx, f)
+}
+
+// TransformerFunc is a function that implements the `Transformer` interface.
+type TransformerFunc func(context.Context, *Feature) error
+
+// Transform invokes 'fn' with 'f'.
+func (fn TransformerFunc) Transform(ctx context.Context, f *Feature) error {
+	return fn(ctx, f)
+}
+
+// SplitterFunc is a function that implements the `Splitter` interface.
+type SplitterFunc func(context.Context, *Feature) ([]*Feature, error)
+
+// Split invokes 'fn' with 'f'.
+func (fn SplitterFunc) Split(ctx context.Context, f *Feature) ([]*Feature, error) {
+	return fn(ctx, f)
+}
+
+// Pipeline is an ordered sequence of `Filter`, `Transformer` and `Splitter` stages applied to each `Feature`.
+type Pipeline struct {
+	stages []*pipelineStage
+}
+
+type pipelineStage struct {
+	filter      Filter
+	transformer Transformer
+	splitter    Splitter
+}
+
+// NewPipeline returns a new (empty) `Pipeline` instance.
+func NewPipeline() *Pipeline {
+
+	p := &Pipeline{
+		stages: make([]*pipelineStage, 0),
+	}
+
+	return p
+}
+
+// AddFilter appends 'filter' to the list of stages in 'p'.
+func (p *Pipeline) AddFilter(filter Filter) *Pipeline {
+	p.stages = append(p.stages, &pipelineStage{filter: filter})
+	return p
+}
+
+// AddTransformer appends 'transformer' to the list of stages in 'p'. If 'transformer' also implements the
+// `Splitter` interface it is added as a splitter stage. This allows splitters to be registered, and created,
+// using the `RegisterTransformer` and `NewTransformer` methods.
+func (p *Pipeline) AddTransformer(transformer Transformer) *Pipeline {
+
+	splitter, ok := transformer.(Splitter)
+
+	if ok {
+		return p.AddSplitter(splitter)
+	}
+
+	p.stages = append(p.stages, &pipelineStage{transformer: transformer})
+	return p
+}
+
+// AddSplitter appends 'splitter' to the list of stages in 'p'.
+func (p *Pipeline) AddSplitter(splitter Splitter) *Pipeline {
+	p.stages = append(p.stages, &pipelineStage{splitter: splitter})
+	return p
+}
+
+// Process applies each stage in 'p', in order, to 'f'. It returns false if 'f' was excluded by a `Filter`
+// stage, in which case no further stages are applied. An error is returned if a `Splitter` stage replaces
+// 'f' with other features; use the `ProcessAll` method for pipelines which may do so.
+func (p *Pipeline) Process(ctx context.Context, f *Feature) (bool, error) {
+
+	features, err := p.ProcessAll(ctx, f)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case len(features) == 0:
+		return false, nil
+	case len(features) == 1 && features[0] == f:
+		return true, nil
+	default:
+		return false, fmt.Errorf("Pipeline replaced %s with %d features, use ProcessAll", f.Path, len(features))
+	}
+}
+
+// ProcessAll applies each stage in 'p', in order, to 'f' returning the features to be written. Stages following
+// a `Splitter` stage are applied to each of the features it returns. Features excluded by a `Filter` stage are
+// omitted so an empty list means that 'f' (and anything derived from it) was excluded.
+func (p *Pipeline) ProcessAll(ctx context.Context, f *Feature) ([]*Feature, error) {
+
+	// Each invocation gets its own state so that stages can keep track of the parts of the source record
+	// that they have already seen (see claimPart)
+
+	state := &processState{
+		claimed: make(map[any]bool),
+	}
+
+	ctx = context.WithValue(ctx, processStateContextKey{}, state)
+
+	return p.processStages(ctx, p.stages, f)
+}
+
+// processStateContextKey is the (unexported) key used to store the `processState` of a `Pipeline.ProcessAll`
+// invocation in a `context.Context`.
+type processStateContextKey struct{}
+
+// processState is the state shared by the stages of a single `Pipeline.ProcessAll` invocation.
+type processState struct {
+	claimed map[any]bool
+}
+
+// isClaimed returns true if 'key' has been claimed, using the `claimPart` method, during the current
+// `Pipeline.ProcessAll` invocation. It always returns false outside of `Pipeline.ProcessAll`.
+func isClaimed(ctx context.Context, key any) bool {
+
+	state, ok := ctx.Value(processStateContextKey{}).(*processState)
+
+	if !ok {
+		return false
+	}
+
+	return state.claimed[key]
+}
+
+// claimPart records that a stage, identified by 'key', has emitted a feature for the source record of the
+// current `Pipeline.ProcessAll` invocation. This allows a stage to emit a single feature for the first part
+// of a record that reaches it regardless of which parts were excluded by earlier stages.
+func claimPart(ctx context.Context, key any) {
+
+	state, ok := ctx.Value(processStateContextKey{}).(*processState)
+
+	if !ok {
+		return
+	}
+
+	state.claimed[key] = true
+}
+
+func (p *Pipeline) processStages(ctx context.Context, stages []*pipelineStage, f *Feature) ([]*Feature, error) {
+
+	for i, s := range stages {
+
+		switch {
+		case s.filter != nil:
+
+			ok, err := s.filter.Include(ctx, f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				return nil, nil
+			}
+
+		case s.splitter != nil:
+
+			parts, err := s.splitter.Split(ctx, f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			features := make([]*Feature, 0)
+
+			for _, part := range parts {
+
+				part_features, err := p.processStages(ctx, stages[i+1:], part)
+
+				if err != nil {
+					return nil, err
+				}
+
+				features = append(features, part_features...)
+			}
+
+			return features, nil
+
+		default:
+
+			err := s.transformer.Transform(ctx, f)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return []*Feature{f}, nil
+}
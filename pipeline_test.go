@@ -0,0 +1,238 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const testPipelineFeature string = `{"type":"Feature","properties":{"wof:id":1234,"wof:name":"Test"},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+// testSplitter implements both the `Transformer` and `Splitter` interfaces replacing a feature with 'count' parts.
+type testSplitter struct {
+	count int
+}
+
+func (s *testSplitter) Transform(ctx context.Context, f *Feature) error {
+	return fmt.Errorf("Transform should not be called")
+}
+
+func (s *testSplitter) Split(ctx context.Context, f *Feature) ([]*Feature, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]*Feature, s.count)
+
+	for i := 0; i < s.count; i++ {
+
+		part_body, err := sjson.SetBytes(body, "properties.part_index", i)
+
+		if err != nil {
+			return nil, err
+		}
+
+		part, err := f.NewPart(i, part_body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		parts[i] = part
+	}
+
+	return parts, nil
+}
+
+func testPipelineFeatureFor(t *testing.T) *Feature {
+
+	f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(testPipelineFeature)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	return f
+}
+
+// testCountingTransformer returns a `Transformer` which increments the "count" property of each feature.
+func testCountingTransformer() Transformer {
+
+	return TransformerFunc(func(ctx context.Context, f *Feature) error {
+
+		body, err := f.Body()
+
+		if err != nil {
+			return err
+		}
+
+		body, err = sjson.SetBytes(body, "properties.count", gjson.GetBytes(body, "properties.count").Int()+1)
+
+		if err != nil {
+			return err
+		}
+
+		f.SetBody(body)
+		return nil
+	})
+}
+
+func TestPipelineProcessAll(t *testing.T) {
+
+	ctx := context.Background()
+
+	exclude := FilterFunc(func(ctx context.Context, f *Feature) (bool, error) {
+		return false, nil
+	})
+
+	exclude_odd_parts := FilterFunc(func(ctx context.Context, f *Feature) (bool, error) {
+		idx, _ := f.Part()
+		return idx%2 == 0, nil
+	})
+
+	failing := TransformerFunc(func(ctx context.Context, f *Feature) error {
+		return fmt.Errorf("Failed")
+	})
+
+	tests := []struct {
+		label    string
+		pipeline *Pipeline
+		count    int
+		expected int64
+		err      bool
+	}{
+		{"empty", NewPipeline(), 1, 0, false},
+		{"transformers", NewPipeline().AddTransformer(testCountingTransformer()).AddTransformer(testCountingTransformer()), 1, 2, false},
+		{"filter", NewPipeline().AddFilter(exclude).AddTransformer(failing), 0, 0, false},
+		{"transformer before filter", NewPipeline().AddTransformer(testCountingTransformer()).AddFilter(exclude), 0, 0, false},
+		{"error", NewPipeline().AddTransformer(failing).AddTransformer(testCountingTransformer()), 0, 0, true},
+		{"splitter", NewPipeline().AddSplitter(&testSplitter{count: 3}).AddTransformer(testCountingTransformer()), 3, 1, false},
+		{"splitter via AddTransformer", NewPipeline().AddTransformer(&testSplitter{count: 3}), 3, 0, false},
+		{"splitter then filter", NewPipeline().AddSplitter(&testSplitter{count: 5}).AddFilter(exclude_odd_parts).AddTransformer(testCountingTransformer()), 3, 1, false},
+		{"nested splitters", NewPipeline().AddTransformer(testCountingTransformer()).AddSplitter(&testSplitter{count: 2}).AddSplitter(&testSplitter{count: 3}).AddTransformer(testCountingTransformer()), 6, 2, false},
+		{"splitter returning nothing", NewPipeline().AddSplitter(&testSplitter{count: 0}).AddTransformer(failing), 0, 0, false},
+		{"splitter then error", NewPipeline().AddSplitter(&testSplitter{count: 2}).AddTransformer(failing), 0, 0, true},
+	}
+
+	for _, test := range tests {
+
+		features, err := test.pipeline.ProcessAll(ctx, testPipelineFeatureFor(t))
+
+		if test.err {
+
+			if err == nil {
+				t.Fatalf("Expected '%s' pipeline to fail", test.label)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to process '%s' pipeline, %v", test.label, err)
+		}
+
+		if len(features) != test.count {
+			t.Fatalf("Expected %d features from '%s' pipeline, got %d", test.count, test.label, len(features))
+		}
+
+		for _, f := range features {
+
+			body, err := f.Body()
+
+			if err != nil {
+				t.Fatalf("Failed to read body, %v", err)
+			}
+
+			count := gjson.GetBytes(body, "properties.count").Int()
+
+			if count != test.expected {
+				t.Fatalf("Expected each feature from '%s' pipeline to be transformed %d times, got %d", test.label, test.expected, count)
+			}
+		}
+	}
+}
+
+func TestPipelineProcess(t *testing.T) {
+
+	ctx := context.Background()
+
+	exclude := FilterFunc(func(ctx context.Context, f *Feature) (bool, error) {
+		return false, nil
+	})
+
+	unchanged := SplitterFunc(func(ctx context.Context, f *Feature) ([]*Feature, error) {
+		return []*Feature{f}, nil
+	})
+
+	tests := []struct {
+		label    string
+		pipeline *Pipeline
+		ok       bool
+		err      bool
+	}{
+		{"transformer", NewPipeline().AddTransformer(testCountingTransformer()), true, false},
+		{"filter", NewPipeline().AddFilter(exclude), false, false},
+		{"unchanged splitter", NewPipeline().AddSplitter(unchanged), true, false},
+		{"empty splitter", NewPipeline().AddSplitter(&testSplitter{count: 0}), false, false},
+		// A splitter may not replace the feature, even with a single part, when using Process
+		{"replacing splitter", NewPipeline().AddSplitter(&testSplitter{count: 1}), false, true},
+		{"multiple parts", NewPipeline().AddSplitter(&testSplitter{count: 2}), false, true},
+	}
+
+	for _, test := range tests {
+
+		f := testPipelineFeatureFor(t)
+
+		ok, err := test.pipeline.Process(ctx, f)
+
+		if test.err != (err != nil) {
+			t.Fatalf("Unexpected error for '%s' pipeline, %v", test.label, err)
+		}
+
+		if ok != test.ok {
+			t.Fatalf("Expected '%s' pipeline to return %t, got %t", test.label, test.ok, ok)
+		}
+	}
+}
+
+func TestFeatureNewPart(t *testing.T) {
+
+	f := testPipelineFeatureFor(t)
+
+	part, err := f.NewPart(2, []byte(`{"type":"Feature","properties":{}}`))
+
+	if err != nil {
+		t.Fatalf("Failed to create part, %v", err)
+	}
+
+	idx, is_part := part.Part()
+
+	if !is_part || idx != 2 {
+		t.Fatalf("Expected part 2, got %d (%t)", idx, is_part)
+	}
+
+	if part.RelPath != "123/4/1234-alt-part-2.geojson" {
+		t.Fatalf("Unexpected relative path for part, %s", part.RelPath)
+	}
+
+	original, err := part.Original()
+
+	if err != nil {
+		t.Fatalf("Failed to read original body, %v", err)
+	}
+
+	if string(original) != testPipelineFeature {
+		t.Fatalf("Expected part to share the original body of its feature")
+	}
+
+	if _, is_part := f.Part(); is_part {
+		t.Fatalf("Expected feature not to be a part")
+	}
+}
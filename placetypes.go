@@ -0,0 +1,298 @@
+package tippecanoe
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// placetypes.json lists every placetype, and its immediate parents, defined by the whosonfirst/whosonfirst-placetypes
+// specification. It is embedded, rather than importing whosonfirst/go-whosonfirst-placetypes, so that the parent
+// relationships (which are all this package needs) can be read without any additional dependencies.
+//
+//go:embed placetypes.json
+var placetypes_data []byte
+
+var placetypes_graph map[string]*Placetype
+
+var placetypes_once sync.Once
+
+var placetypes_err error
+
+// Placetype is a struct describing an individual Who's On First placetype and its immediate parents.
+type Placetype struct {
+	// Name is the name of the placetype.
+	Name string `json:"name"`
+	// Parent is the list of placetypes that may immediately contain this placetype.
+	Parent []string `json:"parent"`
+}
+
+func loadPlacetypes() (map[string]*Placetype, error) {
+
+	placetypes_once.Do(func() {
+
+		var pts []*Placetype
+
+		err := json.Unmarshal(placetypes_data, &pts)
+
+		if err != nil {
+			placetypes_err = fmt.Errorf("Failed to unmarshal placetypes, %w", err)
+			return
+		}
+
+		graph := make(map[string]*Placetype)
+
+		for _, pt := range pts {
+			graph[pt.Name] = pt
+		}
+
+		placetypes_graph = graph
+	})
+
+	return placetypes_graph, placetypes_err
+}
+
+// Placetypes returns the sorted list of Who's On First placetype names known to this package.
+func Placetypes() ([]string, error) {
+
+	graph, err := loadPlacetypes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(graph))
+
+	for name := range graph {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names, nil
+}
+
+// IsValidPlacetype returns true if 'name' is a Who's On First placetype known to this package.
+func IsValidPlacetype(name string) bool {
+
+	graph, err := loadPlacetypes()
+
+	if err != nil {
+		return false
+	}
+
+	_, ok := graph[name]
+	return ok
+}
+
+// PlacetypeAncestors returns the sorted list of placetypes that may contain 'name', including 'name' itself.
+func PlacetypeAncestors(name string) ([]string, error) {
+
+	graph, err := loadPlacetypes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := graph[name]
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid placetype '%s'", name)
+	}
+
+	seen := make(map[string]bool)
+	queue := []string{name}
+
+	for len(queue) > 0 {
+
+		pt := queue[0]
+		queue = queue[1:]
+
+		if seen[pt] {
+			continue
+		}
+
+		seen[pt] = true
+		queue = append(queue, graph[pt].Parent...)
+	}
+
+	return sortedKeys(seen), nil
+}
+
+// PlacetypeDescendants returns the sorted list of placetypes that may be contained by 'name', including 'name' itself.
+func PlacetypeDescendants(name string) ([]string, error) {
+
+	graph, err := loadPlacetypes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := graph[name]
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid placetype '%s'", name)
+	}
+
+	children := make(map[string][]string)
+
+	for _, pt := range graph {
+
+		for _, parent := range pt.Parent {
+			children[parent] = append(children[parent], pt.Name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	queue := []string{name}
+
+	for len(queue) > 0 {
+
+		pt := queue[0]
+		queue = queue[1:]
+
+		if seen[pt] {
+			continue
+		}
+
+		seen[pt] = true
+		queue = append(queue, children[pt]...)
+	}
+
+	return sortedKeys(seen), nil
+}
+
+// PlacetypeSelectionOptions is a struct containing configuration options for the `NewPlacetypeSelection` method.
+type PlacetypeSelectionOptions struct {
+	// Placetypes is zero or more placetypes to include.
+	Placetypes []string
+	// ExcludePlacetypes is zero or more placetypes to exclude.
+	ExcludePlacetypes []string
+	// DescendantsOf is zero or more placetypes whose descendants (and themselves) should be included.
+	DescendantsOf []string
+	// AncestorsOf is zero or more placetypes whose ancestors (and themselves) should be included.
+	AncestorsOf []string
+}
+
+// PlacetypeSelection is a struct defining the set of placetypes to include in, or exclude from, output.
+type PlacetypeSelection struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// NewPlacetypeSelection returns a new `PlacetypeSelection` instance derived from 'opts'. Included placetypes are the
+// union of 'opts.Placetypes', 'opts.DescendantsOf' and 'opts.AncestorsOf' (and all placetypes if none are defined)
+// less 'opts.ExcludePlacetypes'. An error is returned if any of the placetypes are unknown.
+func NewPlacetypeSelection(opts *PlacetypeSelectionOptions) (*PlacetypeSelection, error) {
+
+	include := make(map[string]bool)
+	exclude := make(map[string]bool)
+
+	for _, pt := range opts.Placetypes {
+
+		if !IsValidPlacetype(pt) {
+			return nil, fmt.Errorf("Invalid placetype '%s'", pt)
+		}
+
+		include[pt] = true
+	}
+
+	for _, pt := range opts.DescendantsOf {
+
+		descendants, err := PlacetypeDescendants(pt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range descendants {
+			include[d] = true
+		}
+	}
+
+	for _, pt := range opts.AncestorsOf {
+
+		ancestors, err := PlacetypeAncestors(pt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range ancestors {
+			include[a] = true
+		}
+	}
+
+	for _, pt := range opts.ExcludePlacetypes {
+
+		if !IsValidPlacetype(pt) {
+			return nil, fmt.Errorf("Invalid placetype '%s'", pt)
+		}
+
+		exclude[pt] = true
+	}
+
+	s := &PlacetypeSelection{
+		include: include,
+		exclude: exclude,
+	}
+
+	return s, nil
+}
+
+// Includes returns true if 'placetype' is included by 's'.
+func (s *PlacetypeSelection) Includes(placetype string) bool {
+
+	if s.exclude[placetype] {
+		return false
+	}
+
+	if len(s.include) == 0 {
+		return true
+	}
+
+	return s.include[placetype]
+}
+
+// Placetypes returns the sorted list of placetypes explicitly included by 's'. An empty list means that all
+// placetypes, except those explicitly excluded, are included.
+func (s *PlacetypeSelection) Placetypes() []string {
+	return sortedKeys(s.include)
+}
+
+// PlacetypeFilter implements the `Filter` interface excluding records whose "wof:placetype" property is not
+// included by a `PlacetypeSelection` instance.
+type PlacetypeFilter struct {
+	Filter
+	// Selection is the `PlacetypeSelection` instance used to test records.
+	Selection *PlacetypeSelection
+}
+
+// Include returns true if the "wof:placetype" property of 'f' is included by 'filter.Selection'.
+func (filter *PlacetypeFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	pt_rsp := gjson.GetBytes(body, "properties.wof:placetype")
+	return filter.Selection.Includes(pt_rsp.String()), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
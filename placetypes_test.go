@@ -0,0 +1,92 @@
+package tippecanoe
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlacetypesGraph(t *testing.T) {
+
+	graph, err := loadPlacetypes()
+
+	if err != nil {
+		t.Fatalf("Failed to load placetypes, %v", err)
+	}
+
+	for name, pt := range graph {
+
+		for _, parent := range pt.Parent {
+
+			_, ok := graph[parent]
+
+			if !ok {
+				t.Fatalf("Placetype '%s' has unknown parent '%s'", name, parent)
+			}
+		}
+	}
+
+	for _, name := range []string{"metroarea", "marketarea", "arcade", "postalregion", "marinearea", "installation"} {
+
+		if !IsValidPlacetype(name) {
+			t.Fatalf("Expected '%s' to be a valid placetype", name)
+		}
+	}
+}
+
+func TestPlacetypeAncestors(t *testing.T) {
+
+	tests := map[string][]string{
+		"locality":  {"country", "region", "county", "localadmin", "continent", "planet"},
+		"metroarea": {"country", "region"},
+		"venue":     {"building", "locality", "neighbourhood", "country"},
+	}
+
+	for name, expected := range tests {
+
+		ancestors, err := PlacetypeAncestors(name)
+
+		if err != nil {
+			t.Fatalf("Failed to derive ancestors for '%s', %v", name, err)
+		}
+
+		if !slices.Contains(ancestors, name) {
+			t.Fatalf("Expected ancestors of '%s' to include itself", name)
+		}
+
+		for _, pt := range expected {
+
+			if !slices.Contains(ancestors, pt) {
+				t.Fatalf("Expected ancestors of '%s' to include '%s', %v", name, pt, ancestors)
+			}
+		}
+	}
+}
+
+func TestPlacetypeDescendants(t *testing.T) {
+
+	descendants, err := PlacetypeDescendants("region")
+
+	if err != nil {
+		t.Fatalf("Failed to derive descendants, %v", err)
+	}
+
+	for _, pt := range []string{"region", "county", "locality", "neighbourhood", "venue", "metroarea"} {
+
+		if !slices.Contains(descendants, pt) {
+			t.Fatalf("Expected descendants of region to include '%s', %v", pt, descendants)
+		}
+	}
+
+	for _, pt := range []string{"country", "continent", "ocean"} {
+
+		if slices.Contains(descendants, pt) {
+			t.Fatalf("Expected descendants of region to exclude '%s'", pt)
+		}
+	}
+
+	_, err = PlacetypeDescendants("galaxy")
+
+	if err == nil {
+		t.Fatalf("Expected unknown placetype to fail")
+	}
+}
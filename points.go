@@ -0,0 +1,402 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// The default population used as the upper bound when normalizing population values.
+const POINTS_DEFAULT_MAX_POPULATION float64 = 10000000
+
+// POINTS_DEFAULT_POPULATION_BUCKETS are the default population thresholds used to assign population classes. A
+// feature's class is the number of thresholds its population is greater than or equal to.
+var POINTS_DEFAULT_POPULATION_BUCKETS = []float64{
+	1000,
+	10000,
+	50000,
+	100000,
+	250000,
+	500000,
+	1000000,
+	5000000,
+	10000000,
+}
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "points", NewPointsTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// PointsTransformer implements the `Transformer` interface replacing the geometries of features with specific
+// placetypes with a centroid Point and adding "population", "population_norm" and "population_class" properties.
+// The centroid is derived from the "lbl:latitude" and "lbl:longitude" properties (or "mps:latitude" and
+// "mps:longitude") falling back to the centroid of the geometry itself. Population is the "wof:population"
+// property (or "gn:population" if absent). "population_norm" is log10(population + 1) / log10(MaxPopulation + 1)
+// clamped to a value between 0 and 1 and "population_class" is the number of `Buckets` that population is greater
+// than or equal to. Population properties are omitted for features without a population. All properties are read
+// from the original body of the feature so this can be used with SPR output. It also implements the `Splitter`
+// interface so that, when it follows a splitter like `ExplodeSplitter`, only one point is emitted for each source
+// record rather than an identical point for every part.
+type PointsTransformer struct {
+	Transformer
+	// Placetypes is the list of placetypes to convert. If empty all features are converted.
+	Placetypes []string
+	// MaxPopulation is the population used as the upper bound when normalizing population values.
+	MaxPopulation float64
+	// Buckets is the (sorted) list of population thresholds used to assign population classes.
+	Buckets []float64
+}
+
+// NewPointsTransformer returns a new `PointsTransformer` instance configured by 'uri' in the form of:
+//
+//	points://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?placetype=` Zero or more placetypes to convert. If empty all features are converted.
+// * `?max-population=` The population used as the upper bound when normalizing population values. Default is 10000000.
+// * `?buckets=` A comma-separated list of unique, non-negative, population thresholds used to assign population classes. Default is 1000,10000,50000,100000,250000,500000,1000000,5000000,10000000.
+//
+// The return value is a `Transformer` so that it can be registered using `RegisterTransformer`; `Pipeline.AddTransformer`
+// adds it as a `Splitter` stage.
+func NewPointsTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	t := &PointsTransformer{
+		MaxPopulation: POINTS_DEFAULT_MAX_POPULATION,
+		Buckets:       POINTS_DEFAULT_POPULATION_BUCKETS,
+	}
+
+	for _, str_pt := range q["placetype"] {
+
+		for _, pt := range strings.Split(str_pt, ",") {
+
+			if !IsValidPlacetype(pt) {
+				return nil, fmt.Errorf("Invalid '?placetype=' parameter, unknown placetype '%s'", pt)
+			}
+
+			t.Placetypes = append(t.Placetypes, pt)
+		}
+	}
+
+	if q.Has("max-population") {
+
+		v, err := strconv.ParseFloat(q.Get("max-population"), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?max-population=' parameter, %w", err)
+		}
+
+		if v <= 0 {
+			return nil, fmt.Errorf("Invalid '?max-population=' parameter, must be greater than zero")
+		}
+
+		t.MaxPopulation = v
+	}
+
+	if q.Has("buckets") {
+
+		buckets := make([]float64, 0)
+
+		for _, str_b := range strings.Split(q.Get("buckets"), ",") {
+
+			v, err := strconv.ParseFloat(strings.TrimSpace(str_b), 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse '?buckets=' parameter, %w", err)
+			}
+
+			if v < 0 || math.IsNaN(v) {
+				return nil, fmt.Errorf("Invalid '?buckets=' parameter, thresholds must be zero or greater")
+			}
+
+			if slices.Contains(buckets, v) {
+				return nil, fmt.Errorf("Invalid '?buckets=' parameter, duplicate threshold %v", v)
+			}
+
+			buckets = append(buckets, v)
+		}
+
+		slices.Sort(buckets)
+		t.Buckets = buckets
+	}
+
+	return t, nil
+}
+
+// Split returns an empty list if 'f' is a part of a feature that would be converted and another part of the same
+// source record has already been converted by 't', since every part would be converted to the same point. Otherwise
+// it applies the `Transform` method to 'f' and returns a single-item list containing 'f'. Parts are tracked for the
+// duration of a single `Pipeline.ProcessAll` invocation so the point is emitted for the first part to reach 't',
+// even if earlier parts were excluded by a preceding `Filter` stage. Outside of `Pipeline.ProcessAll` every part is
+// converted.
+func (t *PointsTransformer) Split(ctx context.Context, f *Feature) ([]*Feature, error) {
+
+	ok, err := t.converts(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, is_part := f.Part()
+
+	if ok && is_part && isClaimed(ctx, t) {
+		return []*Feature{}, nil
+	}
+
+	converted, err := t.convert(ctx, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if converted && is_part {
+		claimPart(ctx, t)
+	}
+
+	return []*Feature{f}, nil
+}
+
+// Transform replaces the geometry of 'f' with a centroid Point, and adds population properties, if its placetype
+// is one of 't.Placetypes'. Features whose centroid can not be determined (for example an empty geometry or a
+// GeometryCollection with no area) are left unchanged and a warning is logged.
+func (t *PointsTransformer) Transform(ctx context.Context, f *Feature) error {
+	_, err := t.convert(ctx, f)
+	return err
+}
+
+// convert applies the `Transform` method to 'f' returning true if its geometry was replaced with a centroid.
+func (t *PointsTransformer) convert(ctx context.Context, f *Feature) (bool, error) {
+
+	ok, err := t.converts(f)
+
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		return false, nil
+	}
+
+	original, err := f.Original()
+
+	if err != nil {
+		return false, err
+	}
+
+	props := gjson.GetBytes(original, "properties")
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	pt, ok, err := t.centroid(f, props)
+
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		slog.Warn("Unable to derive centroid, leaving geometry unchanged", "path", f.Path, "rel_path", f.RelPath)
+		return false, nil
+	}
+
+	enc, err := json.Marshal(geojson.NewGeometry(pt))
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to marshal centroid for %s, %w", f.Path, err)
+	}
+
+	body, err = sjson.SetRawBytes(body, "geometry", enc)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to assign centroid for %s, %w", f.Path, err)
+	}
+
+	if gjson.GetBytes(body, "bbox").Exists() {
+
+		body, err = sjson.SetBytes(body, "bbox", []float64{pt.X(), pt.Y(), pt.X(), pt.Y()})
+
+		if err != nil {
+			return false, fmt.Errorf("Failed to assign bbox for %s, %w", f.Path, err)
+		}
+	}
+
+	population := props.Get("wof:population").Float()
+
+	if population <= 0 {
+		population = props.Get("gn:population").Float()
+	}
+
+	if population > 0 {
+
+		pop_props := []property{
+			{"population", int64(population)},
+			{"population_norm", t.NormalizePopulation(population)},
+			{"population_class", t.PopulationClass(population)},
+		}
+
+		body, err = setPropertiesInOrder(body, pop_props)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	f.SetBody(body)
+	return true, nil
+}
+
+// NormalizePopulation returns log10(population + 1) / log10(t.MaxPopulation + 1), rounded to four decimal places
+// and clamped to a value between 0 and 1.
+func (t *PointsTransformer) NormalizePopulation(population float64) float64 {
+
+	if population <= 0 {
+		return 0.0
+	}
+
+	norm := math.Log10(population+1) / math.Log10(t.MaxPopulation+1)
+	norm = min(max(norm, 0.0), 1.0)
+
+	return math.Round(norm*10000) / 10000
+}
+
+// PopulationClass returns the number of thresholds in 't.Buckets' that 'population' is greater than or equal to.
+func (t *PointsTransformer) PopulationClass(population float64) int {
+
+	class := 0
+
+	for _, b := range t.Buckets {
+
+		if population < b {
+			break
+		}
+
+		class += 1
+	}
+
+	return class
+}
+
+// converts returns true if the placetype of 'f' is one of 't.Placetypes' (or 't.Placetypes' is empty).
+func (t *PointsTransformer) converts(f *Feature) (bool, error) {
+
+	if len(t.Placetypes) == 0 {
+		return true, nil
+	}
+
+	original, err := f.Original()
+
+	if err != nil {
+		return false, err
+	}
+
+	pt := gjson.GetBytes(original, "properties.wof:placetype").String()
+	return slices.Contains(t.Placetypes, pt), nil
+}
+
+// centroid returns the label (or math) centroid of 'f' falling back to the centroid of its geometry and a boolean
+// value indicating whether a centroid could be determined. A geometry centroid is only returned if it has an area
+// or length (or non-empty set of points) to be derived from rather than the zero point returned for empty geometries.
+func (t *PointsTransformer) centroid(f *Feature, props gjson.Result) (orb.Point, bool, error) {
+
+	for _, prefix := range []string{"lbl", "mps"} {
+
+		lat_rsp := props.Get(fmt.Sprintf("%s:latitude", prefix))
+		lon_rsp := props.Get(fmt.Sprintf("%s:longitude", prefix))
+
+		if lat_rsp.Exists() && lon_rsp.Exists() {
+			return orb.Point{lon_rsp.Float(), lat_rsp.Float()}, true, nil
+		}
+	}
+
+	var geom *geojson.Geometry
+
+	_, is_part := f.Part()
+
+	if is_part {
+
+		// Use the geometry of the source record rather than just this part
+
+		original, err := f.Original()
+
+		if err != nil {
+			return orb.Point{}, false, err
+		}
+
+		v, err := geojson.UnmarshalGeometry([]byte(gjson.GetBytes(original, "geometry").Raw))
+
+		if err != nil {
+			return orb.Point{}, false, fmt.Errorf("Failed to unmarshal geometry for %s, %w", f.Path, err)
+		}
+
+		geom = v
+
+	} else {
+
+		v, err := f.Geometry()
+
+		if err != nil {
+			return orb.Point{}, false, err
+		}
+
+		geom = v
+	}
+
+	if geom == nil {
+		return orb.Point{}, false, nil
+	}
+
+	orb_geom := geom.Geometry()
+
+	if orb_geom == nil {
+		return orb.Point{}, false, nil
+	}
+
+	pt, area := planar.CentroidArea(orb_geom)
+
+	if area > 0 {
+		return pt, true, nil
+	}
+
+	// planar.CentroidArea returns a zero point, rather than an error, for geometries without an area or
+	// length (including GeometryCollections of points and lines) so only trust it when there is a basis
+
+	switch g := orb_geom.(type) {
+	case orb.Point:
+		return g, true, nil
+	case orb.MultiPoint:
+		return pt, len(g) > 0, nil
+	case orb.LineString, orb.MultiLineString:
+		return pt, planar.Length(g) > 0, nil
+	default:
+		return orb.Point{}, false, nil
+	}
+}
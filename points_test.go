@@ -0,0 +1,213 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestPopulationClass(t *testing.T) {
+
+	tr := &PointsTransformer{
+		Buckets: POINTS_DEFAULT_POPULATION_BUCKETS,
+	}
+
+	tests := map[float64]int{
+		0:         0,
+		999:       0,
+		1000:      1,
+		9999:      1,
+		50000:     3,
+		1000000:   7,
+		10000000:  9,
+		100000000: 9,
+	}
+
+	for population, expected := range tests {
+
+		class := tr.PopulationClass(population)
+
+		if class != expected {
+			t.Fatalf("Expected population class %d for %v, got %d", expected, population, class)
+		}
+	}
+}
+
+func TestNewPointsTransformerBuckets(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]bool{
+		"points://?buckets=10,5,100":  true,
+		"points://?buckets=0,5":       true,
+		"points://?buckets=-1,5":      false,
+		"points://?buckets=5,10,5":    false,
+		"points://?buckets=5,fifteen": false,
+	}
+
+	for uri, expected := range tests {
+
+		_, err := NewPointsTransformer(ctx, uri)
+
+		if expected && err != nil {
+			t.Fatalf("Expected '%s' to be valid, %v", uri, err)
+		}
+
+		if !expected && err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+
+	tr, err := NewPointsTransformer(ctx, "points://?buckets=10,5,100")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	if class := tr.(*PointsTransformer).PopulationClass(50); class != 2 {
+		t.Fatalf("Expected sorted thresholds to yield population class 2, got %d", class)
+	}
+}
+
+func TestPointsSplitParts(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		types []string
+	}{
+		{"all parts", nil},
+		// The Point part (0) is excluded before it reaches the points stage
+		{"filtered first part", []string{"Polygon"}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			opts := &IterwriterCallbackFuncBuilderOptions{
+				ExplodeGeometryCollections: true,
+				GeometryTypes:              test.types,
+				PointPlacetypes:            []string{"venue"},
+			}
+
+			p := DefaultPipeline(opts)
+
+			f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(testCollectionFeature)))
+
+			if err != nil {
+				t.Fatalf("Failed to create feature, %v", err)
+			}
+
+			converted, err := p.ProcessAll(ctx, f)
+
+			if err != nil {
+				t.Fatalf("Failed to process feature, %v", err)
+			}
+
+			if len(converted) != 1 {
+				t.Fatalf("Expected a single point, got %d features", len(converted))
+			}
+
+			body, err := converted[0].Body()
+
+			if err != nil {
+				t.Fatalf("Failed to read feature, %v", err)
+			}
+
+			if geom_type := gjson.GetBytes(body, "geometry.type").String(); geom_type != "Point" {
+				t.Fatalf("Expected Point geometry, got '%s'", geom_type)
+			}
+		})
+	}
+}
+
+func TestPointsWithoutCentroid(t *testing.T) {
+
+	ctx := context.Background()
+
+	points := &PointsTransformer{
+		MaxPopulation: POINTS_DEFAULT_MAX_POPULATION,
+		Buckets:       POINTS_DEFAULT_POPULATION_BUCKETS,
+	}
+
+	// A collection of points has no area so planar.CentroidArea returns a zero point for it
+
+	body := `{"type":"Feature","properties":{"wof:id":1},"geometry":{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[10,10]},{"type":"Point","coordinates":[20,20]}]}}`
+
+	f, err := NewFeature("1.geojson", bytes.NewReader([]byte(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	err = points.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	out, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to read feature, %v", err)
+	}
+
+	if geom_type := gjson.GetBytes(out, "geometry.type").String(); geom_type != "GeometryCollection" {
+		t.Fatalf("Expected geometry to be left unchanged, got '%s'", geom_type)
+	}
+}
+
+func TestPointsPropertyOrder(t *testing.T) {
+
+	ctx := context.Background()
+
+	tr, err := NewPointsTransformer(ctx, "points://")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	points := tr.(*PointsTransformer)
+
+	body := `{"type":"Feature","properties":{"wof:id":1,"wof:population":50000},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	expected := "wof:id,wof:population,population,population_norm,population_class"
+
+	var previous []byte
+
+	for i := 0; i < 20; i++ {
+
+		f, err := NewFeature("1.geojson", bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		converted, err := points.Split(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to convert feature, %v", err)
+		}
+
+		out, err := converted[0].Body()
+
+		if err != nil {
+			t.Fatalf("Failed to derive body, %v", err)
+		}
+
+		if names := strings.Join(testPropertyNames(out), ","); names != expected {
+			t.Fatalf("Expected properties %s, got %s", expected, names)
+		}
+
+		if previous != nil && !bytes.Equal(out, previous) {
+			t.Fatalf("Expected identical output for every run")
+		}
+
+		previous = out
+	}
+}
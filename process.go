@@ -0,0 +1,589 @@
+package tippecanoe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The default tippecanoe binary used by `StartProcess`.
+const TIPPECANOE_BINARY string = "tippecanoe"
+
+// The maximum length of a line written to tippecanoe's STDERR. Longer lines are split into chunks of this length.
+const PROCESS_MAX_STDERR_LINE int = 1024 * 1024
+
+var re_progress = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)%\s+(\d+)/(\d+)/(\d+)\s*$`)
+
+var re_read = regexp.MustCompile(`^Read\s+(\d+(?:\.\d+)?)\s+million features`)
+
+var re_maxzoom = regexp.MustCompile(`^Choosing a maxzoom of -z(\d+)`)
+
+var re_tile_size = regexp.MustCompile(`^tile (\d+)/(\d+)/(\d+) size is (\d+)`)
+
+var re_warning = regexp.MustCompile(`(?i)\b(error|warning|can't|cannot|failed|unknown|invalid)\b`)
+
+// ProcessOptions is a struct defining options for running tippecanoe as a subprocess.
+type ProcessOptions struct {
+	// Path is the path to the tippecanoe binary. If empty `TIPPECANOE_BINARY` is used.
+	Path string
+	// Args are additional arguments to pass to tippecanoe (for example "-o", "wof.pmtiles", "-zg").
+	Args []string
+	// Layers is an optional list of layer names. If present features are written to a named pipe for each
+	// layer, passed to tippecanoe using "-L {LAYER}:{PIPE}", rather than to tippecanoe's STDIN.
+	Layers []string
+	// Stdout is an optional `io.Writer` where tippecanoe's STDOUT will be written.
+	Stdout io.Writer
+	// Logger is an optional `slog.Logger` instance used to log tippecanoe's STDERR. If nil `slog.Default` is used.
+	Logger *slog.Logger
+}
+
+// ProcessError is an error returned when tippecanoe exits with a non-zero status.
+type ProcessError struct {
+	// ExitCode is the exit code of the tippecanoe process.
+	ExitCode int
+	// Message is the last (non-progress) message tippecanoe wrote to STDERR.
+	Message string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns a string representation of 'e'.
+func (e *ProcessError) Error() string {
+
+	if e.Message != "" {
+		return fmt.Sprintf("tippecanoe exited with status %d: %s", e.ExitCode, e.Message)
+	}
+
+	return fmt.Sprintf("tippecanoe exited with status %d", e.ExitCode)
+}
+
+// Unwrap returns the underlying error for 'e'.
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// Process is a struct wrapping a tippecanoe subprocess that features are streamed to.
+type Process struct {
+	ctx          context.Context
+	cmd          *exec.Cmd
+	logger       *slog.Logger
+	stdin        io.WriteCloser
+	layers       []string
+	layer_index  map[string]int
+	pipes        []string
+	pipe_fh      *os.File
+	spools       map[int]*os.File
+	tmpdir       string
+	done         chan struct{}
+	err          error
+	last_message string
+	closed       bool
+	mu           *sync.Mutex
+}
+
+// StartProcess starts a new tippecanoe subprocess configured by 'opts'. The process is killed if 'ctx' is cancelled.
+//
+// When `opts.Layers` is empty features are written to tippecanoe's STDIN. Otherwise a named pipe is created for each
+// layer. Since tippecanoe reads its inputs one at a time features for the first layer are streamed directly while
+// features for all the other layers are spooled to temporary files and copied to their pipes, in order, when the
+// process is closed. Named pipes are only supported on Unix-like platforms.
+func StartProcess(ctx context.Context, opts *ProcessOptions) (*Process, error) {
+
+	path := opts.Path
+
+	if path == "" {
+		path = TIPPECANOE_BINARY
+	}
+
+	logger := opts.Logger
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	p := &Process{
+		ctx:         ctx,
+		logger:      logger.With("process", filepath.Base(path)),
+		layers:      opts.Layers,
+		layer_index: make(map[string]int),
+		spools:      make(map[int]*os.File),
+		done:        make(chan struct{}),
+		mu:          new(sync.Mutex),
+	}
+
+	args := make([]string, 0)
+
+	if len(opts.Layers) > 0 {
+
+		tmpdir, err := os.MkdirTemp("", "tippecanoe")
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create temporary directory, %w", err)
+		}
+
+		p.tmpdir = tmpdir
+		p.pipes = make([]string, len(opts.Layers))
+
+		for idx, l := range opts.Layers {
+
+			_, exists := p.layer_index[l]
+
+			if exists {
+				p.cleanup()
+				return nil, fmt.Errorf("Duplicate layer '%s'", l)
+			}
+
+			pipe_path := filepath.Join(tmpdir, fmt.Sprintf("layer-%03d.geojsonl", idx))
+
+			err := mkfifo(pipe_path)
+
+			if err != nil {
+				p.cleanup()
+				return nil, fmt.Errorf("Failed to create named pipe for layer '%s', %w", l, err)
+			}
+
+			p.layer_index[l] = idx
+			p.pipes[idx] = pipe_path
+
+			args = append(args, "-L", fmt.Sprintf("%s:%s", l, pipe_path))
+		}
+	}
+
+	args = append(args, opts.Args...)
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = opts.Stdout
+	cmd.WaitDelay = 10 * time.Second
+
+	if len(opts.Layers) == 0 {
+
+		stdin, err := cmd.StdinPipe()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create STDIN pipe, %w", err)
+		}
+
+		p.stdin = stdin
+	}
+
+	// Use an io.Pipe rather than cmd.StderrPipe so that cmd.WaitDelay applies to reading STDERR if
+	// the process is killed but its own children keep the pipe open.
+
+	stderr_r, stderr_w := io.Pipe()
+	cmd.Stderr = stderr_w
+
+	p.logger.Debug("Start process", "args", args)
+
+	err := cmd.Start()
+
+	if err != nil {
+		p.cleanup()
+		return nil, fmt.Errorf("Failed to start %s, %w", path, err)
+	}
+
+	p.cmd = cmd
+
+	stderr_done := make(chan struct{})
+
+	go func() {
+		p.readStderr(stderr_r)
+		close(stderr_done)
+	}()
+
+	go func() {
+
+		// Note: 'err' and 'last_message' are only read after 'done' is closed
+
+		p.err = cmd.Wait()
+
+		stderr_w.Close()
+		<-stderr_done
+
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// Done returns a channel that is closed when the tippecanoe process exits.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Write writes 'body', which is expected to be a single line of GeoJSON, to the input for 'layer'. If the process
+// was not started with layers then 'layer' is ignored and 'body' is written to tippecanoe's STDIN.
+func (p *Process) Write(layer string, body []byte) error {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return fmt.Errorf("Process has been closed")
+	}
+
+	var wr io.Writer
+
+	if p.stdin != nil {
+		wr = p.stdin
+	} else {
+
+		idx, exists := p.layer_index[layer]
+
+		if !exists {
+			return fmt.Errorf("Unknown layer '%s'", layer)
+		}
+
+		w, err := p.layerWriter(idx)
+
+		if err != nil {
+			return err
+		}
+
+		wr = w
+	}
+
+	_, err := wr.Write(body)
+
+	if err != nil {
+		return p.exitError(fmt.Errorf("Failed to write to tippecanoe, %w", err))
+	}
+
+	return nil
+}
+
+// Close signals that there are no more features to write. For layers, any spooled features are copied to their
+// named pipes before returning.
+func (p *Process) Close() error {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	p.closed = true
+
+	if p.stdin != nil {
+
+		err := p.stdin.Close()
+
+		if err != nil && !errors.Is(err, os.ErrClosed) {
+			return p.exitError(fmt.Errorf("Failed to close STDIN, %w", err))
+		}
+
+		return nil
+	}
+
+	for idx := range p.layers {
+
+		var fh *os.File
+
+		if idx == 0 && p.pipe_fh != nil {
+			fh = p.pipe_fh
+		} else {
+
+			pipe_fh, err := p.openPipe(p.pipes[idx])
+
+			if err != nil {
+				return p.exitError(err)
+			}
+
+			fh = pipe_fh
+		}
+
+		spool_fh, ok := p.spools[idx]
+
+		if ok {
+
+			_, err := spool_fh.Seek(0, 0)
+
+			if err == nil {
+				_, err = io.Copy(fh, spool_fh)
+			}
+
+			spool_fh.Close()
+
+			if err != nil {
+				fh.Close()
+				return p.exitError(fmt.Errorf("Failed to copy features for layer '%s', %w", p.layers[idx], err))
+			}
+		}
+
+		err := fh.Close()
+
+		if err != nil {
+			return p.exitError(fmt.Errorf("Failed to close pipe for layer '%s', %w", p.layers[idx], err))
+		}
+	}
+
+	return nil
+}
+
+// Wait closes 'p', waits for the tippecanoe process to exit and removes any temporary files. If tippecanoe exits
+// with a non-zero status a `ProcessError` is returned.
+func (p *Process) Wait() error {
+
+	close_err := p.Close()
+
+	<-p.done
+
+	p.cleanup()
+
+	err := p.err
+
+	if err != nil && p.ctx.Err() != nil {
+		return fmt.Errorf("tippecanoe was cancelled, %w", p.ctx.Err())
+	}
+
+	if err != nil {
+
+		var exit_err *exec.ExitError
+
+		if errors.As(err, &exit_err) {
+			return &ProcessError{
+				ExitCode: exit_err.ExitCode(),
+				Message:  p.last_message,
+				Err:      err,
+			}
+		}
+
+		return fmt.Errorf("Failed to wait for tippecanoe, %w", err)
+	}
+
+	return close_err
+}
+
+// layerWriter returns the `io.Writer` for the layer at 'idx': the named pipe for the first layer and a spool file
+// for all the others.
+func (p *Process) layerWriter(idx int) (io.Writer, error) {
+
+	if idx == 0 {
+
+		if p.pipe_fh == nil {
+
+			fh, err := p.openPipe(p.pipes[0])
+
+			if err != nil {
+				return nil, p.exitError(err)
+			}
+
+			p.pipe_fh = fh
+		}
+
+		return p.pipe_fh, nil
+	}
+
+	spool_fh, ok := p.spools[idx]
+
+	if !ok {
+
+		fh, err := os.CreateTemp(p.tmpdir, "spool")
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create spool file for layer '%s', %w", p.layers[idx], err)
+		}
+
+		p.spools[idx] = fh
+		spool_fh = fh
+	}
+
+	return spool_fh, nil
+}
+
+// openPipe opens the named pipe at 'path' for writing. Opening a named pipe blocks until there is a reader so
+// this will return an error if the process exits first.
+func (p *Process) openPipe(path string) (*os.File, error) {
+
+	select {
+	case <-p.done:
+		return nil, fmt.Errorf("tippecanoe exited before reading %s", path)
+	default:
+	}
+
+	type result struct {
+		fh  *os.File
+		err error
+	}
+
+	ch := make(chan result, 1)
+
+	go func() {
+		fh, err := os.OpenFile(path, os.O_WRONLY, 0)
+		ch <- result{fh, err}
+	}()
+
+	select {
+	case r := <-ch:
+
+		if r.err != nil {
+			return nil, fmt.Errorf("Failed to open %s, %w", path, r.err)
+		}
+
+		return r.fh, nil
+
+	case <-p.done:
+
+		// Unblock the pending open. This is repeated since the open may not have started when the
+		// pipe is first opened (and closed) for reading, in which case it would block again.
+
+		for {
+
+			unblockFifo(path)
+
+			select {
+			case r := <-ch:
+
+				if r.fh != nil {
+					r.fh.Close()
+				}
+
+				return nil, fmt.Errorf("tippecanoe exited before reading %s", path)
+
+			case <-time.After(10 * time.Millisecond):
+				// try again
+			}
+		}
+	}
+}
+
+// exitError returns a `ProcessError` if the process has exited with a non-zero status, otherwise 'err'. This is
+// used to report why writes fail (for example a broken pipe) when tippecanoe exits prematurely.
+func (p *Process) exitError(err error) error {
+
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		return err
+	}
+
+	var exit_err *exec.ExitError
+
+	if errors.As(p.err, &exit_err) {
+		return &ProcessError{
+			ExitCode: exit_err.ExitCode(),
+			Message:  p.last_message,
+			Err:      err,
+		}
+	}
+
+	return err
+}
+
+func (p *Process) cleanup() {
+
+	for _, fh := range p.spools {
+		fh.Close()
+	}
+
+	if p.tmpdir != "" {
+		os.RemoveAll(p.tmpdir)
+	}
+}
+
+// readStderr reads 'r' until EOF logging each line (or carriage-return delimited progress update). If reading
+// fails the remainder of 'r' is discarded so that tippecanoe is never blocked writing to STDERR.
+func (p *Process) readStderr(r io.Reader) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), PROCESS_MAX_STDERR_LINE)
+	scanner.Split(scanLinesOrReturns)
+
+	defer func() {
+
+		err := scanner.Err()
+
+		if err != nil {
+			p.logger.Warn("Failed to read tippecanoe STDERR, discarding remaining output", "error", err)
+			io.Copy(io.Discard, r)
+		}
+	}()
+
+	last_pct := -1
+
+	for scanner.Scan() {
+
+		msg := strings.TrimSpace(scanner.Text())
+
+		if msg == "" {
+			continue
+		}
+
+		if m := re_progress.FindStringSubmatch(msg); m != nil {
+
+			pct, _ := strconv.ParseFloat(m[1], 64)
+
+			// Progress is reported very frequently so only log every whole percent
+
+			if int(pct) == last_pct {
+				continue
+			}
+
+			last_pct = int(pct)
+
+			p.logger.Debug("Progress", "percent", pct, "tile", fmt.Sprintf("%s/%s/%s", m[2], m[3], m[4]))
+			continue
+		}
+
+		if m := re_read.FindStringSubmatch(msg); m != nil {
+			millions, _ := strconv.ParseFloat(m[1], 64)
+			p.logger.Debug("Read features", "count", int64(millions*1000000))
+			continue
+		}
+
+		p.last_message = msg
+
+		if m := re_maxzoom.FindStringSubmatch(msg); m != nil {
+			z, _ := strconv.Atoi(m[1])
+			p.logger.Info(msg, "maxzoom", z)
+			continue
+		}
+
+		if m := re_tile_size.FindStringSubmatch(msg); m != nil {
+			size, _ := strconv.ParseInt(m[4], 10, 64)
+			p.logger.Warn(msg, "tile", fmt.Sprintf("%s/%s/%s", m[1], m[2], m[3]), "size", size)
+			continue
+		}
+
+		if re_warning.MatchString(msg) {
+			p.logger.Warn(msg)
+			continue
+		}
+
+		p.logger.Info(msg)
+	}
+}
+
+// scanLinesOrReturns is a `bufio.SplitFunc` that splits on either "\n" or "\r" since tippecanoe uses
+// carriage returns to update progress in place. Lines longer than `PROCESS_MAX_STDERR_LINE` are split
+// into chunks rather than triggering a `bufio.ErrTooLong` error.
+func scanLinesOrReturns(data []byte, at_eof bool) (int, []byte, error) {
+
+	if at_eof && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	idx := bytes.IndexAny(data, "\r\n")
+
+	if idx >= 0 {
+		return idx + 1, data[0:idx], nil
+	}
+
+	if at_eof || len(data) >= PROCESS_MAX_STDERR_LINE {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
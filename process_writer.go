@@ -0,0 +1,156 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// processContextKey is the type of the key used to store a `Process` instance in a `context.Context` instance.
+type processContextKey struct{}
+
+func init() {
+
+	ctx := context.Background()
+
+	err := writer.RegisterWriter(ctx, "tippecanoe", NewProcessWriter)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ProcessWriter implements the `whosonfirst/go-writer/v3.Writer` interface writing each document, as a single
+// line of GeoJSON, to a tippecanoe `Process`.
+type ProcessWriter struct {
+	writer.Writer
+	process        *Process
+	layer_property string
+}
+
+// ContextWithProcess returns a new `context.Context` instance containing 'p' for use by `NewProcessWriter`.
+func ContextWithProcess(ctx context.Context, p *Process) context.Context {
+	return context.WithValue(ctx, processContextKey{}, p)
+}
+
+// ProcessFromContext returns the `Process` instance stored in 'ctx' by `ContextWithProcess`.
+func ProcessFromContext(ctx context.Context) (*Process, error) {
+
+	v := ctx.Value(processContextKey{})
+
+	if v == nil {
+		return nil, fmt.Errorf("Context is missing tippecanoe process")
+	}
+
+	p, ok := v.(*Process)
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid tippecanoe process in context")
+	}
+
+	return p, nil
+}
+
+// NewProcessWriter returns a new `ProcessWriter` instance for the `Process` stored in 'ctx' (using the
+// `ContextWithProcess` method) configured by 'uri' in the form of:
+//
+//	tippecanoe://?layer-property={PROPERTY}
+//
+// Where {PROPERTY} is the (optional) path of the property whose value is the name of the layer a feature
+// is written to. It is required if the process was started with layers.
+func NewProcessWriter(ctx context.Context, uri string) (writer.Writer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	p, err := ProcessFromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	layer_property := q.Get("layer-property")
+
+	if layer_property == "" && len(p.layers) > 0 {
+		return nil, fmt.Errorf("Missing ?layer-property= parameter")
+	}
+
+	if layer_property != "" && !strings.HasPrefix(layer_property, "properties.") {
+		layer_property = fmt.Sprintf("properties.%s", layer_property)
+	}
+
+	wr := &ProcessWriter{
+		process:        p,
+		layer_property: layer_property,
+	}
+
+	return wr, nil
+}
+
+// Write writes the compacted contents of 'r' to the tippecanoe process.
+func (wr *ProcessWriter) Write(ctx context.Context, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	var buf bytes.Buffer
+
+	err = json.Compact(&buf, body)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to compact %s, %w", key, err)
+	}
+
+	buf.WriteString("\n")
+
+	layer := ""
+
+	if wr.layer_property != "" {
+		layer = gjson.GetBytes(body, wr.layer_property).String()
+	}
+
+	err = wr.process.Write(layer, buf.Bytes())
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to write %s, %w", key, err)
+	}
+
+	return int64(buf.Len()), nil
+}
+
+// WriterURI returns 'key'.
+func (wr *ProcessWriter) WriterURI(ctx context.Context, key string) string {
+	return key
+}
+
+// Flush is a no-op to conform to the `whosonfirst/go-writer/v3.Writer` interface.
+func (wr *ProcessWriter) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the tippecanoe process' inputs signaling that there are no more features to write. Note that this
+// applies to all the `ProcessWriter` instances for the same process.
+func (wr *ProcessWriter) Close(ctx context.Context) error {
+	return wr.process.Close()
+}
+
+// SetLogger is a no-op to conform to the `whosonfirst/go-writer/v3.Writer` interface.
+func (wr *ProcessWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-iterwriter/v4"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// Profile is a struct defining a named set of options, and a writer, used to produce one of several outputs
+// from a single pass over the records emitted by an iterator.
+type Profile struct {
+	// Name is the name of the profile.
+	Name string
+	// Options are the `IterwriterCallbackFuncBuilderOptions` used to produce output.
+	Options *IterwriterCallbackFuncBuilderOptions
+	// Pipeline is the `Pipeline` used to process each record.
+	Pipeline *Pipeline
+	// Writer is the `whosonfirst/go-writer/v3.Writer` instance that processed records are written to.
+	Writer writer.Writer
+}
+
+// NewProfile returns a new `Profile` instance whose pipeline is the `Pipeline` returned by `DefaultPipeline` for 'opts'.
+func NewProfile(name string, opts *IterwriterCallbackFuncBuilderOptions, wr writer.Writer) *Profile {
+
+	p := &Profile{
+		Name:     name,
+		Options:  opts,
+		Pipeline: DefaultPipeline(opts),
+		Writer:   wr,
+	}
+
+	return p
+}
+
+// ProfilesCallbackFuncBuilder returns a `iterwriter.IterwriterCallback` function which reads each record once and then
+// processes, and writes, it for each of 'profiles' in turn. The writer passed to the callback function is ignored since
+// each profile has its own writer. Errors are handled according to the `Forgiving` option of each profile, the same way
+// they are by `IterwriterCallbackFuncBuilderWithPipeline`.
+func ProfilesCallbackFuncBuilder(profiles ...*Profile) iterwriter.IterwriterCallback {
+
+	fn := func(ctx context.Context, rec *iterate.Record, wr writer.Writer) error {
+
+		body, err := io.ReadAll(rec.Body)
+
+		if err != nil {
+
+			slog.Error("Failed to read record", "path", rec.Path, "error", err)
+
+			// Only skip the record if every profile is forgiving since it won't be processed by any of them
+
+			if isForgiving(profiles...) {
+				return nil
+			}
+
+			return fmt.Errorf("Failed to read %s, %w", rec.Path, err)
+		}
+
+		for _, p := range profiles {
+
+			f, err := NewFeature(rec.Path, bytes.NewReader(body))
+
+			if err != nil {
+
+				slog.Error("Failed to create feature", "path", rec.Path, "profile", p.Name, "error", err)
+
+				if p.Options.Forgiving {
+					continue
+				}
+
+				return fmt.Errorf("Failed to create feature for %s for profile '%s', %w", rec.Path, p.Name, err)
+			}
+
+			err = processAndWriteFeature(ctx, p.Pipeline, p.Options, f, p.Writer)
+
+			if err != nil {
+				return fmt.Errorf("Failed to process %s for profile '%s', %w", rec.Path, p.Name, err)
+			}
+		}
+
+		return nil
+	}
+
+	return fn
+}
+
+// isForgiving returns true if the `Forgiving` option is enabled for all of 'profiles'.
+func isForgiving(profiles ...*Profile) bool {
+
+	for _, p := range profiles {
+
+		if !p.Options.Forgiving {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+)
+
+type nopReadSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (r *nopReadSeekCloser) Close() error {
+	return nil
+}
+
+func TestProfilesCallbackFuncBuilderForgiving(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, forgiving := range []bool{true, false} {
+
+		opts := &IterwriterCallbackFuncBuilderOptions{
+			Forgiving: forgiving,
+		}
+
+		p := NewProfile("test", opts, newCaptureWriter())
+		cb := ProfilesCallbackFuncBuilder(p)
+
+		// Not a Who's On First path so a feature can not be created
+
+		rec := iterate.NewRecord("README.md", &nopReadSeekCloser{bytes.NewReader([]byte(`{}`))})
+
+		err := cb(ctx, rec, nil)
+
+		if forgiving && err != nil {
+			t.Fatalf("Expected forgiving profile to skip record, %v", err)
+		}
+
+		if !forgiving && err == nil {
+			t.Fatalf("Expected profile to fail")
+		}
+	}
+}
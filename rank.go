@@ -0,0 +1,314 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// The default name of the property that feature ranks are assigned to.
+const RANK_DEFAULT_PROPERTY string = "sort_rank"
+
+// The default weight applied to the (log10) population of a feature.
+const RANK_DEFAULT_POPULATION_WEIGHT float64 = 5.0
+
+// The default weight applied to the (log10) area, in square kilometres, of a feature.
+const RANK_DEFAULT_AREA_WEIGHT float64 = 2.0
+
+// The default weight applied to features whose "mz:is_current" property is 1.
+const RANK_DEFAULT_CURRENT_WEIGHT float64 = 10.0
+
+// The default number of points of rank that correspond to one zoom level when deriving "tippecanoe.minzoom" values.
+const RANK_DEFAULT_SCORE_PER_ZOOM float64 = 10.0
+
+// The default maximum zoom level assigned to "tippecanoe.minzoom" values.
+const RANK_DEFAULT_MAX_ZOOM int = 14
+
+// RANK_DEFAULT_PLACETYPE_WEIGHTS is the default weight assigned to each placetype. Placetypes not listed
+// have a weight of zero.
+var RANK_DEFAULT_PLACETYPE_WEIGHTS = map[string]float64{
+	"continent":     100,
+	"ocean":         100,
+	"empire":        95,
+	"country":       90,
+	"dependency":    85,
+	"disputed":      80,
+	"marinearea":    75,
+	"macroregion":   75,
+	"region":        70,
+	"macrocounty":   65,
+	"county":        60,
+	"localadmin":    55,
+	"locality":      50,
+	"borough":       45,
+	"macrohood":     40,
+	"neighbourhood": 35,
+	"microhood":     30,
+	"campus":        25,
+	"building":      15,
+	"venue":         10,
+}
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "rank", NewRankTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RankTransformer implements the `Transformer` interface assigning a numeric rank, suitable for use with the
+// tippecanoe `--order-by` and `--drop-*` flags, to each feature. The rank is computed as:
+//
+//	placetype weight + (population weight * log10(population + 1)) + (area weight * log10(area in km² + 1)) + current weight
+//
+// Where population is the "wof:population" property (or "gn:population" if absent), area is the geodesic area
+// of the feature's original geometry and the current weight is only added if the "mz:is_current" property is 1. All
+// properties are read from the original body of the feature so ranks can be computed for SPR output.
+type RankTransformer struct {
+	Transformer
+	// Property is the name of the property that ranks are assigned to.
+	Property string
+	// PlacetypeWeights is the weight assigned to each placetype. Placetypes not listed have a weight of zero.
+	PlacetypeWeights map[string]float64
+	// PopulationWeight is the weight applied to the (log10) population of a feature.
+	PopulationWeight float64
+	// AreaWeight is the weight applied to the (log10) area, in square kilometres, of a feature.
+	AreaWeight float64
+	// CurrentWeight is the weight applied to features whose "mz:is_current" property is 1.
+	CurrentWeight float64
+	// MinZoom is a boolean flag signaling that a "tippecanoe.minzoom" value should also be derived from the rank.
+	MinZoom bool
+	// ScorePerZoom is the number of points of rank that correspond to one zoom level when deriving "tippecanoe.minzoom" values.
+	ScorePerZoom float64
+	// MaxZoom is the largest "tippecanoe.minzoom" value that will be assigned.
+	MaxZoom int
+}
+
+// NewDefaultRankTransformer returns a new `RankTransformer` instance using the default weights.
+func NewDefaultRankTransformer() *RankTransformer {
+
+	weights := make(map[string]float64)
+
+	for k, v := range RANK_DEFAULT_PLACETYPE_WEIGHTS {
+		weights[k] = v
+	}
+
+	t := &RankTransformer{
+		Property:         RANK_DEFAULT_PROPERTY,
+		PlacetypeWeights: weights,
+		PopulationWeight: RANK_DEFAULT_POPULATION_WEIGHT,
+		AreaWeight:       RANK_DEFAULT_AREA_WEIGHT,
+		CurrentWeight:    RANK_DEFAULT_CURRENT_WEIGHT,
+		ScorePerZoom:     RANK_DEFAULT_SCORE_PER_ZOOM,
+		MaxZoom:          RANK_DEFAULT_MAX_ZOOM,
+	}
+
+	return t
+}
+
+// NewRankTransformer returns a new `RankTransformer` instance configured by 'uri' in the form of:
+//
+//	rank://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?property=` The name of the property that ranks are assigned to. Default is "sort_rank".
+// * `?weight=` Zero or more {PLACETYPE}:{WEIGHT} pairs overriding the default placetype weights.
+// * `?population-weight=` The weight applied to the (log10) population of a feature. Default is 5.
+// * `?area-weight=` The weight applied to the (log10) area, in square kilometres, of a feature. Default is 2.
+// * `?current-weight=` The weight applied to features whose "mz:is_current" property is 1. Default is 10.
+// * `?minzoom=` A boolean value indicating whether to also assign a "tippecanoe.minzoom" value. Default is false.
+// * `?score-per-zoom=` The number of points of rank that correspond to one zoom level. Default is 10.
+// * `?max-zoom=` The largest "tippecanoe.minzoom" value that will be assigned. Default is 14.
+func NewRankTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	t := NewDefaultRankTransformer()
+
+	q := u.Query()
+
+	if q.Has("property") {
+		t.Property = q.Get("property")
+	}
+
+	for _, w := range q["weight"] {
+
+		pt, str_weight, ok := strings.Cut(w, ":")
+
+		if !ok {
+			return nil, fmt.Errorf("Invalid '?weight=' parameter, expected {PLACETYPE}:{WEIGHT}")
+		}
+
+		if !IsValidPlacetype(pt) {
+			return nil, fmt.Errorf("Invalid '?weight=' parameter, unknown placetype '%s'", pt)
+		}
+
+		v, err := strconv.ParseFloat(str_weight, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?weight=' parameter for %s, %w", pt, err)
+		}
+
+		t.PlacetypeWeights[pt] = v
+	}
+
+	floats := map[string]*float64{
+		"population-weight": &t.PopulationWeight,
+		"area-weight":       &t.AreaWeight,
+		"current-weight":    &t.CurrentWeight,
+		"score-per-zoom":    &t.ScorePerZoom,
+	}
+
+	for k, ptr := range floats {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(q.Get(k), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?%s=' parameter, %w", k, err)
+		}
+
+		*ptr = v
+	}
+
+	if t.ScorePerZoom <= 0 {
+		return nil, fmt.Errorf("Invalid '?score-per-zoom=' parameter, must be greater than zero")
+	}
+
+	if q.Has("minzoom") {
+
+		v, err := strconv.ParseBool(q.Get("minzoom"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?minzoom=' parameter, %w", err)
+		}
+
+		t.MinZoom = v
+	}
+
+	if q.Has("max-zoom") {
+
+		v, err := strconv.Atoi(q.Get("max-zoom"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?max-zoom=' parameter, %w", err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid '?max-zoom=' parameter, must be zero or greater")
+		}
+
+		t.MaxZoom = v
+	}
+
+	return t, nil
+}
+
+// Transform assigns a rank (and optionally a "tippecanoe.minzoom" value) to 'f'.
+func (t *RankTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	original, err := f.Original()
+
+	if err != nil {
+		return err
+	}
+
+	props := gjson.GetBytes(original, "properties")
+
+	area := 0.0
+
+	// Use the original geometry so that ranks are not affected by other transformations
+	// (for example simplification or conversion to points)
+
+	geom_rsp := gjson.GetBytes(original, "geometry")
+
+	if geom_rsp.Exists() {
+
+		geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+		if err == nil {
+			area = geo.Area(geom.Geometry()) / 1000000.0
+		}
+	}
+
+	population := props.Get("wof:population").Float()
+
+	if population <= 0 {
+		population = props.Get("gn:population").Float()
+	}
+
+	rank := t.Rank(props.Get("wof:placetype").String(), population, area, props.Get("mz:is_current").Int() == 1)
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("properties.%s", t.Property)
+
+	body, err = sjson.SetBytes(body, path, rank)
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign %s, %w", path, err)
+	}
+
+	if t.MinZoom {
+
+		body, err = sjson.SetBytes(body, "tippecanoe.minzoom", t.MinZoomForRank(rank))
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign tippecanoe.minzoom, %w", err)
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+// Rank returns the rank, rounded to two decimal places, for a feature with 'placetype', 'population', 'area' (in
+// square kilometres) and whether or not it is current.
+func (t *RankTransformer) Rank(placetype string, population float64, area float64, is_current bool) float64 {
+
+	rank := t.PlacetypeWeights[placetype]
+
+	if population > 0 {
+		rank += t.PopulationWeight * math.Log10(population+1)
+	}
+
+	if area > 0 {
+		rank += t.AreaWeight * math.Log10(area+1)
+	}
+
+	if is_current {
+		rank += t.CurrentWeight
+	}
+
+	return math.Round(rank*100) / 100
+}
+
+// MinZoomForRank returns the "tippecanoe.minzoom" value for 'rank' which is 'MaxZoom' less one zoom level for
+// every 'ScorePerZoom' points of rank, and never less than zero.
+func (t *RankTransformer) MinZoomForRank(rank float64) int {
+	z := t.MaxZoom - int(math.Round(rank/t.ScorePerZoom))
+	return min(max(z, 0), t.MaxZoom)
+}
@@ -0,0 +1,161 @@
+package tippecanoe
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestRank(t *testing.T) {
+
+	tr := NewDefaultRankTransformer()
+
+	tests := []struct {
+		placetype  string
+		population float64
+		area       float64
+		is_current bool
+		expected   float64
+	}{
+		{"country", 0, 0, false, 90},
+		{"locality", 999999, 0, false, 80},
+		{"locality", 999999, 0, true, 90},
+		{"region", 0, 99, false, 74},
+		{"venue", -10, -10, false, 10},
+		{"planet", 0, 9, true, 12},
+		// log10(2) * 5 = 1.50514... rounded to two decimal places
+		{"", 1, 0, false, 1.51},
+	}
+
+	for _, test := range tests {
+
+		rank := tr.Rank(test.placetype, test.population, test.area, test.is_current)
+
+		if rank != test.expected {
+			t.Fatalf("Expected rank %f for %+v, got %f", test.expected, test, rank)
+		}
+	}
+}
+
+func TestMinZoomForRank(t *testing.T) {
+
+	tr := NewDefaultRankTransformer()
+
+	tests := map[float64]int{
+		0:   14,
+		-30: 14,
+		44:  10,
+		45:  9,
+		90:  5,
+		140: 0,
+		500: 0,
+	}
+
+	for rank, expected := range tests {
+
+		z := tr.MinZoomForRank(rank)
+
+		if z != expected {
+			t.Fatalf("Expected minzoom %d for rank %f, got %d", expected, rank, z)
+		}
+	}
+}
+
+func TestNewRankTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	uri := "rank://?property=rank&weight=locality:60&weight=county:1.5&population-weight=1&area-weight=0&current-weight=0&minzoom=true&score-per-zoom=5&max-zoom=12"
+
+	v, err := NewRankTransformer(ctx, uri)
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer for %s, %v", uri, err)
+	}
+
+	tr := v.(*RankTransformer)
+
+	if tr.Property != "rank" || !tr.MinZoom || tr.ScorePerZoom != 5 || tr.MaxZoom != 12 {
+		t.Fatalf("Unexpected transformer for %s, %+v", uri, tr)
+	}
+
+	if tr.PopulationWeight != 1 || tr.AreaWeight != 0 || tr.CurrentWeight != 0 {
+		t.Fatalf("Unexpected weights for %s, %+v", uri, tr)
+	}
+
+	if tr.PlacetypeWeights["locality"] != 60 || tr.PlacetypeWeights["county"] != 1.5 || tr.PlacetypeWeights["country"] != RANK_DEFAULT_PLACETYPE_WEIGHTS["country"] {
+		t.Fatalf("Unexpected placetype weights for %s, %v", uri, tr.PlacetypeWeights)
+	}
+
+	// Overriding placetype weights must not modify the defaults
+
+	if RANK_DEFAULT_PLACETYPE_WEIGHTS["locality"] != 50 {
+		t.Fatalf("Expected default placetype weights to be unchanged")
+	}
+
+	invalid := []string{
+		"rank://?weight=locality",
+		"rank://?weight=city:10",
+		"rank://?weight=locality:ten",
+		"rank://?population-weight=five",
+		"rank://?score-per-zoom=0",
+		"rank://?score-per-zoom=-1",
+		"rank://?minzoom=maybe",
+		"rank://?max-zoom=-1",
+		"rank://?max-zoom=high",
+	}
+
+	for _, uri := range invalid {
+
+		_, err := NewRankTransformer(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+}
+
+func TestRankTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	tr, err := NewRankTransformer(ctx, "rank://?minzoom=true")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	body := `{"type":"Feature","properties":{"wof:id":1,"wof:placetype":"locality","gn:population":999999,"mz:is_current":1},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	f, err := NewFeature("1.geojson", strings.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	// Ranks are derived from the original body so replacing its properties (for example with SPR) doesn't change them
+
+	f.SetBody([]byte(`{"type":"Feature","properties":{"wof:id":1},"geometry":{"type":"Point","coordinates":[0,0]}}`))
+
+	err = tr.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	out, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to derive body, %v", err)
+	}
+
+	if rank := gjson.GetBytes(out, "properties.sort_rank").Float(); rank != 90 {
+		t.Fatalf("Expected rank 90, got %f", rank)
+	}
+
+	if z := gjson.GetBytes(out, "tippecanoe.minzoom").Int(); z != 5 {
+		t.Fatalf("Expected minzoom 5, got %d", z)
+	}
+}
@@ -0,0 +1,460 @@
+package tippecanoe
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// The default value used by `RoutingWriter` for template properties which are missing or empty.
+const ROUTE_DEFAULT_VALUE string = "unknown"
+
+// The default maximum number of files that a `RoutingWriter` will keep open at the same time.
+const ROUTE_DEFAULT_MAX_OPEN_FILES int = 64
+
+var re_route_placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+var re_route_unsafe = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+
+func init() {
+
+	ctx := context.Background()
+
+	err := writer.RegisterWriter(ctx, "route", NewRoutingWriter)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RoutingWriter implements the `whosonfirst/go-writer/v3.Writer` interface for writing each feature to one of
+// several destinations derived from the values of its properties. Destinations are opened when they are first
+// needed and are all closed when the writer is closed. Only a limited number of files are kept open at the same
+// time; when that limit is reached the least recently used file is closed and then reopened, in append mode, if
+// it is needed again. Writers (for URI templates) can not be safely reopened so writing to more distinct writers
+// than that limit is an error.
+type RoutingWriter struct {
+	writer.Writer
+	template      string
+	default_value string
+	is_uri        bool
+	max_open      int
+	files         map[string]*shard
+	open          *list.List
+	open_elements map[string]*list.Element
+	writers       map[string]writer.Writer
+	mu            *sync.Mutex
+}
+
+// NewRoutingWriter returns a new `RoutingWriter` instance configured by 'uri' in the form of:
+//
+//	route://?template={TEMPLATE}&default={DEFAULT}&max-open={MAX_OPEN}
+//
+// Where {TEMPLATE} is a path (or a whosonfirst/go-writer/v3 URI) containing one or more "{PROPERTY}" placeholders
+// which are replaced by the value of that property for each feature, for example "/tmp/layers/{wof:placetype}.jsonl".
+// If {TEMPLATE} is a path features are appended, as JSON-L, to that file. If it is a URI features are written to the
+// writer for that URI. Placeholders are read from the "properties" of each feature unless they start with "properties."
+// or "tippecanoe." (for example "{tippecanoe.layer}") in which case they are read from that path. Values are sanitized
+// so that only letters, numbers, "_", "." and "-" are included and values consisting only of dots are replaced by "_". {DEFAULT}
+// is the (optional) value used for properties that are missing or empty; the default is "unknown". {MAX_OPEN} is the
+// (optional) maximum number of files (or writers) to keep open at the same time; the default is 64. Templates containing
+// their own query parameters must be URL-encoded.
+func NewRoutingWriter(ctx context.Context, uri string) (writer.Writer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	template := q.Get("template")
+
+	if template == "" {
+		return nil, fmt.Errorf("Missing ?template= parameter")
+	}
+
+	if !re_route_placeholder.MatchString(template) {
+		return nil, fmt.Errorf("Template '%s' does not contain any {PROPERTY} placeholders", template)
+	}
+
+	default_value := ROUTE_DEFAULT_VALUE
+
+	if q.Has("default") {
+		default_value = q.Get("default")
+	}
+
+	max_open := ROUTE_DEFAULT_MAX_OPEN_FILES
+
+	if q.Has("max-open") {
+
+		v, err := strconv.Atoi(q.Get("max-open"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?max-open=' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid '?max-open=' parameter, must be greater than zero")
+		}
+
+		max_open = v
+	}
+
+	wr := &RoutingWriter{
+		template:      template,
+		default_value: default_value,
+		is_uri:        strings.Contains(template, "://"),
+		max_open:      max_open,
+		files:         make(map[string]*shard),
+		open:          list.New(),
+		open_elements: make(map[string]*list.Element),
+		writers:       make(map[string]writer.Writer),
+		mu:            new(sync.Mutex),
+	}
+
+	return wr, nil
+}
+
+// Write writes the contents of 'fh' to the destination derived from its properties.
+func (wr *RoutingWriter) Write(ctx context.Context, key string, fh io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(fh)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	target := wr.Target(body)
+
+	if wr.is_uri {
+
+		target_wr, err := wr.targetWriter(ctx, target)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return target_wr.Write(ctx, key, bytes.NewReader(body))
+	}
+
+	var buf bytes.Buffer
+
+	err = json.Compact(&buf, body)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to compact %s, %w", key, err)
+	}
+
+	buf.WriteString("\n")
+
+	// Files may be closed, and reopened, at any time to stay under the limit of open files so
+	// writes are made while holding the lock for 'wr'
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	s, err := wr.targetFile(target)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := s.buf.Write(buf.Bytes())
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to write %s to %s, %w", key, s.path, err)
+	}
+
+	s.features += 1
+	s.bytes += int64(n)
+
+	return int64(n), nil
+}
+
+// Target returns the destination for the feature 'body' derived from the template for 'wr'.
+func (wr *RoutingWriter) Target(body []byte) string {
+
+	return re_route_placeholder.ReplaceAllStringFunc(wr.template, func(m string) string {
+
+		prop := strings.Trim(m, "{}")
+
+		// Top-level "tippecanoe" properties (for example "tippecanoe.layer") are read as-is
+
+		if !strings.HasPrefix(prop, "properties.") && !strings.HasPrefix(prop, "tippecanoe.") {
+			prop = fmt.Sprintf("properties.%s", prop)
+		}
+
+		v := gjson.GetBytes(body, prop).String()
+
+		if v == "" {
+			v = wr.default_value
+		}
+
+		v = re_route_unsafe.ReplaceAllString(v, "_")
+
+		// Values consisting only of dots (for example "..") would refer to (parent) directories
+
+		if strings.Trim(v, ".") == "" {
+			v = "_"
+		}
+
+		return v
+	})
+}
+
+// Targets returns the sorted list of destinations that have been written to.
+func (wr *RoutingWriter) Targets() []string {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	targets := make([]string, 0, len(wr.files)+len(wr.writers))
+
+	for t := range wr.files {
+		targets = append(targets, t)
+	}
+
+	for t := range wr.writers {
+		targets = append(targets, t)
+	}
+
+	sort.Strings(targets)
+	return targets
+}
+
+// WriterURI returns the template for 'wr'.
+func (wr *RoutingWriter) WriterURI(ctx context.Context, key string) string {
+	return wr.template
+}
+
+// Flush flushes each of the destinations that have been opened. Every destination is flushed even if flushing
+// one of them fails.
+func (wr *RoutingWriter) Flush(ctx context.Context) error {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	return wr.flush(ctx)
+}
+
+// Close flushes and closes each of the destinations that have been opened. Every destination is closed even if
+// flushing or closing one of them fails.
+func (wr *RoutingWriter) Close(ctx context.Context) error {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	errs := make([]error, 0)
+
+	err := wr.flush(ctx)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for wr.open.Len() > 0 {
+
+		err := wr.closeFile(wr.open.Back())
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, target := range wr.writerTargets() {
+
+		err := wr.writers[target].Close(ctx)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to close %s, %w", target, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SetLogger is a no-op to conform to the `Writer` instance and returns nil.
+func (wr *RoutingWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return nil
+}
+
+// targetFile returns the (open) file for 'path', closing the least recently used file if necessary. Files are
+// created the first time they are opened and appended to if they are reopened. The lock for 'wr' must be held.
+func (wr *RoutingWriter) targetFile(path string) (*shard, error) {
+
+	s, ok := wr.files[path]
+
+	if ok && s.fh != nil {
+		wr.open.MoveToFront(wr.open_elements[path])
+		return s, nil
+	}
+
+	for wr.open.Len() >= wr.max_open {
+
+		err := wr.closeFile(wr.open.Back())
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fh *os.File
+
+	if ok {
+
+		v, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to reopen %s, %w", path, err)
+		}
+
+		fh = v
+
+	} else {
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create directory for %s, %w", path, err)
+		}
+
+		v, err := os.Create(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create %s, %w", path, err)
+		}
+
+		fh = v
+
+		s = &shard{
+			path: path,
+		}
+
+		wr.files[path] = s
+	}
+
+	s.fh = fh
+	s.buf = bufio.NewWriter(fh)
+
+	wr.open_elements[path] = wr.open.PushFront(s)
+	return s, nil
+}
+
+// closeFile flushes and closes the file for the list element 'el' and removes it from the list of open files. The
+// lock for 'wr' must be held.
+func (wr *RoutingWriter) closeFile(el *list.Element) error {
+
+	s := el.Value.(*shard)
+
+	wr.open.Remove(el)
+	delete(wr.open_elements, s.path)
+
+	fh := s.fh
+	buf := s.buf
+
+	s.fh = nil
+	s.buf = nil
+
+	err := buf.Flush()
+
+	if err != nil {
+		fh.Close()
+		return fmt.Errorf("Failed to flush %s, %w", s.path, err)
+	}
+
+	err = fh.Close()
+
+	if err != nil {
+		return fmt.Errorf("Failed to close %s, %w", s.path, err)
+	}
+
+	return nil
+}
+
+// flush flushes each of the open files, and writers, for 'wr' returning the errors for all of the destinations
+// that failed. The lock for 'wr' must be held.
+func (wr *RoutingWriter) flush(ctx context.Context) error {
+
+	errs := make([]error, 0)
+
+	for el := wr.open.Front(); el != nil; el = el.Next() {
+
+		s := el.Value.(*shard)
+		err := s.buf.Flush()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to flush %s, %w", s.path, err))
+		}
+	}
+
+	for _, target := range wr.writerTargets() {
+
+		err := wr.writers[target].Flush(ctx)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to flush %s, %w", target, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writerTargets returns the sorted list of URIs for the writers that have been opened. The lock for 'wr' must be held.
+func (wr *RoutingWriter) writerTargets() []string {
+
+	targets := make([]string, 0, len(wr.writers))
+
+	for t := range wr.writers {
+		targets = append(targets, t)
+	}
+
+	sort.Strings(targets)
+	return targets
+}
+
+// targetWriter returns the writer for 'uri', creating it if necessary. Writers can not be closed and then reopened
+// (without, for example, overwriting the features already written) so an error is returned if creating a new writer
+// would exceed the maximum number of open destinations for 'wr'.
+func (wr *RoutingWriter) targetWriter(ctx context.Context, uri string) (writer.Writer, error) {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	target_wr, ok := wr.writers[uri]
+
+	if ok {
+		return target_wr, nil
+	}
+
+	if len(wr.writers) >= wr.max_open {
+		return nil, fmt.Errorf("Failed to create writer for %s, template has more than %d distinct destinations (set the ?max-open= parameter to increase this limit)", uri, wr.max_open)
+	}
+
+	target_wr, err := writer.NewWriter(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create writer for %s, %w", uri, err)
+	}
+
+	wr.writers[uri] = target_wr
+	return target_wr, nil
+}
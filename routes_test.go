@@ -0,0 +1,231 @@
+package tippecanoe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoutingWriterMaxOpen(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	q := url.Values{}
+	q.Set("template", filepath.Join(root, "{wof:placetype}.jsonl"))
+	q.Set("max-open", "2")
+
+	wr, err := NewRoutingWriter(ctx, fmt.Sprintf("route://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create writer, %v", err)
+	}
+
+	placetypes := []string{"country", "region", "county", "locality", "country", "region", "county", "locality"}
+
+	for i, pt := range placetypes {
+
+		body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d,"wof:placetype":"%s"},"geometry":{"type":"Point","coordinates":[0,0]}}`, 100+i, pt)
+
+		_, err := wr.Write(ctx, fmt.Sprintf("%d.geojson", 100+i), bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to write feature %d, %v", i, err)
+		}
+
+		if wr.(*RoutingWriter).open.Len() > 2 {
+			t.Fatalf("Expected at most 2 open files")
+		}
+	}
+
+	err = wr.Close(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to close writer, %v", err)
+	}
+
+	for _, pt := range []string{"country", "region", "county", "locality"} {
+
+		path := filepath.Join(root, pt+".jsonl")
+
+		fh, err := os.Open(path)
+
+		if err != nil {
+			t.Fatalf("Failed to open %s, %v", path, err)
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(fh)
+
+		for scanner.Scan() {
+			lines += 1
+		}
+
+		fh.Close()
+
+		if lines != 2 {
+			t.Fatalf("Expected 2 features in %s, got %d", path, lines)
+		}
+	}
+}
+
+func TestRoutingWriterMaxOpenWriters(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	q := url.Values{}
+	q.Set("template", fmt.Sprintf("fs://%s/{wof:placetype}", root))
+	q.Set("max-open", "2")
+
+	wr, err := NewRoutingWriter(ctx, fmt.Sprintf("route://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create writer, %v", err)
+	}
+
+	placetypes := []string{"country", "region", "country", "locality"}
+
+	// The fs:// writer requires that its root directory exist
+
+	for _, pt := range placetypes {
+
+		err := os.MkdirAll(filepath.Join(root, pt), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory for %s, %v", pt, err)
+		}
+	}
+
+	for i, pt := range placetypes {
+
+		body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d,"wof:placetype":"%s"},"geometry":{"type":"Point","coordinates":[0,0]}}`, 100+i, pt)
+
+		_, err := wr.Write(ctx, fmt.Sprintf("%d.geojson", 100+i), bytes.NewReader([]byte(body)))
+
+		// Writers can't be reopened so a third distinct destination is an error
+
+		if pt == "locality" {
+
+			if err == nil {
+				t.Fatalf("Expected writing to a third destination to fail")
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Failed to write feature %d, %v", i, err)
+		}
+	}
+
+	err = wr.Close(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to close writer, %v", err)
+	}
+
+	for _, path := range []string{"country/100.geojson", "country/102.geojson", "region/101.geojson"} {
+
+		_, err := os.Stat(filepath.Join(root, path))
+
+		if err != nil {
+			t.Fatalf("Expected %s to exist, %v", path, err)
+		}
+	}
+}
+
+func TestRoutingWriterCloseErrors(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	q := url.Values{}
+	q.Set("template", filepath.Join(root, "{wof:placetype}.jsonl"))
+
+	wr, err := NewRoutingWriter(ctx, fmt.Sprintf("route://?%s", q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create writer, %v", err)
+	}
+
+	for i, pt := range []string{"country", "region"} {
+
+		body := fmt.Sprintf(`{"type":"Feature","properties":{"wof:id":%d,"wof:placetype":"%s"},"geometry":{"type":"Point","coordinates":[0,0]}}`, 100+i, pt)
+
+		_, err := wr.Write(ctx, fmt.Sprintf("%d.geojson", 100+i), bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to write feature %d, %v", i, err)
+		}
+	}
+
+	// Close the underlying files so that flushing (and closing) every destination fails
+
+	for _, s := range wr.(*RoutingWriter).files {
+		s.fh.Close()
+	}
+
+	err = wr.Close(ctx)
+
+	if err == nil {
+		t.Fatalf("Expected close to fail")
+	}
+
+	for _, pt := range []string{"country", "region"} {
+
+		if !strings.Contains(err.Error(), filepath.Join(root, pt+".jsonl")) {
+			t.Fatalf("Expected error for %s, %v", pt, err)
+		}
+	}
+
+	if wr.(*RoutingWriter).open.Len() != 0 {
+		t.Fatalf("Expected all files to be removed from the list of open files")
+	}
+}
+
+func TestRoutingWriterTarget(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		template string
+		body     string
+		expected string
+	}{
+		{"/tmp/layers/{wof:placetype}.jsonl", `{"properties":{"wof:placetype":"locality"}}`, "/tmp/layers/locality.jsonl"},
+		{"/tmp/layers/{properties.wof:placetype}.jsonl", `{"properties":{"wof:placetype":"locality"}}`, "/tmp/layers/locality.jsonl"},
+		{"/tmp/layers/{tippecanoe.layer}.jsonl", `{"tippecanoe":{"layer":"polygons"},"properties":{}}`, "/tmp/layers/polygons.jsonl"},
+		{"/tmp/layers/{wof:placetype}.jsonl", `{"properties":{}}`, "/tmp/layers/unknown.jsonl"},
+		{"/tmp/layers/{wof:placetype}.jsonl", `{"properties":{"wof:placetype":"../etc"}}`, "/tmp/layers/.._etc.jsonl"},
+		{"/tmp/layers/{wof:placetype}.jsonl", `{"properties":{"wof:placetype":".."}}`, "/tmp/layers/_.jsonl"},
+		{"/tmp/layers/{wof:placetype}.jsonl", `{"properties":{"wof:placetype":"."}}`, "/tmp/layers/_.jsonl"},
+	}
+
+	for _, test := range tests {
+
+		q := url.Values{}
+		q.Set("template", test.template)
+
+		wr, err := NewRoutingWriter(ctx, fmt.Sprintf("route://?%s", q.Encode()))
+
+		if err != nil {
+			t.Fatalf("Failed to create writer, %v", err)
+		}
+
+		target := wr.(*RoutingWriter).Target([]byte(test.body))
+
+		if target != test.expected {
+			t.Fatalf("Expected %s for %s, got %s", test.expected, test.body, target)
+		}
+	}
+}
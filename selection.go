@@ -0,0 +1,207 @@
+package tippecanoe
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// SelectionOptions defines the (unparsed) values, typically derived from command line flags, used to select which
+// records are processed and which geometry types they are emitted as. They are shared by the features and
+// affected-tiles tools so that both tools select the same records.
+type SelectionOptions struct {
+	// ValidAt is an optional EDTF date. See `ParseValidAt` for details.
+	ValidAt string
+	// ValidBetween is an optional {START},{END} range of EDTF dates. See `ParseValidBetween` for details.
+	ValidBetween string
+	// IncludeIds is zero or more comma-separated lists of IDs, or paths to files containing IDs, to include.
+	IncludeIds []string
+	// ExcludeIds is zero or more comma-separated lists of IDs, or paths to files containing IDs, to exclude.
+	ExcludeIds []string
+	// Placetypes is zero or more placetypes to include.
+	Placetypes []string
+	// ExcludePlacetypes is zero or more placetypes to exclude.
+	ExcludePlacetypes []string
+	// PlacetypeDescendantsOf is zero or more placetypes whose descendants should be included.
+	PlacetypeDescendantsOf []string
+	// PlacetypeAncestorsOf is zero or more placetypes whose ancestors should be included.
+	PlacetypeAncestorsOf []string
+	// GeometryTypes is zero or more GeoJSON geometry types to include.
+	GeometryTypes []string
+	// ExcludeGeometryTypes is zero or more GeoJSON geometry types to exclude.
+	ExcludeGeometryTypes []string
+	// PointPlacetypes is zero or more placetypes whose geometries should be replaced by centroid points.
+	PointPlacetypes []string
+}
+
+// SelectionFlags defines the command line flags used to derive a `SelectionOptions` instance. They are defined once,
+// using `AppendSelectionFlags`, so that the features and affected-tiles tools share the same flags and descriptions.
+type SelectionFlags struct {
+	ValidAt                string
+	ValidBetween           string
+	IncludeIds             multi.MultiString
+	ExcludeIds             multi.MultiString
+	Placetypes             multi.MultiCSVString
+	ExcludePlacetypes      multi.MultiCSVString
+	PlacetypeDescendantsOf multi.MultiCSVString
+	PlacetypeAncestorsOf   multi.MultiCSVString
+	GeometryTypes          multi.MultiCSVString
+	ExcludeGeometryTypes   multi.MultiCSVString
+	PointPlacetypes        multi.MultiCSVString
+}
+
+// AppendSelectionFlags appends the -valid-at, -valid-between, -include-id, -exclude-id, -placetype, -exclude-placetype,
+// -placetype-descendants-of, -placetype-ancestors-of, -geometry-type, -exclude-geometry-type and -points-placetype flags
+// to 'fs' assigning their values to 'sel_flags'.
+func AppendSelectionFlags(fs *flag.FlagSet, sel_flags *SelectionFlags) {
+
+	fs.Var(&sel_flags.GeometryTypes, "geometry-type", "Zero or more GeoJSON geometry types (Point, MultiPoint, LineString, MultiLineString, Polygon, MultiPolygon, GeometryCollection) to include in output. -require-polygons is equivalent to -geometry-type Polygon,MultiPolygon.")
+	fs.Var(&sel_flags.ExcludeGeometryTypes, "exclude-geometry-type", "Zero or more GeoJSON geometry types to exclude from output.")
+
+	fs.Var(&sel_flags.PointPlacetypes, "points-placetype", "Zero or more Who's On First placetypes whose geometries should be replaced by centroid points (derived from lbl:latitude and lbl:longitude, falling back to the centroid of the geometry) with population, population_norm and population_class properties.")
+
+	fs.StringVar(&sel_flags.ValidAt, "valid-at", "", "If not empty, only emit records whose EDTF inception and cessation dates overlap this (EDTF) date. For example: 1962, 1962-06 or 1962-06-01.")
+	fs.StringVar(&sel_flags.ValidBetween, "valid-between", "", "If not empty, only emit records whose EDTF inception and cessation dates overlap this range, in the form of {START},{END} where each value is an EDTF date. For example: 1950,1962.")
+
+	fs.Var(&sel_flags.IncludeIds, "include-id", "Zero or more comma-separated lists of Who's On First IDs, or paths to files containing IDs (one per line or a CSV document with an \"id\" or \"wof:id\" column), to include in output. If present only these IDs will be emitted and it is an error for the values to contain no IDs.")
+	fs.Var(&sel_flags.ExcludeIds, "exclude-id", "Zero or more comma-separated lists of Who's On First IDs, or paths to files containing IDs (one per line or a CSV document with an \"id\" or \"wof:id\" column), to exclude from output.")
+
+	fs.Var(&sel_flags.Placetypes, "placetype", "Zero or more Who's On First placetypes to include in output.")
+	fs.Var(&sel_flags.ExcludePlacetypes, "exclude-placetype", "Zero or more Who's On First placetypes to exclude from output.")
+	fs.Var(&sel_flags.PlacetypeDescendantsOf, "placetype-descendants-of", "Zero or more Who's On First placetypes whose descendants (including the placetype itself) should be included in output. For example -placetype-descendants-of region will include regions, counties, localities, neighbourhoods and so on.")
+	fs.Var(&sel_flags.PlacetypeAncestorsOf, "placetype-ancestors-of", "Zero or more Who's On First placetypes whose ancestors (including the placetype itself) should be included in output.")
+}
+
+// SelectionOptions returns a new `SelectionOptions` instance derived from the values in 'sel_flags'.
+func (sel_flags *SelectionFlags) SelectionOptions() *SelectionOptions {
+
+	sel_opts := &SelectionOptions{
+		ValidAt:                sel_flags.ValidAt,
+		ValidBetween:           sel_flags.ValidBetween,
+		IncludeIds:             sel_flags.IncludeIds,
+		ExcludeIds:             sel_flags.ExcludeIds,
+		Placetypes:             sel_flags.Placetypes,
+		ExcludePlacetypes:      sel_flags.ExcludePlacetypes,
+		PlacetypeDescendantsOf: sel_flags.PlacetypeDescendantsOf,
+		PlacetypeAncestorsOf:   sel_flags.PlacetypeAncestorsOf,
+		GeometryTypes:          sel_flags.GeometryTypes,
+		ExcludeGeometryTypes:   sel_flags.ExcludeGeometryTypes,
+		PointPlacetypes:        sel_flags.PointPlacetypes,
+	}
+
+	return sel_opts
+}
+
+// ApplySelectionOptions validates and parses the values in 'sel_opts' and assigns the results to the corresponding
+// properties of 'opts'. Errors name the flag (or parameter) that each value is derived from, without a leading "-". It is
+// an error for include-id values to yield an empty list of IDs.
+func ApplySelectionOptions(opts *IterwriterCallbackFuncBuilderOptions, sel_opts *SelectionOptions) error {
+
+	_, err := NewGeometryTypeFilter(sel_opts.GeometryTypes, sel_opts.ExcludeGeometryTypes)
+
+	if err != nil {
+		return fmt.Errorf("Invalid geometry-type or exclude-geometry-type value, %w", err)
+	}
+
+	opts.GeometryTypes = sel_opts.GeometryTypes
+	opts.ExcludeGeometryTypes = sel_opts.ExcludeGeometryTypes
+
+	for _, pt := range sel_opts.PointPlacetypes {
+
+		if !IsValidPlacetype(pt) {
+			return fmt.Errorf("Invalid points-placetype value, unknown placetype '%s'", pt)
+		}
+	}
+
+	opts.PointPlacetypes = sel_opts.PointPlacetypes
+
+	if sel_opts.ValidAt != "" && sel_opts.ValidBetween != "" {
+		return fmt.Errorf("valid-at and valid-between values are mutually exclusive")
+	}
+
+	opts.ValidRange = nil
+
+	if sel_opts.ValidAt != "" {
+
+		r, err := ParseValidAt(sel_opts.ValidAt)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse valid-at value, %w", err)
+		}
+
+		opts.ValidRange = r
+	}
+
+	if sel_opts.ValidBetween != "" {
+
+		start, end, ok := strings.Cut(sel_opts.ValidBetween, ",")
+
+		if !ok {
+			return fmt.Errorf("Invalid valid-between value, expected {START},{END}")
+		}
+
+		r, err := ParseValidBetween(strings.TrimSpace(start), strings.TrimSpace(end))
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse valid-between value, %w", err)
+		}
+
+		opts.ValidRange = r
+	}
+
+	opts.IncludeIds = nil
+	opts.ExcludeIds = nil
+
+	if len(sel_opts.IncludeIds) > 0 {
+
+		ids, err := NewIdList(sel_opts.IncludeIds...)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive IDs from include-id value, %w", err)
+		}
+
+		// An empty include list would exclude every record so it is almost certainly a mistake (for example
+		// a truncated export or a CSV file without an ID column) rather than something to silently honour
+
+		if ids.Len() == 0 {
+			return fmt.Errorf("include-id value did not yield any IDs")
+		}
+
+		opts.IncludeIds = ids
+	}
+
+	if len(sel_opts.ExcludeIds) > 0 {
+
+		ids, err := NewIdList(sel_opts.ExcludeIds...)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive IDs from exclude-id value, %w", err)
+		}
+
+		opts.ExcludeIds = ids
+	}
+
+	opts.Placetypes = nil
+
+	if len(sel_opts.Placetypes) > 0 || len(sel_opts.ExcludePlacetypes) > 0 || len(sel_opts.PlacetypeDescendantsOf) > 0 || len(sel_opts.PlacetypeAncestorsOf) > 0 {
+
+		pt_opts := &PlacetypeSelectionOptions{
+			Placetypes:        sel_opts.Placetypes,
+			ExcludePlacetypes: sel_opts.ExcludePlacetypes,
+			DescendantsOf:     sel_opts.PlacetypeDescendantsOf,
+			AncestorsOf:       sel_opts.PlacetypeAncestorsOf,
+		}
+
+		pt, err := NewPlacetypeSelection(pt_opts)
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive placetypes, %w", err)
+		}
+
+		opts.Placetypes = pt
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package tippecanoe
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplySelectionOptions(t *testing.T) {
+
+	valid := []*SelectionOptions{
+		{},
+		{ValidAt: "1962-06"},
+		{ValidBetween: "1950, 1962"},
+		{IncludeIds: []string{"101736545,85922583"}, ExcludeIds: []string{"1234"}},
+		{Placetypes: []string{"locality"}, PlacetypeDescendantsOf: []string{"region"}},
+		{GeometryTypes: []string{"Polygon", "MultiPolygon"}, PointPlacetypes: []string{"locality"}},
+	}
+
+	for _, sel_opts := range valid {
+
+		opts := &IterwriterCallbackFuncBuilderOptions{}
+
+		err := ApplySelectionOptions(opts, sel_opts)
+
+		if err != nil {
+			t.Fatalf("Expected %v to be valid, %v", sel_opts, err)
+		}
+
+		if (sel_opts.ValidAt != "" || sel_opts.ValidBetween != "") != (opts.ValidRange != nil) {
+			t.Fatalf("Expected valid range to be assigned for %v", sel_opts)
+		}
+
+		if (len(sel_opts.IncludeIds) > 0) != (opts.IncludeIds != nil) {
+			t.Fatalf("Expected include IDs to be assigned for %v", sel_opts)
+		}
+
+		if (len(sel_opts.Placetypes) > 0) != (opts.Placetypes != nil) {
+			t.Fatalf("Expected placetypes to be assigned for %v", sel_opts)
+		}
+	}
+
+	invalid := []*SelectionOptions{
+		{ValidAt: "1962", ValidBetween: "1950,1962"},
+		{ValidBetween: "1950"},
+		{ValidBetween: "1962,1950"},
+		{ValidAt: "not a date"},
+		{IncludeIds: []string{"one,two"}},
+		{IncludeIds: []string{" , "}},
+		{Placetypes: []string{"planetoid"}},
+		{GeometryTypes: []string{"Circle"}},
+		{PointPlacetypes: []string{"planetoid"}},
+	}
+
+	for _, sel_opts := range invalid {
+
+		err := ApplySelectionOptions(&IterwriterCallbackFuncBuilderOptions{}, sel_opts)
+
+		if err == nil {
+			t.Fatalf("Expected %v to be invalid", sel_opts)
+		}
+	}
+}
+
+func TestApplySelectionOptionsEmptyIdFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ids.csv")
+
+	err := os.WriteFile(path, []byte("name,placetype\nExample,locality\n"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write ID file, %v", err)
+	}
+
+	opts := &IterwriterCallbackFuncBuilderOptions{}
+
+	err = ApplySelectionOptions(opts, &SelectionOptions{IncludeIds: []string{path}})
+
+	if err == nil {
+		t.Fatalf("Expected an include-id file without any IDs to be invalid")
+	}
+
+	// An empty exclude list excludes nothing so it is allowed
+
+	err = ApplySelectionOptions(opts, &SelectionOptions{ExcludeIds: []string{path}})
+
+	if err != nil {
+		t.Fatalf("Expected an exclude-id file without any IDs to be valid, %v", err)
+	}
+}
+
+func TestAppendSelectionFlags(t *testing.T) {
+
+	var sel_flags SelectionFlags
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	AppendSelectionFlags(fs, &sel_flags)
+
+	args := []string{
+		"-valid-at", "1962-06",
+		"-include-id", "101736545",
+		"-include-id", "85922583",
+		"-placetype", "locality,region",
+		"-geometry-type", "Polygon",
+	}
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		t.Fatalf("Failed to parse flags, %v", err)
+	}
+
+	sel_opts := sel_flags.SelectionOptions()
+
+	if sel_opts.ValidAt != "1962-06" {
+		t.Fatalf("Unexpected valid-at value, %s", sel_opts.ValidAt)
+	}
+
+	if !slices.Equal(sel_opts.IncludeIds, []string{"101736545", "85922583"}) {
+		t.Fatalf("Unexpected include-id values, %v", sel_opts.IncludeIds)
+	}
+
+	if !slices.Equal(sel_opts.Placetypes, []string{"locality", "region"}) {
+		t.Fatalf("Unexpected placetype values, %v", sel_opts.Placetypes)
+	}
+
+	if !slices.Equal(sel_opts.GeometryTypes, []string{"Polygon"}) {
+		t.Fatalf("Unexpected geometry-type values, %v", sel_opts.GeometryTypes)
+	}
+}
@@ -0,0 +1,429 @@
+package tippecanoe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+const SHARDS_PARTITION_ROUNDROBIN string = "roundrobin"
+
+const SHARDS_PARTITION_ID string = "id"
+
+const SHARDS_PARTITION_SPATIAL string = "spatial"
+
+// The default zoom level of the tiles used to assign features to shards when using the "spatial" partition.
+const SHARDS_DEFAULT_SPATIAL_ZOOM int = 6
+
+func init() {
+
+	ctx := context.Background()
+
+	err := writer.RegisterWriter(ctx, "shards", NewShardedWriter)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ShardedWriter implements the `whosonfirst/go-writer/v3.Writer` interface for writing features as JSON-L
+// to multiple files so that they can be read in parallel by `tippecanoe -P` or distributed across machines.
+type ShardedWriter struct {
+	writer.Writer
+	root      string
+	prefix    string
+	partition string
+	zoom      maptile.Zoom
+	manifest  bool
+	shards    []*shard
+	counter   int64
+}
+
+// ShardManifest is a struct describing the files produced by a `ShardedWriter` instance.
+type ShardManifest struct {
+	Partition string               `json:"partition"`
+	Count     int                  `json:"count"`
+	Features  int64                `json:"features"`
+	Shards    []*ShardManifestItem `json:"shards"`
+}
+
+// ShardManifestItem is a struct describing an individual file produced by a `ShardedWriter` instance.
+type ShardManifestItem struct {
+	Path     string `json:"path"`
+	Features int64  `json:"features"`
+	Bytes    int64  `json:"bytes"`
+}
+
+type shard struct {
+	path     string
+	fh       *os.File
+	buf      *bufio.Writer
+	mu       *sync.Mutex
+	features int64
+	bytes    int64
+}
+
+// NewShardedWriter returns a new `ShardedWriter` instance configured by 'uri' in the form of:
+//
+//	shards://{PATH}?{PARAMETERS}
+//
+// Where {PATH} is the directory where shard files will be written and {PARAMETERS} may be:
+// * `?count=` The number of shard files to create. Default is 4.
+// * `?prefix=` The prefix for shard filenames. Default is "features".
+// * `?partition=` The partitioning scheme used to assign features to shards. Valid options are "roundrobin" (default), "id" or "spatial".
+// * `?zoom=` The zoom level of the tiles used to assign features to shards when using the "spatial" partition. Default is 6.
+// * `?manifest=` A boolean value indicating whether to write a manifest file listing each shard. Default is true.
+//
+// Shard files are named "{PREFIX}-{NNN}.jsonl" and the manifest is named "{PREFIX}-manifest.json". The "spatial"
+// partition assigns features to shards using a stable hash of the z/x/y map tile (at `?zoom=`) containing the center
+// of each feature's bounding box. Features whose centers fall in the same tile are kept together while features for
+// regional data, which only covers a small part of the world, are still spread across all the shards. Adjacent tiles
+// hash to unrelated shards so features which are near each other, but in different tiles, may be written to different
+// shards.
+func NewShardedWriter(ctx context.Context, uri string) (writer.Writer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	root := u.Path
+
+	if root == "" {
+		return nil, fmt.Errorf("Missing shards root directory")
+	}
+
+	q := u.Query()
+
+	count := 4
+	prefix := "features"
+	partition := SHARDS_PARTITION_ROUNDROBIN
+	manifest := true
+	zoom := SHARDS_DEFAULT_SPATIAL_ZOOM
+
+	if q.Has("count") {
+
+		v, err := strconv.Atoi(q.Get("count"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?count=' parameter, %w", err)
+		}
+
+		if v < 1 {
+			return nil, fmt.Errorf("Invalid '?count=' parameter, must be greater than zero")
+		}
+
+		count = v
+	}
+
+	if q.Has("prefix") {
+		prefix = q.Get("prefix")
+	}
+
+	if q.Has("partition") {
+
+		partition = q.Get("partition")
+
+		switch partition {
+		case SHARDS_PARTITION_ROUNDROBIN, SHARDS_PARTITION_ID, SHARDS_PARTITION_SPATIAL:
+			// pass
+		default:
+			return nil, fmt.Errorf("Invalid '?partition=' parameter, %s", partition)
+		}
+	}
+
+	if q.Has("zoom") {
+
+		v, err := strconv.Atoi(q.Get("zoom"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?zoom=' parameter, %w", err)
+		}
+
+		if v < 0 || v > 32 {
+			return nil, fmt.Errorf("Invalid '?zoom=' parameter, must be between 0 and 32")
+		}
+
+		zoom = v
+	}
+
+	if q.Has("manifest") {
+
+		v, err := strconv.ParseBool(q.Get("manifest"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse '?manifest=' parameter, %w", err)
+		}
+
+		manifest = v
+	}
+
+	err = os.MkdirAll(root, 0755)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create %s, %w", root, err)
+	}
+
+	shards := make([]*shard, count)
+
+	for i := 0; i < count; i++ {
+
+		fname := fmt.Sprintf("%s-%03d.jsonl", prefix, i)
+		path := filepath.Join(root, fname)
+
+		fh, err := os.Create(path)
+
+		if err != nil {
+
+			// Close any shard files which have already been opened
+
+			for _, prev := range shards[:i] {
+				prev.fh.Close()
+			}
+
+			return nil, fmt.Errorf("Failed to create shard %s, %w", path, err)
+		}
+
+		shards[i] = &shard{
+			path: path,
+			fh:   fh,
+			buf:  bufio.NewWriter(fh),
+			mu:   new(sync.Mutex),
+		}
+	}
+
+	wr := &ShardedWriter{
+		root:      root,
+		prefix:    prefix,
+		partition: partition,
+		zoom:      maptile.Zoom(zoom),
+		manifest:  manifest,
+		shards:    shards,
+	}
+
+	return wr, nil
+}
+
+// Write appends the content of 'fh' as a single line of JSON to one of the shard files managed by 'wr'.
+func (wr *ShardedWriter) Write(ctx context.Context, key string, fh io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(fh)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	var buf bytes.Buffer
+
+	err = json.Compact(&buf, body)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to compact %s, %w", key, err)
+	}
+
+	buf.WriteString("\n")
+
+	idx, err := wr.shardIndex(key, body)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to derive shard for %s, %w", key, err)
+	}
+
+	s := wr.shards[idx]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.buf.Write(buf.Bytes())
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to write %s to %s, %w", key, s.path, err)
+	}
+
+	s.features += 1
+	s.bytes += int64(n)
+
+	return int64(n), nil
+}
+
+// WriterURI returns the directory that shard files are written to.
+func (wr *ShardedWriter) WriterURI(ctx context.Context, key string) string {
+	return wr.root
+}
+
+// Flush flushes any buffered data to each of the shard files. Every shard is flushed even if an earlier shard
+// fails and any errors are joined.
+func (wr *ShardedWriter) Flush(ctx context.Context) error {
+
+	errs := make([]error, 0)
+
+	for _, s := range wr.shards {
+
+		s.mu.Lock()
+		err := s.buf.Flush()
+		s.mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to flush %s, %w", s.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close flushes and closes each of the shard files and, if required, writes a manifest file. Every shard is
+// closed even if an earlier shard fails and any errors are joined.
+func (wr *ShardedWriter) Close(ctx context.Context) error {
+
+	errs := make([]error, 0)
+
+	err := wr.Flush(ctx)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, s := range wr.shards {
+
+		err := s.fh.Close()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to close %s, %w", s.path, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if !wr.manifest {
+		return nil
+	}
+
+	m := wr.Manifest()
+
+	path := filepath.Join(wr.root, fmt.Sprintf("%s-manifest.json", wr.prefix))
+
+	m_fh, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create manifest %s, %w", path, err)
+	}
+
+	enc := json.NewEncoder(m_fh)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(m)
+
+	if err != nil {
+		m_fh.Close()
+		return fmt.Errorf("Failed to encode manifest, %w", err)
+	}
+
+	return m_fh.Close()
+}
+
+// SetLogger is a no-op to conform to the `Writer` instance and returns nil.
+func (wr *ShardedWriter) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return nil
+}
+
+// Manifest returns a `ShardManifest` describing the files written by 'wr'.
+func (wr *ShardedWriter) Manifest() *ShardManifest {
+
+	items := make([]*ShardManifestItem, len(wr.shards))
+	total := int64(0)
+
+	for idx, s := range wr.shards {
+
+		s.mu.Lock()
+
+		items[idx] = &ShardManifestItem{
+			Path:     filepath.Base(s.path),
+			Features: s.features,
+			Bytes:    s.bytes,
+		}
+
+		total += s.features
+		s.mu.Unlock()
+	}
+
+	m := &ShardManifest{
+		Partition: wr.partition,
+		Count:     len(wr.shards),
+		Features:  total,
+		Shards:    items,
+	}
+
+	return m
+}
+
+func (wr *ShardedWriter) shardIndex(key string, body []byte) (int, error) {
+
+	count := len(wr.shards)
+
+	switch wr.partition {
+	case SHARDS_PARTITION_ID:
+
+		id, _, err := uri.ParseURI(key)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to parse key, %w", err)
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(strconv.FormatInt(id, 10)))
+
+		return int(h.Sum32() % uint32(count)), nil
+
+	case SHARDS_PARTITION_SPATIAL:
+
+		geom_rsp := gjson.GetBytes(body, "geometry")
+
+		if !geom_rsp.Exists() {
+			return 0, fmt.Errorf("Missing geometry")
+		}
+
+		geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to unmarshal geometry, %w", err)
+		}
+
+		center := geom.Geometry().Bound().Center()
+
+		return ShardIndexForTile(maptile.At(center, wr.zoom), count), nil
+
+	default:
+		i := atomic.AddInt64(&wr.counter, 1) - 1
+		return int(i % int64(count)), nil
+	}
+}
+
+// ShardIndexForTile returns the (stable) index of the shard, out of 'count' shards, for the map tile 't'.
+func ShardIndexForTile(t maptile.Tile, count int) int {
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d/%d/%d", t.Z, t.X, t.Y)
+
+	return int(h.Sum32() % uint32(count))
+}
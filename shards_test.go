@@ -0,0 +1,100 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+)
+
+func TestShardIndexForTileRegional(t *testing.T) {
+
+	count := 8
+	seen := make(map[int]bool)
+
+	// Sample the centers of tiles covering the continental United States
+
+	for lon := -124.0; lon <= -67.0; lon += 2.0 {
+
+		for lat := 25.0; lat <= 49.0; lat += 2.0 {
+
+			tile := maptile.At(orb.Point{lon, lat}, maptile.Zoom(SHARDS_DEFAULT_SPATIAL_ZOOM))
+			idx := ShardIndexForTile(tile, count)
+
+			if idx < 0 || idx >= count {
+				t.Fatalf("Invalid shard index %d for %v", idx, tile)
+			}
+
+			seen[idx] = true
+		}
+	}
+
+	if len(seen) != count {
+		t.Fatalf("Expected regional data to be spread across %d shards but only %d were used", count, len(seen))
+	}
+}
+
+func TestShardIndexForTileStable(t *testing.T) {
+
+	tile := maptile.New(10, 20, 6)
+
+	a := ShardIndexForTile(tile, 8)
+	b := ShardIndexForTile(tile, 8)
+
+	if a != b {
+		t.Fatalf("Expected stable shard index, got %d and %d", a, b)
+	}
+}
+
+func TestShardedWriterSpatial(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+
+	q := url.Values{}
+	q.Set("count", "4")
+	q.Set("partition", SHARDS_PARTITION_SPATIAL)
+
+	wr, err := NewShardedWriter(ctx, fmt.Sprintf("shards://%s?%s", root, q.Encode()))
+
+	if err != nil {
+		t.Fatalf("Failed to create writer, %v", err)
+	}
+
+	for i, lon := range []float64{-122.4, -73.5, 2.3, 139.7} {
+
+		key := fmt.Sprintf("%d.geojson", 100+i)
+		body := fmt.Sprintf(`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[%f,45.0]}}`, lon)
+
+		_, err := wr.Write(ctx, key, bytes.NewReader([]byte(body)))
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", key, err)
+		}
+	}
+
+	err = wr.Close(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to close writer, %v", err)
+	}
+
+	m := wr.(*ShardedWriter).Manifest()
+
+	if m.Features != 4 {
+		t.Fatalf("Expected 4 features, got %d", m.Features)
+	}
+
+	_, err = os.Stat(filepath.Join(root, "features-manifest.json"))
+
+	if err != nil {
+		t.Fatalf("Expected manifest file, %v", err)
+	}
+}
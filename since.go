@@ -0,0 +1,458 @@
+package tippecanoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+const GIT_CHANGE_INSERT string = "insert"
+
+const GIT_CHANGE_MODIFY string = "modify"
+
+const GIT_CHANGE_DELETE string = "delete"
+
+// The prefix used to signal that a `-since` value is a Unix timestamp.
+const SINCE_PREFIX_UNIX string = "unix:"
+
+// The prefix used to signal that a `-since` value is a Git commit.
+const SINCE_PREFIX_COMMIT string = "commit:"
+
+// Unix timestamps since March 1973 have at least nine digits. Shorter all-digit values are either
+// YYYYMMDD dates (eight digits) or short Git commit hashes.
+var re_since_timestamp = regexp.MustCompile(`^\d{9,}$`)
+
+var re_since_commit = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+var re_git_scp = regexp.MustCompile(`^[A-Za-z0-9_.\-]+@[A-Za-z0-9_.\-]+:`)
+
+// ErrCommitNotFound is returned (wrapped) by `GitChangesSince` when a commit does not exist in a repository.
+var ErrCommitNotFound = errors.New("Commit not found")
+
+// Since is a struct used to limit output to records that have been modified since a point in time
+// or since a Git commit.
+type Since struct {
+	// Timestamp is a Unix timestamp. If greater than zero records whose "wof:lastmodified" property is
+	// less than this value will be excluded.
+	Timestamp int64
+	// Commit is a Git commit hash (or revision) that changes are derived from.
+	Commit string
+	// Changes is the list of Who's On First records which have changed since Commit.
+	Changes []*GitChange
+	// paths is a lookup table of the (Who's On First) relative paths in Changes that still exist.
+	paths map[string]bool
+	// tmpdirs are the temporary directories that remote repositories were cloned in to.
+	tmpdirs []string
+}
+
+// GitChange is a struct describing a Who's On First record that has changed between two commits in a Git repository.
+type GitChange struct {
+	// Repo is the path or URI of the Git repository.
+	Repo string
+	// Path is the path of the record relative to the root of the repository.
+	Path string
+	// RelPath is the Who's On First relative path for the record.
+	RelPath string
+	// Action is the type of change: `GIT_CHANGE_INSERT`, `GIT_CHANGE_MODIFY` or `GIT_CHANGE_DELETE`.
+	Action string
+	// previous is an optional function used to retrieve the contents of the record as it existed at the original commit.
+	previous func() ([]byte, error)
+}
+
+// Previous returns the body of the record as it existed before it was changed. If the record was inserted
+// (and so has no previous state) it returns nil.
+func (ch *GitChange) Previous() ([]byte, error) {
+
+	if ch.previous == nil {
+		return nil, nil
+	}
+
+	return ch.previous()
+}
+
+// ParseSince returns a new `Since` instance derived from 'str' which may be a Unix timestamp, an ISO-8601
+// (RFC3339, YYYY-MM-DD or YYYYMMDD) date or a Git commit hash. All-digit values are only treated as Unix timestamps
+// if they have at least nine digits; use the `SINCE_PREFIX_UNIX` ("unix:") or `SINCE_PREFIX_COMMIT` ("commit:")
+// prefixes to be explicit. If 'str' is a Git commit then 'repos' is the list of Git repositories (local paths or
+// remote URIs) used to derive the set of records that have changed between that commit and each repository's HEAD.
+// Since a commit only exists in one repository, repositories where it can not be resolved (and local paths which
+// are not Git repositories) are skipped; it is an error if it can not be resolved in any of them. Remote repositories are cloned to a temporary directory on disk
+// which is removed by the `Close` method.
+func ParseSince(ctx context.Context, str string, repos ...string) (*Since, error) {
+
+	switch {
+	case strings.HasPrefix(str, SINCE_PREFIX_UNIX):
+		return parseSinceTimestamp(strings.TrimPrefix(str, SINCE_PREFIX_UNIX))
+	case strings.HasPrefix(str, SINCE_PREFIX_COMMIT):
+		return parseSinceCommit(ctx, strings.TrimPrefix(str, SINCE_PREFIX_COMMIT), repos...)
+	case re_since_timestamp.MatchString(str):
+		return parseSinceTimestamp(str)
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly, "20060102"} {
+
+		t, err := time.Parse(layout, str)
+
+		if err == nil {
+
+			s := &Since{
+				Timestamp: t.Unix(),
+			}
+
+			return s, nil
+		}
+	}
+
+	return parseSinceCommit(ctx, str, repos...)
+}
+
+func parseSinceTimestamp(str string) (*Since, error) {
+
+	ts, err := strconv.ParseInt(str, 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse timestamp, %w", err)
+	}
+
+	s := &Since{
+		Timestamp: ts,
+	}
+
+	return s, nil
+}
+
+func parseSinceCommit(ctx context.Context, str string, repos ...string) (*Since, error) {
+
+	if !re_since_commit.MatchString(str) {
+		return nil, fmt.Errorf("Invalid value '%s', expected a Unix timestamp, an ISO-8601 date or a Git commit", str)
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("Deriving changes since a Git commit requires one or more Git repositories")
+	}
+
+	s := &Since{
+		Commit:  str,
+		Changes: make([]*GitChange, 0),
+		paths:   make(map[string]bool),
+		tmpdirs: make([]string, 0),
+	}
+
+	resolved := 0
+
+	for _, repo_uri := range repos {
+
+		logger := slog.Default()
+		logger = logger.With("repo", repo_uri)
+		logger = logger.With("commit", str)
+
+		repo, tmpdir, err := openGitRepository(ctx, repo_uri)
+
+		if errors.Is(err, gogit.ErrRepositoryNotExists) {
+			logger.Warn("Path is not a Git repository, skipping")
+			continue
+		}
+
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		repo_changes, err := GitChangesSince(ctx, repo, repo_uri, str)
+
+		if errors.Is(err, ErrCommitNotFound) {
+
+			logger.Warn("Commit not found in repository, skipping")
+
+			if tmpdir != "" {
+				os.RemoveAll(tmpdir)
+			}
+
+			continue
+		}
+
+		if tmpdir != "" {
+			s.tmpdirs = append(s.tmpdirs, tmpdir)
+		}
+
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("Failed to derive changes for %s, %w", repo_uri, err)
+		}
+
+		for _, ch := range repo_changes {
+
+			if ch.Action != GIT_CHANGE_DELETE {
+				s.paths[ch.RelPath] = true
+			}
+
+			s.Changes = append(s.Changes, ch)
+		}
+
+		resolved += 1
+	}
+
+	if resolved == 0 {
+		return nil, fmt.Errorf("Failed to resolve commit %s in any repository, %w", str, ErrCommitNotFound)
+	}
+
+	return s, nil
+}
+
+// Close removes any temporary directories that remote repositories were cloned in to. The `Previous` method
+// of changes derived from those repositories will fail once 's' has been closed.
+func (s *Since) Close() error {
+
+	errs := make([]error, 0)
+
+	for _, tmpdir := range s.tmpdirs {
+
+		err := os.RemoveAll(tmpdir)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to remove %s, %w", tmpdir, err))
+		}
+	}
+
+	s.tmpdirs = nil
+	return errors.Join(errs...)
+}
+
+// IncludePath returns a boolean value indicating whether the record with Who's On First relative path 'rel_path'
+// should be included in output. If 's' was derived from a Git commit this is true if 'rel_path' was added or
+// modified since that commit, otherwise it is always true.
+func (s *Since) IncludePath(rel_path string) bool {
+
+	if s.paths == nil {
+		return true
+	}
+
+	return s.paths[rel_path]
+}
+
+// IncludeLastModified returns a boolean value indicating whether a record whose "wof:lastmodified" property is
+// 'lastmod' should be included in output.
+func (s *Since) IncludeLastModified(lastmod int64) bool {
+
+	if s.Timestamp <= 0 {
+		return true
+	}
+
+	return lastmod >= s.Timestamp
+}
+
+// GitChangesSince returns the list of Who's On First records that have been added, modified or removed between 'commit'
+// and HEAD in 'repo' (whose path or URI is 'repo_uri'). If 'commit' can not be resolved the error returned wraps
+// `ErrCommitNotFound`.
+func GitChangesSince(ctx context.Context, repo *gogit.Repository, repo_uri string, commit string) ([]*GitChange, error) {
+
+	logger := slog.Default()
+	logger = logger.With("repo", repo_uri)
+	logger = logger.With("commit", commit)
+
+	head, err := repo.Head()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive HEAD, %w", err)
+	}
+
+	from_hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to resolve commit %s, %w (%w)", commit, ErrCommitNotFound, err)
+	}
+
+	from_tree, err := gitTree(repo, *from_hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	to_tree, err := gitTree(repo, head.Hash())
+
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := object.DiffTreeWithOptions(ctx, from_tree, to_tree, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to diff trees, %w", err)
+	}
+
+	changes := make([]*GitChange, 0)
+
+	for _, d := range diff {
+
+		action, err := d.Action()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive action for change, %w", err)
+		}
+
+		path := d.To.Name
+
+		if path == "" {
+			path = d.From.Name
+		}
+
+		if filepath.Ext(path) != ".geojson" {
+			continue
+		}
+
+		id, uri_args, err := uri.ParseURI(path)
+
+		if err != nil {
+			logger.Debug("Failed to parse path, skipping", "path", path, "error", err)
+			continue
+		}
+
+		rel_path, err := uri.Id2RelPath(id, uri_args)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive relative path for %s, %w", path, err)
+		}
+
+		ch := &GitChange{
+			Repo:    repo_uri,
+			Path:    path,
+			RelPath: rel_path,
+			Action:  gitChangeAction(action),
+		}
+
+		if ch.Action != GIT_CHANGE_INSERT {
+
+			from := d.From
+
+			ch.previous = func() ([]byte, error) {
+
+				f, err := from.Tree.TreeEntryFile(&from.TreeEntry)
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to derive previous file for %s, %w", from.Name, err)
+				}
+
+				body, err := f.Contents()
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to read previous file for %s, %w", from.Name, err)
+				}
+
+				return []byte(body), nil
+			}
+		}
+
+		changes = append(changes, ch)
+	}
+
+	logger.Debug("Derived changes", "head", head.Hash(), "count", len(changes))
+	return changes, nil
+}
+
+// openGitRepository opens 'repo_uri' in place if it is a local directory or, if it is a remote URL, clones it (without a
+// working tree) in to a new temporary directory on disk whose path is returned so that it can be removed by the caller.
+// Any other value (for example a file path) returns `gogit.ErrRepositoryNotExists`.
+func openGitRepository(ctx context.Context, repo_uri string) (*gogit.Repository, string, error) {
+
+	info, err := os.Stat(repo_uri)
+
+	if err == nil && info.IsDir() {
+
+		repo, err := gogit.PlainOpen(repo_uri)
+
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to open repository %s, %w", repo_uri, err)
+		}
+
+		return repo, "", nil
+	}
+
+	if !isRemoteGitURI(repo_uri) {
+		return nil, "", gogit.ErrRepositoryNotExists
+	}
+
+	tmpdir, err := os.MkdirTemp("", "since")
+
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to create temporary directory, %w", err)
+	}
+
+	clone_opts := &gogit.CloneOptions{
+		URL: repo_uri,
+	}
+
+	repo, err := gogit.PlainCloneContext(ctx, tmpdir, true, clone_opts)
+
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return nil, "", fmt.Errorf("Failed to clone repository %s, %w", repo_uri, err)
+	}
+
+	return repo, tmpdir, nil
+}
+
+// isRemoteGitURI returns true if 'repo_uri' is a URL (or an SCP-style "user@host:path" address) that can be cloned.
+func isRemoteGitURI(repo_uri string) bool {
+
+	if re_git_scp.MatchString(repo_uri) {
+		return true
+	}
+
+	u, err := url.Parse(repo_uri)
+
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "file":
+		return u.Path != ""
+	case "http", "https", "git", "ssh", "git+ssh":
+		return u.Host != ""
+	default:
+		return false
+	}
+}
+
+func gitTree(repo *gogit.Repository, hash plumbing.Hash) (*object.Tree, error) {
+
+	commit, err := repo.CommitObject(hash)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive commit object for %s, %w", hash, err)
+	}
+
+	tree, err := commit.Tree()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive tree for %s, %w", hash, err)
+	}
+
+	return tree, nil
+}
+
+func gitChangeAction(action merkletrie.Action) string {
+
+	switch action {
+	case merkletrie.Insert:
+		return GIT_CHANGE_INSERT
+	case merkletrie.Delete:
+		return GIT_CHANGE_DELETE
+	default:
+		return GIT_CHANGE_MODIFY
+	}
+}
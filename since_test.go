@@ -0,0 +1,238 @@
+package tippecanoe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestParseSinceTimestamp(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]int64{
+		"1690000000":           1690000000,
+		"unix:1690000000":      1690000000,
+		"unix:12345":           12345,
+		"2023-07-22":           time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC).Unix(),
+		"20230722":             time.Date(2023, 7, 22, 0, 0, 0, 0, time.UTC).Unix(),
+		"2023-07-22T04:26:40Z": 1690000000,
+	}
+
+	for str, expected := range tests {
+
+		s, err := ParseSince(ctx, str)
+
+		if err != nil {
+			t.Fatalf("Failed to parse '%s', %v", str, err)
+		}
+
+		if s.Timestamp != expected {
+			t.Fatalf("Expected %d for '%s', got %d", expected, str, s.Timestamp)
+		}
+	}
+}
+
+func TestParseSinceInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []string{
+		"yesterday",
+		"unix:abc",
+		"commit:xyz",
+		// A commit requires one or more repositories
+		"1234567",
+		"commit:4b1a9c2",
+	}
+
+	for _, str := range tests {
+
+		_, err := ParseSince(ctx, str)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to fail", str)
+		}
+	}
+}
+
+func TestParseSinceCommitMultipleRepos(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo_a, commit_a := testGitRepo(t, "101/736/545/101736545.geojson", "859/225/83/85922583.geojson")
+	repo_b, _ := testGitRepo(t, "102/087/579/102087579.geojson", "110/000/000/1/1100000001.geojson")
+
+	s, err := ParseSince(ctx, "commit:"+commit_a, repo_a, repo_b)
+
+	if err != nil {
+		t.Fatalf("Failed to parse commit, %v", err)
+	}
+
+	defer s.Close()
+
+	if len(s.Changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(s.Changes))
+	}
+
+	if !s.IncludePath("859/225/83/85922583.geojson") {
+		t.Fatalf("Expected changed record to be included")
+	}
+
+	if s.IncludePath("101/736/545/101736545.geojson") {
+		t.Fatalf("Expected unchanged record to be excluded")
+	}
+
+	_, err = ParseSince(ctx, "commit:"+commit_a, repo_b)
+
+	if !errors.Is(err, ErrCommitNotFound) {
+		t.Fatalf("Expected ErrCommitNotFound, got %v", err)
+	}
+}
+
+func TestParseSinceCommitRemote(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo_a, commit_a := testGitRepo(t, "101/736/545/101736545.geojson", "859/225/83/85922583.geojson")
+
+	s, err := ParseSince(ctx, commit_a, "file://"+repo_a)
+
+	if err != nil {
+		t.Fatalf("Failed to parse commit, %v", err)
+	}
+
+	if len(s.tmpdirs) != 1 {
+		t.Fatalf("Expected remote repository to be cloned to a temporary directory")
+	}
+
+	tmpdir := s.tmpdirs[0]
+
+	err = s.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to close, %v", err)
+	}
+
+	_, err = os.Stat(tmpdir)
+
+	if !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed", tmpdir)
+	}
+}
+
+func TestParseSinceCommitSkipsFiles(t *testing.T) {
+
+	ctx := context.Background()
+
+	repo_a, commit_a := testGitRepo(t, "101/736/545/101736545.geojson", "859/225/83/85922583.geojson")
+
+	path := filepath.Join(t.TempDir(), "features.geojson")
+
+	err := os.WriteFile(path, []byte(`{"type":"FeatureCollection","features":[]}`), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write %s, %v", path, err)
+	}
+
+	s, err := ParseSince(ctx, commit_a, path, repo_a)
+
+	if err != nil {
+		t.Fatalf("Expected file to be skipped, %v", err)
+	}
+
+	defer s.Close()
+
+	if len(s.tmpdirs) != 0 {
+		t.Fatalf("Expected file not to be cloned")
+	}
+
+	if len(s.Changes) != 1 {
+		t.Fatalf("Expected 1 change, got %d", len(s.Changes))
+	}
+}
+
+func TestIsRemoteGitURI(t *testing.T) {
+
+	tests := map[string]bool{
+		"https://github.com/whosonfirst-data/whosonfirst-data-admin-ca.git": true,
+		"git@github.com:whosonfirst-data/whosonfirst-data-admin-ca.git":     true,
+		"ssh://git@github.com/whosonfirst-data/whosonfirst-data-admin-ca":   true,
+		"file:///usr/local/data/whosonfirst-data-admin-ca":                  true,
+		"/usr/local/data/features.geojson":                                  false,
+		"features.jsonl":                                                    false,
+		"c:/data/features.geojson":                                          false,
+		"https:///missing-host":                                             false,
+	}
+
+	for repo_uri, expected := range tests {
+
+		if isRemoteGitURI(repo_uri) != expected {
+			t.Fatalf("Expected isRemoteGitURI('%s') to be %t", repo_uri, expected)
+		}
+	}
+}
+
+// testGitRepo creates a new Git repository with two commits, the first adding 'first' and the second
+// adding 'second', returning its path and the hash of the first commit.
+func testGitRepo(t *testing.T, first string, second string) (string, string) {
+
+	root := t.TempDir()
+
+	repo, err := gogit.PlainInit(root, false)
+
+	if err != nil {
+		t.Fatalf("Failed to create repository, %v", err)
+	}
+
+	wt, err := repo.Worktree()
+
+	if err != nil {
+		t.Fatalf("Failed to derive worktree, %v", err)
+	}
+
+	hashes := make([]string, 0)
+
+	for _, rel_path := range []string{first, second} {
+
+		path := filepath.Join(root, "data", rel_path)
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory, %v", err)
+		}
+
+		err = os.WriteFile(path, []byte(`{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[0,0]}}`), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", path, err)
+		}
+
+		_, err = wt.Add(filepath.Join("data", rel_path))
+
+		if err != nil {
+			t.Fatalf("Failed to add %s, %v", rel_path, err)
+		}
+
+		commit_opts := &gogit.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		}
+
+		hash, err := wt.Commit("Add "+rel_path, commit_opts)
+
+		if err != nil {
+			t.Fatalf("Failed to commit %s, %v", rel_path, err)
+		}
+
+		hashes = append(hashes, hash.String())
+	}
+
+	return root, hashes[0]
+}
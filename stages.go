@@ -0,0 +1,190 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/whosonfirst/go-whosonfirst-spr/v2"
+)
+
+// AlternateGeometryFilter implements the `Filter` interface excluding alternate geometry records unless
+// explicitly included.
+type AlternateGeometryFilter struct {
+	Filter
+	// IncludeAltFiles is a boolean flag signaling that alternate geometry records should be included.
+	IncludeAltFiles bool
+}
+
+// Include returns false if 'f' is an alternate geometry record and alternate geometries are not included.
+func (filter *AlternateGeometryFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+	return filter.IncludeAltFiles || !f.IsAlternate(), nil
+}
+
+// SinceFilter implements the `Filter` interface excluding records that have not been modified since a
+// timestamp or Git commit.
+type SinceFilter struct {
+	Filter
+	// Since is the `Since` instance used to test records.
+	Since *Since
+}
+
+// Include returns false if 'f' has not been modified since the point in time defined by 'filter.Since'.
+// The body of 'f' is only read when testing against a timestamp.
+func (filter *SinceFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	if !filter.Since.IncludePath(f.RelPath) {
+		return false, nil
+	}
+
+	if filter.Since.Timestamp <= 0 {
+		return true, nil
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	lastmod_rsp := gjson.GetBytes(body, "properties.wof:lastmodified")
+	return filter.Since.IncludeLastModified(lastmod_rsp.Int()), nil
+}
+
+// PolygonFilter implements the `Filter` interface excluding records whose geometry type is not "Polygon" or "MultiPolygon".
+type PolygonFilter struct {
+	Filter
+}
+
+// Include returns true if the geometry type of 'f' is "Polygon" or "MultiPolygon".
+func (filter *PolygonFilter) Include(ctx context.Context, f *Feature) (bool, error) {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return false, err
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry.type")
+
+	switch geom_rsp.String() {
+	case "Polygon", "MultiPolygon":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// SPRTransformer implements the `Transformer` interface replacing the properties of a record with its
+// Who's On First Standard Places Response (SPR). Alternate geometry records are left unchanged. The "part_index"
+// and "part_key" properties of features created by a `Splitter` are preserved.
+type SPRTransformer struct {
+	Transformer
+}
+
+// Transform replaces the properties of 'f' with its SPR.
+func (t *SPRTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	if f.IsAlternate() {
+		return nil
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	s, err := spr.WhosOnFirstSPR(body)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create SPR for %s, %w", f.Path, err)
+	}
+
+	// ideally use go-whosonfirst-spatial.PropertiesResponseResultsWithStandardPlacesResults here
+	// but not sure what the what is yet
+
+	body, err = sjson.SetBytes(body, "properties", s)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update properties for %s, %w", f.Path, err)
+	}
+
+	// Preserve the index and key of features created by a `Splitter` (for example `ExplodeSplitter`)
+
+	idx, is_part := f.Part()
+
+	if is_part {
+
+		body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", EXPLODE_PART_PROPERTY), idx)
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign %s for %s, %w", EXPLODE_PART_PROPERTY, f.Path, err)
+		}
+
+		body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", EXPLODE_PART_KEY_PROPERTY), partKey(f))
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign %s for %s, %w", EXPLODE_PART_KEY_PROPERTY, f.Path, err)
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
+
+// AppendPropertiesTransformer implements the `Transformer` interface copying properties from the original
+// body of a record in to its current body. This is typically used to append properties to SPR output.
+// Alternate geometry records are left unchanged.
+type AppendPropertiesTransformer struct {
+	Transformer
+	// Properties is the list of properties to append. Paths may be relative ("wof:hierarchy") or absolute ("properties.wof:hierarchy").
+	Properties []string
+}
+
+// Transform copies each of the properties in 't.Properties' which exist in the original body of 'f' to its current body.
+func (t *AppendPropertiesTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	if f.IsAlternate() || len(t.Properties) == 0 {
+		return nil
+	}
+
+	original, err := f.Original()
+
+	if err != nil {
+		return err
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	old_props := gjson.GetBytes(original, "properties")
+
+	for _, path := range t.Properties {
+
+		// Because we are deriving this from old_props and not body
+		rel_path := strings.Replace(path, "properties.", "", 1)
+
+		p_rsp := old_props.Get(rel_path)
+
+		if !p_rsp.Exists() {
+			continue
+		}
+
+		abs_path := fmt.Sprintf("properties.%s", rel_path)
+
+		body, err = sjson.SetBytes(body, abs_path, p_rsp.Value())
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign %s to properties, %w", abs_path, err)
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
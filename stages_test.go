@@ -0,0 +1,296 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"github.com/whosonfirst/go-whosonfirst-iterate/v3"
+	"github.com/whosonfirst/go-whosonfirst-spr/v2"
+	"github.com/whosonfirst/go-whosonfirst-uri"
+)
+
+var testStagesRecords = map[string]string{
+	"101/736/545/101736545.geojson":                   `{"type":"Feature","properties":{"wof:id":101736545,"wof:name":"Montréal","wof:placetype":"locality","wof:parent_id":-1,"wof:country":"CA","wof:repo":"whosonfirst-data-admin-ca","wof:lastmodified":1700000000,"wof:population":1704694,"wof:hierarchy":[{"locality_id":101736545}],"iso:country":"CA","lbl:latitude":45.5,"lbl:longitude":-73.6},"bbox":[-74,45,-73,46],"geometry":{"type":"Polygon","coordinates":[[[-74,45],[-73,45],[-73,46],[-74,46],[-74,45]]]}}`,
+	"101/736/545/101736545-alt-quattroshapes.geojson": `{"type":"Feature","properties":{"wof:id":101736545,"src:alt_label":"quattroshapes","wof:repo":"whosonfirst-data-admin-ca"},"geometry":{"type":"MultiPolygon","coordinates":[[[[-74,45],[-73,45],[-73,46],[-74,46],[-74,45]]]]}}`,
+	"859/225/83/85922583.geojson":                     `{"type":"Feature","properties":{"wof:id":85922583,"wof:name":"San Francisco","wof:placetype":"locality","wof:parent_id":-1,"wof:country":"US","wof:repo":"whosonfirst-data-admin-us","wof:lastmodified":1600000000,"wof:hierarchy":[{"locality_id":85922583}]},"bbox":[-122.4,37.7,-122.4,37.7],"geometry":{"type":"Point","coordinates":[-122.4,37.7]}}`,
+}
+
+func TestAlternateGeometryFilter(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string][2]bool{
+		"101/736/545/101736545.geojson":                   {true, true},
+		"101/736/545/101736545-alt-quattroshapes.geojson": {false, true},
+	}
+
+	for path, expected := range tests {
+
+		for i, include := range []bool{false, true} {
+
+			f, err := NewFeature(path, bytes.NewReader([]byte(testStagesRecords[path])))
+
+			if err != nil {
+				t.Fatalf("Failed to create feature, %v", err)
+			}
+
+			ok, err := (&AlternateGeometryFilter{IncludeAltFiles: include}).Include(ctx, f)
+
+			if err != nil {
+				t.Fatalf("Failed to filter %s, %v", path, err)
+			}
+
+			if ok != expected[i] {
+				t.Fatalf("Expected %s to be included (%t) when including alternate geometries is %t", path, expected[i], include)
+			}
+		}
+	}
+}
+
+func TestPolygonFilter(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]bool{
+		"101/736/545/101736545.geojson":                   true,
+		"101/736/545/101736545-alt-quattroshapes.geojson": true,
+		"859/225/83/85922583.geojson":                     false,
+	}
+
+	for path, expected := range tests {
+
+		f, err := NewFeature(path, bytes.NewReader([]byte(testStagesRecords[path])))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		ok, err := (&PolygonFilter{}).Include(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to filter %s, %v", path, err)
+		}
+
+		if ok != expected {
+			t.Fatalf("Expected %s to be included (%t)", path, expected)
+		}
+	}
+}
+
+func TestSinceFilterTimestamp(t *testing.T) {
+
+	ctx := context.Background()
+
+	filter := &SinceFilter{
+		Since: &Since{Timestamp: 1650000000},
+	}
+
+	tests := map[string]bool{
+		"101/736/545/101736545.geojson": true,
+		"859/225/83/85922583.geojson":   false,
+	}
+
+	for path, expected := range tests {
+
+		f, err := NewFeature(path, bytes.NewReader([]byte(testStagesRecords[path])))
+
+		if err != nil {
+			t.Fatalf("Failed to create feature, %v", err)
+		}
+
+		ok, err := filter.Include(ctx, f)
+
+		if err != nil {
+			t.Fatalf("Failed to filter %s, %v", path, err)
+		}
+
+		if ok != expected {
+			t.Fatalf("Expected %s to be included (%t)", path, expected)
+		}
+	}
+}
+
+func TestSPRTransformerPart(t *testing.T) {
+
+	ctx := context.Background()
+
+	path := "101/736/545/101736545.geojson"
+
+	f, err := NewFeature(path, bytes.NewReader([]byte(testStagesRecords[path])))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	body, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to read body, %v", err)
+	}
+
+	part, err := f.NewPart(1, body)
+
+	if err != nil {
+		t.Fatalf("Failed to create part, %v", err)
+	}
+
+	err = (&SPRTransformer{}).Transform(ctx, part)
+
+	if err != nil {
+		t.Fatalf("Failed to transform part, %v", err)
+	}
+
+	part_body, err := part.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to read part, %v", err)
+	}
+
+	if gjson.GetBytes(part_body, "properties.wof:hierarchy").Exists() {
+		t.Fatalf("Expected properties to be replaced by SPR")
+	}
+
+	if gjson.GetBytes(part_body, "properties."+EXPLODE_PART_PROPERTY).Int() != 1 {
+		t.Fatalf("Expected %s to be preserved", EXPLODE_PART_PROPERTY)
+	}
+}
+
+// TestIterwriterCallbackBaseline ensures that the callback built from the default pipeline writes the same bytes, for
+// the same records, as the original (pre-pipeline) callback for every combination of its options.
+func TestIterwriterCallbackBaseline(t *testing.T) {
+
+	ctx := context.Background()
+
+	append_props := [][]string{
+		nil,
+		{"wof:hierarchy", "properties.wof:population", "wof:missing"},
+	}
+
+	for i := 0; i < 8; i++ {
+
+		for _, props := range append_props {
+
+			opts := &IterwriterCallbackFuncBuilderOptions{
+				RequirePolygon:      i&1 != 0,
+				AsSPR:               i&2 != 0,
+				IncludeAltFiles:     i&4 != 0,
+				AppendSPRProperties: props,
+			}
+
+			label := fmt.Sprintf("require-polygons=%t as-spr=%t include-alt-files=%t spr-append-property=%v", opts.RequirePolygon, opts.AsSPR, opts.IncludeAltFiles, props)
+
+			cb := IterwriterCallbackFuncBuilder(opts)
+
+			for path, body := range testStagesRecords {
+
+				expected, expected_ok, err := baselineCallback(path, []byte(body), opts)
+
+				if err != nil {
+					t.Fatalf("Failed to derive baseline for %s (%s), %v", path, label, err)
+				}
+
+				wr := newCaptureWriter()
+
+				rec := iterate.NewRecord(path, &nopReadSeekCloser{bytes.NewReader([]byte(body))})
+
+				err = cb(ctx, rec, wr)
+
+				if err != nil {
+					t.Fatalf("Failed to process %s (%s), %v", path, label, err)
+				}
+
+				captured := wr.Drain()
+
+				if !expected_ok {
+
+					if len(captured) != 0 {
+						t.Fatalf("Expected %s to be skipped (%s)", path, label)
+					}
+
+					continue
+				}
+
+				if len(captured) != 1 {
+					t.Fatalf("Expected %s to be written once (%s), got %d", path, label, len(captured))
+				}
+
+				if captured[0].key != path {
+					t.Fatalf("Expected %s to be written as %s (%s), got %s", path, path, label, captured[0].key)
+				}
+
+				if !bytes.Equal(captured[0].body, expected) {
+					t.Fatalf("Unexpected output for %s (%s), expected %s, got %s", path, label, expected, captured[0].body)
+				}
+			}
+		}
+	}
+}
+
+// baselineCallback reproduces the processing of the original (pre-pipeline) callback returning the body that would be
+// written for 'path' and whether it would be written at all.
+func baselineCallback(path string, body []byte, opts *IterwriterCallbackFuncBuilderOptions) ([]byte, bool, error) {
+
+	_, uri_args, err := uri.ParseURI(path)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if uri_args.IsAlternate && !opts.IncludeAltFiles {
+		return nil, false, nil
+	}
+
+	if !opts.RequirePolygon && !opts.AsSPR {
+		return body, true, nil
+	}
+
+	if opts.RequirePolygon {
+
+		switch gjson.GetBytes(body, "geometry.type").String() {
+		case "Polygon", "MultiPolygon":
+			// pass
+		default:
+			return nil, false, nil
+		}
+	}
+
+	if opts.AsSPR && !uri_args.IsAlternate {
+
+		s, err := spr.WhosOnFirstSPR(body)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		old_props := gjson.GetBytes(body, "properties")
+
+		body, err = sjson.SetBytes(body, "properties", s)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, path := range opts.AppendSPRProperties {
+
+			rel_path := strings.Replace(path, "properties.", "", 1)
+
+			p_rsp := old_props.Get(rel_path)
+
+			if p_rsp.Exists() {
+
+				body, err = sjson.SetBytes(body, fmt.Sprintf("properties.%s", rel_path), p_rsp.Value())
+
+				if err != nil {
+					return nil, false, err
+				}
+			}
+		}
+	}
+
+	return body, true, nil
+}
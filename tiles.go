@@ -0,0 +1,227 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/maptile"
+	"github.com/paulmach/orb/maptile/tilecover"
+	"github.com/tidwall/gjson"
+	"github.com/whosonfirst/go-writer/v3"
+)
+
+// The maximum zoom level that tippecanoe will generate tiles for.
+const TIPPECANOE_MAX_ZOOM int = 24
+
+// AffectedTiles accumulates the (deduplicated) set of z/x/y map tiles covered by one or more features for
+// a range of zoom levels. It implements the `whosonfirst/go-writer/v3.Writer` interface so that it can be
+// used as the target for `IterwriterCallbackFuncBuilder` callbacks. It is safe for concurrent use.
+type AffectedTiles struct {
+	writer.Writer
+	min_zoom maptile.Zoom
+	max_zoom maptile.Zoom
+	tiles    maptile.Set
+	mu       *sync.Mutex
+}
+
+// NewAffectedTiles returns a new `AffectedTiles` instance for zoom levels 'min_zoom' to 'max_zoom' (inclusive). Zoom levels
+// greater than `TIPPECANOE_MAX_ZOOM` are not supported since the number of tiles covered by a feature grows by a factor of
+// four at each zoom level.
+func NewAffectedTiles(min_zoom int, max_zoom int) (*AffectedTiles, error) {
+
+	if min_zoom < 0 || max_zoom < min_zoom || max_zoom > TIPPECANOE_MAX_ZOOM {
+		return nil, fmt.Errorf("Invalid zoom range %d-%d", min_zoom, max_zoom)
+	}
+
+	t := &AffectedTiles{
+		min_zoom: maptile.Zoom(min_zoom),
+		max_zoom: maptile.Zoom(max_zoom),
+		tiles:    make(maptile.Set),
+		mu:       new(sync.Mutex),
+	}
+
+	return t, nil
+}
+
+// AddFeature adds the tiles covered by the geometry of the GeoJSON Feature 'body' to 't'.
+func (t *AffectedTiles) AddFeature(body []byte) error {
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() {
+		return fmt.Errorf("Missing geometry")
+	}
+
+	geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal geometry, %w", err)
+	}
+
+	// Derive the cover at the maximum zoom level and then walk up the
+	// tile pyramid rather than computing a new cover for each zoom level.
+
+	cover, err := tilecover.Geometry(geom.Geometry(), t.max_zoom)
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive tile cover, %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for z := t.max_zoom; ; z-- {
+
+		parents := make(maptile.Set)
+
+		for tile := range cover {
+			t.tiles[tile] = true
+
+			if z > t.min_zoom {
+				parents[tile.Parent()] = true
+			}
+		}
+
+		if z == t.min_zoom {
+			break
+		}
+
+		cover = parents
+	}
+
+	return nil
+}
+
+// AddPrevious adds the tiles covered by the previous version of each record in 'changes', after processing it with 'p',
+// to 't' so that the tiles a record has vacated are also included. Records which were inserted (and so have no previous
+// version) are skipped. If 'forgiving' is true errors processing individual records are logged rather than returned.
+func (t *AffectedTiles) AddPrevious(ctx context.Context, p *Pipeline, changes []*GitChange, forgiving bool) error {
+
+	for _, ch := range changes {
+
+		logger := slog.Default()
+		logger = logger.With("path", ch.RelPath)
+
+		body, err := ch.Previous()
+
+		if err != nil {
+			return fmt.Errorf("Failed to derive previous version of %s, %w", ch.RelPath, err)
+		}
+
+		if body == nil {
+			continue
+		}
+
+		f, err := NewFeature(ch.RelPath, bytes.NewReader(body))
+
+		if err != nil {
+			return fmt.Errorf("Failed to create feature for previous version of %s, %w", ch.RelPath, err)
+		}
+
+		features, err := p.ProcessAll(ctx, f)
+
+		if err != nil {
+
+			logger.Error("Failed to process previous version of record", "error", err)
+
+			if !forgiving {
+				return fmt.Errorf("Failed to process previous version of %s, %w", ch.RelPath, err)
+			}
+
+			continue
+		}
+
+		for _, out := range features {
+
+			out_body, err := out.Body()
+
+			if err == nil {
+				err = t.AddFeature(out_body)
+			}
+
+			if err != nil {
+
+				logger.Error("Failed to add previous version of record", "error", err)
+
+				if !forgiving {
+					return fmt.Errorf("Failed to add previous version of %s, %w", ch.RelPath, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Tiles returns the list of tiles in 't' sorted by zoom, x and y.
+func (t *AffectedTiles) Tiles() maptile.Tiles {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tiles := make(maptile.Tiles, 0, len(t.tiles))
+
+	for tile := range t.tiles {
+		tiles = append(tiles, tile)
+	}
+
+	sort.Slice(tiles, func(i, j int) bool {
+
+		if tiles[i].Z != tiles[j].Z {
+			return tiles[i].Z < tiles[j].Z
+		}
+
+		if tiles[i].X != tiles[j].X {
+			return tiles[i].X < tiles[j].X
+		}
+
+		return tiles[i].Y < tiles[j].Y
+	})
+
+	return tiles
+}
+
+// Write adds the tiles covered by the GeoJSON Feature in 'r' to 't'.
+func (t *AffectedTiles) Write(ctx context.Context, key string, r io.ReadSeeker) (int64, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read %s, %w", key, err)
+	}
+
+	err = t.AddFeature(body)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to add %s, %w", key, err)
+	}
+
+	return int64(len(body)), nil
+}
+
+// WriterURI returns the value of 'key'.
+func (t *AffectedTiles) WriterURI(ctx context.Context, key string) string {
+	return key
+}
+
+// Flush is a no-op to conform to the `Writer` interface and returns nil.
+func (t *AffectedTiles) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op to conform to the `Writer` interface and returns nil.
+func (t *AffectedTiles) Close(ctx context.Context) error {
+	return nil
+}
+
+// SetLogger is a no-op to conform to the `Writer` interface and returns nil.
+func (t *AffectedTiles) SetLogger(ctx context.Context, logger *log.Logger) error {
+	return nil
+}
@@ -0,0 +1,184 @@
+package tippecanoe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/maptile"
+	"github.com/paulmach/orb/maptile/tilecover"
+)
+
+const testTilesPoint string = `{"type":"Feature","properties":{"wof:id":1234},"geometry":{"type":"Point","coordinates":[-122.4194,37.7749]}}`
+
+const testTilesPolygon string = `{"type":"Feature","properties":{"wof:id":1234},"geometry":{"type":"Polygon","coordinates":[[[-1,-1],[1,-1],[1,1],[-1,1],[-1,-1]]]}}`
+
+func TestAffectedTilesCover(t *testing.T) {
+
+	geoms := map[string]orb.Geometry{
+		testTilesPoint:   orb.Point{-122.4194, 37.7749},
+		testTilesPolygon: orb.Polygon{{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}, {-1, -1}}},
+	}
+
+	for body, geom := range geoms {
+
+		tiles, err := NewAffectedTiles(2, 8)
+
+		if err != nil {
+			t.Fatalf("Failed to create affected tiles, %v", err)
+		}
+
+		err = tiles.AddFeature([]byte(body))
+
+		if err != nil {
+			t.Fatalf("Failed to add feature, %v", err)
+		}
+
+		// Walking up the tile pyramid from the maximum zoom level yields the same tiles as
+		// deriving the cover at each zoom level
+
+		expected := make(maptile.Set)
+
+		for z := maptile.Zoom(2); z <= 8; z++ {
+
+			cover, err := tilecover.Geometry(geom, z)
+
+			if err != nil {
+				t.Fatalf("Failed to derive cover at zoom %d, %v", z, err)
+			}
+
+			for tile := range cover {
+				expected[tile] = true
+			}
+		}
+
+		actual := tiles.Tiles()
+
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %d tiles, got %d", len(expected), len(actual))
+		}
+
+		for _, tile := range actual {
+
+			if !expected[tile] {
+				t.Fatalf("Unexpected tile %d/%d/%d", tile.Z, tile.X, tile.Y)
+			}
+		}
+
+		if actual[0].Z != 2 || actual[len(actual)-1].Z != 8 {
+			t.Fatalf("Expected tiles to be sorted by zoom level")
+		}
+	}
+}
+
+func TestNewAffectedTilesInvalid(t *testing.T) {
+
+	tests := [][2]int{
+		{-1, 4},
+		{6, 4},
+		{0, TIPPECANOE_MAX_ZOOM + 1},
+	}
+
+	for _, zooms := range tests {
+
+		_, err := NewAffectedTiles(zooms[0], zooms[1])
+
+		if err == nil {
+			t.Fatalf("Expected zoom range %d-%d to be invalid", zooms[0], zooms[1])
+		}
+	}
+}
+
+func TestAffectedTilesAddPrevious(t *testing.T) {
+
+	ctx := context.Background()
+
+	moved := &GitChange{
+		RelPath: "123/4/1234.geojson",
+		Action:  GIT_CHANGE_MODIFY,
+		previous: func() ([]byte, error) {
+			return []byte(testTilesPoint), nil
+		},
+	}
+
+	inserted := &GitChange{
+		RelPath: "567/8/5678.geojson",
+		Action:  GIT_CHANGE_INSERT,
+	}
+
+	deleted := &GitChange{
+		RelPath: "910/1/9101.geojson",
+		Action:  GIT_CHANGE_DELETE,
+		previous: func() ([]byte, error) {
+			return []byte(`{"type":"Feature","properties":{"wof:id":9101},"geometry":{"type":"Point","coordinates":[151.2093,-33.8688]}}`), nil
+		},
+	}
+
+	changes := []*GitChange{moved, inserted, deleted}
+
+	tiles, err := NewAffectedTiles(0, 4)
+
+	if err != nil {
+		t.Fatalf("Failed to create affected tiles, %v", err)
+	}
+
+	// The current version of the moved record
+
+	err = tiles.AddFeature([]byte(testTilesPolygon))
+
+	if err != nil {
+		t.Fatalf("Failed to add feature, %v", err)
+	}
+
+	p := DefaultPipeline(&IterwriterCallbackFuncBuilderOptions{})
+
+	err = tiles.AddPrevious(ctx, p, changes, false)
+
+	if err != nil {
+		t.Fatalf("Failed to add previous versions, %v", err)
+	}
+
+	found := tiles.Tiles()
+
+	for _, pt := range []orb.Point{{-122.4194, 37.7749}, {151.2093, -33.8688}, {0.5, 0.5}} {
+
+		tile := maptile.At(pt, 4)
+
+		if !tileInList(found, tile) {
+			t.Fatalf("Expected tile %d/%d/%d for %v", tile.Z, tile.X, tile.Y, pt)
+		}
+	}
+
+	// Previous versions are processed by the pipeline so that records which would not
+	// have been emitted do not invalidate tiles
+
+	tiles, err = NewAffectedTiles(0, 4)
+
+	if err != nil {
+		t.Fatalf("Failed to create affected tiles, %v", err)
+	}
+
+	p = DefaultPipeline(&IterwriterCallbackFuncBuilderOptions{RequirePolygon: true})
+
+	err = tiles.AddPrevious(ctx, p, changes, false)
+
+	if err != nil {
+		t.Fatalf("Failed to add previous versions, %v", err)
+	}
+
+	if len(tiles.Tiles()) != 0 {
+		t.Fatalf("Expected previous Point geometries to be excluded, got %d tiles", len(tiles.Tiles()))
+	}
+}
+
+func tileInList(tiles maptile.Tiles, tile maptile.Tile) bool {
+
+	for _, t := range tiles {
+
+		if t == tile {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aaronland/go-roster"
+	"github.com/tidwall/sjson"
+)
+
+var transformer_roster roster.Roster
+
+// TransformerInitializationFunc is a function defined by individual transformer packages and used to create
+// an instance of that transformer.
+type TransformerInitializationFunc func(ctx context.Context, uri string) (Transformer, error)
+
+// RegisterTransformer registers 'scheme' as a key pointing to 'init_func' in an internal lookup table
+// used to create new `Transformer` instances by the `NewTransformer` method.
+func RegisterTransformer(ctx context.Context, scheme string, init_func TransformerInitializationFunc) error {
+
+	err := ensureTransformerRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return transformer_roster.Register(ctx, scheme, init_func)
+}
+
+func ensureTransformerRoster() error {
+
+	if transformer_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		transformer_roster = r
+	}
+
+	return nil
+}
+
+// NewTransformer returns a new `Transformer` instance configured by 'uri'. The value of 'uri' is parsed
+// as a `url.URL` and its scheme is used as the key for a corresponding `TransformerInitializationFunc`
+// function used to instantiate the new `Transformer`. It is assumed that the scheme (and initialization
+// function) have been registered by the `RegisterTransformer` method.
+func NewTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	scheme := u.Scheme
+
+	if scheme == "" {
+		return nil, fmt.Errorf("Transformer URI is missing scheme '%s'", uri)
+	}
+
+	err = ensureTransformerRoster()
+
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := transformer_roster.Driver(ctx, scheme)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve driver for '%s' scheme, %w", scheme, err)
+	}
+
+	init_func := i.(TransformerInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// TransformerSchemes returns the list of schemes that have been registered.
+func TransformerSchemes() []string {
+
+	ctx := context.Background()
+	schemes := []string{}
+
+	err := ensureTransformerRoster()
+
+	if err != nil {
+		return schemes
+	}
+
+	for _, dr := range transformer_roster.Drivers(ctx) {
+		scheme := fmt.Sprintf("%s://", strings.ToLower(dr))
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// property is a name and value to be assigned to the "properties" dictionary of a feature.
+type property struct {
+	name  string
+	value any
+}
+
+// setPropertiesInOrder assigns each element of 'props' to the "properties" dictionary of 'body', in the order they
+// are listed, and returns the updated body. Properties are assigned in a fixed order, rather than by iterating over
+// a map, so that the output is the same for every run.
+func setPropertiesInOrder(body []byte, props []property) ([]byte, error) {
+
+	for _, p := range props {
+
+		path := fmt.Sprintf("properties.%s", p.name)
+		var err error
+
+		body, err = sjson.SetBytes(body, path, p.value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to assign %s, %w", path, err)
+		}
+	}
+
+	return body, nil
+}
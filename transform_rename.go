@@ -0,0 +1,101 @@
+package tippecanoe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "rename", NewRenameTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RenameTransformer implements the `Transformer` interface renaming one or more properties.
+type RenameTransformer struct {
+	Transformer
+	from []string
+	to   []string
+}
+
+// NewRenameTransformer returns a new `RenameTransformer` instance configured by 'uri' in the form of:
+//
+//	rename://?from={PROPERTY}&to={PROPERTY}
+//
+// Multiple `?from=` and `?to=` parameters may be specified; they are paired in the order they are
+// defined. Property paths may be relative ("wof:name") or absolute ("properties.wof:name").
+func NewRenameTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	from := q["from"]
+	to := q["to"]
+
+	if len(from) == 0 {
+		return nil, fmt.Errorf("Missing ?from= parameter")
+	}
+
+	if len(from) != len(to) {
+		return nil, fmt.Errorf("Mismatched number of ?from= and ?to= parameters")
+	}
+
+	t := &RenameTransformer{
+		from: from,
+		to:   to,
+	}
+
+	return t, nil
+}
+
+// Transform renames each of the properties defined by 't' in 'f'. Properties which do not exist are ignored.
+func (t *RenameTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	for idx, from := range t.from {
+
+		from_path := fmt.Sprintf("properties.%s", strings.Replace(from, "properties.", "", 1))
+		to_path := fmt.Sprintf("properties.%s", strings.Replace(t.to[idx], "properties.", "", 1))
+
+		rsp := gjson.GetBytes(body, from_path)
+
+		if !rsp.Exists() {
+			continue
+		}
+
+		body, err = sjson.DeleteBytes(body, from_path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to remove %s, %w", from_path, err)
+		}
+
+		body, err = sjson.SetRawBytes(body, to_path, []byte(rsp.Raw))
+
+		if err != nil {
+			return fmt.Errorf("Failed to assign %s, %w", to_path, err)
+		}
+	}
+
+	f.SetBody(body)
+	return nil
+}
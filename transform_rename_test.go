@@ -0,0 +1,65 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestRenameTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	body := `{"type":"Feature","properties":{"wof:id":1234,"wof:name":"Test","wof:placetype":"locality"},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	tr, err := NewTransformer(ctx, "rename://?from=wof:name&to=name&from=properties.wof:placetype&to=properties.placetype&from=missing&to=present")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	f, err := NewFeature("123/4/1234.geojson", bytes.NewReader([]byte(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	err = tr.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	out, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to read body, %v", err)
+	}
+
+	expected := `{"type":"Feature","properties":{"wof:id":1234,"name":"Test","placetype":"locality"},"geometry":{"type":"Point","coordinates":[0,0]}}`
+
+	if string(out) != expected {
+		t.Fatalf("Unexpected output, %s", out)
+	}
+}
+
+func TestNewRenameTransformerInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []string{
+		"rename://",
+		"rename://?to=name",
+		"rename://?from=wof:name",
+		"rename://?from=wof:name&from=wof:placetype&to=name",
+	}
+
+	for _, uri := range tests {
+
+		_, err := NewTransformer(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package tippecanoe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/simplify"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func init() {
+
+	ctx := context.Background()
+
+	err := RegisterTransformer(ctx, "simplify", NewSimplifyTransformer)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SimplifyTransformer implements the `Transformer` interface simplifying a feature's geometry using
+// the Douglas-Peucker algorithm.
+type SimplifyTransformer struct {
+	Transformer
+	tolerance float64
+}
+
+// NewSimplifyTransformer returns a new `SimplifyTransformer` instance configured by 'uri' in the form of:
+//
+//	simplify://?tolerance={TOLERANCE}
+//
+// Where {TOLERANCE} is the (required, non-negative) distance threshold, in decimal degrees, used to simplify geometries.
+func NewSimplifyTransformer(ctx context.Context, uri string) (Transformer, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	if !q.Has("tolerance") {
+		return nil, fmt.Errorf("Missing ?tolerance= parameter")
+	}
+
+	tolerance, err := strconv.ParseFloat(q.Get("tolerance"), 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse '?tolerance=' parameter, %w", err)
+	}
+
+	if tolerance < 0 || math.IsNaN(tolerance) {
+		return nil, fmt.Errorf("Invalid '?tolerance=' parameter, must be zero or greater")
+	}
+
+	t := &SimplifyTransformer{
+		tolerance: tolerance,
+	}
+
+	return t, nil
+}
+
+// Transform replaces the geometry of 'f' with a simplified version of that geometry. If the simplified geometry
+// is empty the original geometry is left unchanged.
+func (t *SimplifyTransformer) Transform(ctx context.Context, f *Feature) error {
+
+	body, err := f.Body()
+
+	if err != nil {
+		return err
+	}
+
+	geom_rsp := gjson.GetBytes(body, "geometry")
+
+	if !geom_rsp.Exists() {
+		return nil
+	}
+
+	switch geom_rsp.Get("type").String() {
+	case "Point", "MultiPoint":
+		return nil
+	default:
+		// pass
+	}
+
+	geom, err := geojson.UnmarshalGeometry([]byte(geom_rsp.Raw))
+
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal geometry for %s, %w", f.Path, err)
+	}
+
+	simplified := simplify.DouglasPeucker(t.tolerance).Simplify(geom.Geometry())
+
+	// Simplifying small geometries with a large tolerance can collapse them entirely (for example
+	// every polygon in a MultiPolygon) in which case keep the original geometry rather than emitting
+	// an empty geometry that tippecanoe will reject.
+
+	if simplified == nil || VertexCount(simplified) == 0 {
+		slog.Warn("Simplified geometry is empty, keeping original geometry", "path", f.Path, "tolerance", t.tolerance)
+		return nil
+	}
+
+	enc, err := json.Marshal(geojson.NewGeometry(simplified))
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal simplified geometry for %s, %w", f.Path, err)
+	}
+
+	body, err = sjson.SetRawBytes(body, "geometry", enc)
+
+	if err != nil {
+		return fmt.Errorf("Failed to assign simplified geometry for %s, %w", f.Path, err)
+	}
+
+	f.SetBody(body)
+	return nil
+}
@@ -0,0 +1,105 @@
+package tippecanoe
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestSimplifyTransformerCollapsed(t *testing.T) {
+
+	ctx := context.Background()
+
+	body := `{"type":"Feature","properties":{"wof:id":101736545},"geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[0.001,0],[0.001,0.001],[0,0.001],[0,0]]],[[[1,1],[1.001,1],[1.001,1.001],[1,1.001],[1,1]]]]}}`
+
+	tr, err := NewTransformer(ctx, "simplify://?tolerance=10")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	f, err := NewFeature("101736545.geojson", bytes.NewReader([]byte(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	err = tr.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	out, err := f.Body()
+
+	if err != nil {
+		t.Fatalf("Failed to derive body, %v", err)
+	}
+
+	geom_type := gjson.GetBytes(out, "geometry.type").String()
+
+	if geom_type != "MultiPolygon" {
+		t.Fatalf("Expected MultiPolygon, got '%s'", geom_type)
+	}
+
+	if len(gjson.GetBytes(out, "geometry.coordinates").Array()) == 0 {
+		t.Fatalf("Expected non-empty geometry")
+	}
+}
+
+func TestSimplifyTransformer(t *testing.T) {
+
+	ctx := context.Background()
+
+	body := `{"type":"Feature","properties":{"wof:id":101736545},"geometry":{"type":"LineString","coordinates":[[0,0],[0.5,0.0001],[1,0]]}}`
+
+	tr, err := NewTransformer(ctx, "simplify://?tolerance=0.01")
+
+	if err != nil {
+		t.Fatalf("Failed to create transformer, %v", err)
+	}
+
+	f, err := NewFeature("101736545.geojson", bytes.NewReader([]byte(body)))
+
+	if err != nil {
+		t.Fatalf("Failed to create feature, %v", err)
+	}
+
+	err = tr.Transform(ctx, f)
+
+	if err != nil {
+		t.Fatalf("Failed to transform feature, %v", err)
+	}
+
+	out, _ := f.Body()
+
+	count := len(gjson.GetBytes(out, "geometry.coordinates").Array())
+
+	if count != 2 {
+		t.Fatalf("Expected 2 coordinates, got %d", count)
+	}
+}
+
+func TestNewSimplifyTransformerInvalid(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []string{
+		"simplify://",
+		"simplify://?tolerance=",
+		"simplify://?tolerance=close",
+		"simplify://?tolerance=-0.01",
+		"simplify://?tolerance=NaN",
+	}
+
+	for _, uri := range tests {
+
+		_, err := NewTransformer(ctx, uri)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+}
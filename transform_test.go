@@ -0,0 +1,47 @@
+package tippecanoe
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestTransformerRegistry(t *testing.T) {
+
+	ctx := context.Background()
+
+	schemes := TransformerSchemes()
+
+	for _, s := range []string{"edtf://", "points://", "rank://", "rename://", "simplify://"} {
+
+		if !slices.Contains(schemes, s) {
+			t.Fatalf("Expected %s to be registered, got %v", s, schemes)
+		}
+	}
+
+	err := RegisterTransformer(ctx, "rename", NewRenameTransformer)
+
+	if err == nil {
+		t.Fatalf("Expected registering a scheme twice to fail")
+	}
+
+	tests := map[string]bool{
+		"rename://?from=a&to=b": true,
+		"unknown://":            false,
+		"rename":                false,
+		"://":                   false,
+	}
+
+	for uri, expected := range tests {
+
+		_, err := NewTransformer(ctx, uri)
+
+		if expected && err != nil {
+			t.Fatalf("Expected '%s' to be valid, %v", uri, err)
+		}
+
+		if !expected && err == nil {
+			t.Fatalf("Expected '%s' to be invalid", uri)
+		}
+	}
+}